@@ -0,0 +1,397 @@
+package bills
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Page sizes of the list endpoints of the ApiServer
+const (
+	ApiPageSizeDefault = 50
+	ApiPageSizeMax     = 500
+)
+
+// A page of the items of a list endpoint
+type ApiPage struct {
+	Total  int         `json:"total"`
+	Offset int         `json:"offset"`
+	Limit  int         `json:"limit"`
+	Items  interface{} `json:"items"`
+}
+
+// A bill in the results of a search
+type ApiBillSummary struct {
+	BillNumber   string        `json:"bill_number"`
+	Congress     string        `json:"congress"`
+	BillType     string        `json:"bill_type"`
+	Title        string        `json:"title"`
+	IntroducedAt string        `json:"introduced_at"`
+	Sponsor      CosponsorItem `json:"sponsor"`
+}
+
+type ApiBillVersion struct {
+	Version           string `json:"version"`
+	BillNumberVersion string `json:"bill_number_version"`
+	// Whether the version has a document.xml
+	HasDocument bool `json:"has_document"`
+}
+
+// A version of the bill compared with the bill (from esSimilarCategory.json)
+type ApiBillCategory struct {
+	BillNumberVersion string `json:"bill_number_version"`
+	CompareItem
+}
+
+type ApiTitleBills struct {
+	Index string   `json:"index"`
+	Title string   `json:"title"`
+	Bills []string `json:"bills"`
+}
+
+type apiErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Serves the bill metadata and similarity results in a BillStore as JSON over HTTP.
+// Use Handler() to serve it, e.g. http.ListenAndServe(":8080", apiServer.Handler())
+// Responses have an ETag, and a request with a matching If-None-Match gets 304 Not Modified.
+// The list endpoints take ?offset= and ?limit= (at most ApiPageSizeMax) and return an ApiPage.
+//
+// Endpoints:
+// GET /bills?congress=116&type=hr&sponsor=W000187&cosponsor=A000370&committee=HSBA&subject=Aging&title=...; an ApiPage of ApiBillSummary
+// GET /bills/116hr1500; the BillMeta (billMeta.json)
+// GET /bills/116hr1500/versions; the ApiBillVersion of each version with a directory in text-versions
+// GET /bills/116hr1500/related; an ApiPage of the RelatedBillItem in relatedDict.json, by bill number
+// GET /bills/116hr1500/similar-sections; an ApiPage of the SimilarSectionsItem in esSimilarity.json
+// GET /bills/116hr1500/categories; an ApiPage of the ApiBillCategory in esSimilarCategory.json, highest score first
+// GET /titles?title=Consumers First Act (&index=main for the main titles); the ApiTitleBills
+// GET /health
+type ApiServer struct {
+	Store BillStore
+	// Path to the 'data' directory of the congress tree, for the versions of the bills; may be empty
+	DataPath string
+}
+
+func NewApiServer(billStore BillStore, dataPath string) *ApiServer {
+	return &ApiServer{Store: billStore, DataPath: dataPath}
+}
+
+// An error with the HTTP status to respond with
+type apiError struct {
+	status int
+	err    error
+}
+
+func (e apiError) Error() string {
+	return e.err.Error()
+}
+
+func apiNotFound(format string, args ...interface{}) error {
+	return apiError{http.StatusNotFound, fmt.Errorf(format, args...)}
+}
+
+func apiBadRequest(format string, args ...interface{}) error {
+	return apiError{http.StatusBadRequest, fmt.Errorf(format, args...)}
+}
+
+// Writes the response as JSON with an ETag (a hash of the JSON), or 304 Not Modified if the client has it
+func writeApiResponse(w http.ResponseWriter, r *http.Request, response interface{}) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		writeApiError(w, err)
+		return
+	}
+	hash := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(hash[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	for _, match := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if match = strings.TrimSpace(match); match == etag || match == "*" || match == "W/"+etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
+
+func writeApiError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if apiErr, ok := err.(apiError); ok {
+		status = apiErr.status
+	} else {
+		log.Error().Msgf("Error serving request: %s", err)
+	}
+	writeJSONResponse(w, status, apiErrorResponse{err.Error()})
+}
+
+// Gets the offset and limit of the page from the query
+func apiPageParams(r *http.Request) (offset int, limit int, err error) {
+	limit = ApiPageSizeDefault
+	query := r.URL.Query()
+	if value := query.Get("offset"); value != "" {
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			return 0, 0, apiBadRequest("offset must be a number, 0 or more: %s", value)
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			return 0, 0, apiBadRequest("limit must be a number, 1 or more: %s", value)
+		}
+		if limit > ApiPageSizeMax {
+			limit = ApiPageSizeMax
+		}
+	}
+	return offset, limit, nil
+}
+
+// Gets the page of the total items requested in the query; items gets the slice [start:end] of the items
+func apiPage(r *http.Request, total int, items func(start, end int) interface{}) (ApiPage, error) {
+	offset, limit, err := apiPageParams(r)
+	if err != nil {
+		return ApiPage{}, err
+	}
+	start, end := offset, offset+limit
+	if start > total {
+		start = total
+	}
+	if end > total {
+		end = total
+	}
+	return ApiPage{Total: total, Offset: offset, Limit: limit, Items: items(start, end)}, nil
+}
+
+func (s *ApiServer) getBillMeta(billNumber string) (billMeta BillMeta, err error) {
+	if FindNamedMatches(BillnumberRegexCompiled, billNumber)["congress"] == "" {
+		return billMeta, apiBadRequest("not a bill number: %s", billNumber)
+	}
+	billMeta, err = s.Store.GetBillMeta(billNumber)
+	if err == ErrNotFound {
+		return billMeta, apiNotFound("no metadata for bill: %s", billNumber)
+	}
+	return billMeta, err
+}
+
+// Reads the JSON file of the bill into target. ok is false if the bill has no such file.
+func (s *ApiServer) getBillData(billNumber string, fileName string, target interface{}) (ok bool, err error) {
+	data, err := s.Store.GetBillData(billNumber, fileName)
+	if err == ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return false, fmt.Errorf("error parsing %s for %s: %w", fileName, billNumber, err)
+	}
+	return true, nil
+}
+
+// Searches the bills with the filters in the query
+func (s *ApiServer) searchBills(r *http.Request) (ApiPage, error) {
+	query := r.URL.Query()
+	billMetas, err := s.Store.QueryBillMeta(BillMetaQuery{
+		Congress:    query.Get("congress"),
+		BillType:    query.Get("type"),
+		CommitteeId: query.Get("committee"),
+		Sponsor:     query.Get("sponsor"),
+		Cosponsor:   query.Get("cosponsor"),
+		Subject:     query.Get("subject"),
+		Title:       query.Get("title"),
+	})
+	if err != nil {
+		return ApiPage{}, err
+	}
+	sort.Slice(billMetas, func(i, j int) bool {
+		return billMetas[i].BillCongressTypeNumber < billMetas[j].BillCongressTypeNumber
+	})
+	return apiPage(r, len(billMetas), func(start, end int) interface{} {
+		summaries := []ApiBillSummary{}
+		for _, billMeta := range billMetas[start:end] {
+			title := billMeta.ShortTitle
+			if title == "" && len(billMeta.Titles) > 0 {
+				title = billMeta.Titles[0]
+			}
+			summaries = append(summaries, ApiBillSummary{
+				BillNumber:   billMeta.BillCongressTypeNumber,
+				Congress:     billMeta.Congress,
+				BillType:     billMeta.BillType,
+				Title:        title,
+				IntroducedAt: billMeta.IntroducedAt,
+				Sponsor:      billMeta.Sponsor,
+			})
+		}
+		return summaries
+	})
+}
+
+// Gets the versions of the bill with a directory in text-versions
+func (s *ApiServer) billVersions(billNumber string) ([]ApiBillVersion, error) {
+	versions := []ApiBillVersion{}
+	if s.DataPath == "" {
+		return versions, nil
+	}
+	billPath, err := PathFromBillNumber(billNumber + "ih")
+	if err != nil {
+		return nil, err
+	}
+	versionsDir := path.Join(s.DataPath, path.Dir(billPath))
+	versionDirs, err := os.ReadDir(versionsDir)
+	if os.IsNotExist(err) {
+		return versions, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, versionDir := range versionDirs {
+		if !versionDir.IsDir() {
+			continue
+		}
+		_, statErr := os.Stat(path.Join(versionsDir, versionDir.Name(), "document.xml"))
+		versions = append(versions, ApiBillVersion{Version: versionDir.Name(), BillNumberVersion: billNumber + versionDir.Name(), HasDocument: statErr == nil})
+	}
+	return versions, nil
+}
+
+// Gets the related bills of the bill, from relatedDict.json or, if there is none, from billMeta.json
+func (s *ApiServer) relatedBills(r *http.Request, billMeta BillMeta) (ApiPage, error) {
+	relatedDict := billMeta.RelatedBillsByBillnumber
+	if _, err := s.getBillData(billMeta.BillCongressTypeNumber, RelatedDictFile, &relatedDict); err != nil {
+		return ApiPage{}, err
+	}
+	relatedBillNumbers := make([]string, 0, len(relatedDict))
+	for relatedBillNumber := range relatedDict {
+		relatedBillNumbers = append(relatedBillNumbers, relatedBillNumber)
+	}
+	sort.Strings(relatedBillNumbers)
+	return apiPage(r, len(relatedBillNumbers), func(start, end int) interface{} {
+		items := []RelatedBillItem{}
+		for _, relatedBillNumber := range relatedBillNumbers[start:end] {
+			relatedBillItem := relatedDict[relatedBillNumber]
+			if relatedBillItem.BillCongressTypeNumber == "" {
+				relatedBillItem.BillCongressTypeNumber = relatedBillNumber
+			}
+			items = append(items, relatedBillItem)
+		}
+		return items
+	})
+}
+
+func (s *ApiServer) similarSections(r *http.Request, billNumber string) (ApiPage, error) {
+	var similarSectionsItems SimilarSectionsItems
+	if _, err := s.getBillData(billNumber, EsSimilarityFile, &similarSectionsItems); err != nil {
+		return ApiPage{}, err
+	}
+	return apiPage(r, len(similarSectionsItems), func(start, end int) interface{} {
+		return append(SimilarSectionsItems{}, similarSectionsItems[start:end]...)
+	})
+}
+
+func (s *ApiServer) categories(r *http.Request, billNumber string) (ApiPage, error) {
+	var similarCategory map[string]CompareItem
+	if _, err := s.getBillData(billNumber, EsSimilarCategoryFile, &similarCategory); err != nil {
+		return ApiPage{}, err
+	}
+	categories := make([]ApiBillCategory, 0, len(similarCategory))
+	for billNumberVersion, compareItem := range similarCategory {
+		categories = append(categories, ApiBillCategory{BillNumberVersion: billNumberVersion, CompareItem: compareItem})
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].Score != categories[j].Score {
+			return categories[i].Score > categories[j].Score
+		}
+		return categories[i].BillNumberVersion < categories[j].BillNumberVersion
+	})
+	return apiPage(r, len(categories), func(start, end int) interface{} {
+		return append([]ApiBillCategory{}, categories[start:end]...)
+	})
+}
+
+// Gets the response for /bills/[billNumber] and its sub-resources
+func (s *ApiServer) bill(r *http.Request, billPath string) (interface{}, error) {
+	parts := strings.Split(strings.Trim(billPath, "/"), "/")
+	if len(parts) > 2 {
+		return nil, apiNotFound("no such resource: %s", r.URL.Path)
+	}
+	billMeta, err := s.getBillMeta(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 1 {
+		return billMeta, nil
+	}
+	switch parts[1] {
+	case "versions":
+		return s.billVersions(billMeta.BillCongressTypeNumber)
+	case "related":
+		return s.relatedBills(r, billMeta)
+	case "similar-sections":
+		return s.similarSections(r, billMeta.BillCongressTypeNumber)
+	case "categories":
+		return s.categories(r, billMeta.BillCongressTypeNumber)
+	default:
+		return nil, apiNotFound("no such resource: %s", r.URL.Path)
+	}
+}
+
+// Looks up the bills with the title in the title index. The stores compare titles by their
+// normalized form, so the title can differ in case, punctuation or a final year (e.g. ' of 2019').
+func (s *ApiServer) titleBills(r *http.Request) (ApiTitleBills, error) {
+	title := strings.TrimSpace(r.URL.Query().Get("title"))
+	if title == "" {
+		return ApiTitleBills{}, apiBadRequest("no title in the request")
+	}
+	indexName := TitleNoYearIndex
+	if r.URL.Query().Get("index") == "main" {
+		indexName = MainTitleNoYearIndex
+	}
+	titleBills := ApiTitleBills{Index: indexName, Title: title, Bills: []string{}}
+	billNumbers, err := s.Store.QueryTitleIndex(indexName, title)
+	if err == ErrNotFound {
+		return titleBills, apiNotFound("no bills with the title: %s", title)
+	} else if err != nil {
+		return titleBills, err
+	}
+	titleBills.Bills = billNumbers
+	return titleBills, nil
+}
+
+func (s *ApiServer) handle(get func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeJSONResponse(w, http.StatusMethodNotAllowed, apiErrorResponse{"use GET"})
+			return
+		}
+		response, err := get(r)
+		if err != nil {
+			writeApiError(w, err)
+			return
+		}
+		writeApiResponse(w, r, response)
+	}
+}
+
+// Returns the http.Handler for the API
+func (s *ApiServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bills", s.handle(func(r *http.Request) (interface{}, error) {
+		return s.searchBills(r)
+	}))
+	mux.HandleFunc("/bills/", s.handle(func(r *http.Request) (interface{}, error) {
+		return s.bill(r, strings.TrimPrefix(r.URL.Path, "/bills/"))
+	}))
+	mux.HandleFunc("/titles", s.handle(func(r *http.Request) (interface{}, error) {
+		return s.titleBills(r)
+	}))
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{"status": "ok"})
+	})
+	return mux
+}
@@ -0,0 +1,157 @@
+package bills
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func getApiResponse(t *testing.T, url string, etag string, target interface{}) *http.Response {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error getting %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if target != nil && resp.StatusCode == http.StatusOK {
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(target))
+	}
+	return resp
+}
+
+// Decodes the items of an ApiPage
+func apiPageItems(page ApiPage, target interface{}) error {
+	data, err := json.Marshal(page.Items)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+func TestApiServer(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test the bills API with the Badger store")
+	billStore, err := OpenBadgerBillStore(path.Join(t.TempDir(), "badger"))
+	if err != nil {
+		t.Fatalf("Error opening Badger store: %v", err)
+	}
+	defer billStore.Close()
+	checkApiServer(t, billStore)
+}
+
+func TestApiServerFileStore(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test the bills API with the filesystem store")
+	parentPath := t.TempDir()
+	billDirs, err := filepath.Glob("samples/congress/data/*/bills/*/*")
+	assert.Nil(t, err)
+	for _, billDir := range billDirs {
+		if err := os.MkdirAll(filepath.Join(parentPath, strings.TrimPrefix(billDir, "samples")), 0755); err != nil {
+			t.Fatalf("Error making bill directory: %v", err)
+		}
+	}
+	checkApiServer(t, NewFileBillStore(parentPath))
+}
+
+// Runs the same requests against the API with each BillStore
+func checkApiServer(t *testing.T, billStore BillStore) {
+	metaIndex := NewMetaIndex()
+	metaIndex.MakeBillsMeta("samples", billStore, "115", "116", "117")
+	metaIndex.LoadTitles()
+	WriteRelatedDictsToStore(metaIndex, billStore)
+	assert.Nil(t, billStore.PutTitleIndex(TitleNoYearIndex, metaIndex.TitleIndex()))
+	categories, _ := json.Marshal(map[string]CompareItem{
+		"115hr6972ih": {Score: 0.8, Explanation: "_nearly_identical_"},
+		"116hr1500rh": {Score: 0.9, Explanation: "_identical_"},
+	})
+	assert.Nil(t, billStore.PutBillData("116hr1500", EsSimilarCategoryFile, categories))
+
+	ts := httptest.NewServer(NewApiServer(billStore, "samples/congress/data").Handler())
+	defer ts.Close()
+
+	var billMeta BillMeta
+	resp := getApiResponse(t, ts.URL+"/bills/116hr1500", "", &billMeta)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "116hr1500", billMeta.BillCongressTypeNumber)
+	assert.Equal(t, "Finance and financial sector", billMeta.SubjectsTopTerm)
+	etag := resp.Header.Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, http.StatusNotModified, getApiResponse(t, ts.URL+"/bills/116hr1500", etag, nil).StatusCode)
+
+	assert.Equal(t, http.StatusNotFound, getApiResponse(t, ts.URL+"/bills/116hr9999", "", nil).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, getApiResponse(t, ts.URL+"/bills/hr1500", "", nil).StatusCode)
+	assert.Equal(t, http.StatusNotFound, getApiResponse(t, ts.URL+"/bills/116hr1500/cosponsors", "", nil).StatusCode)
+
+	var versions []ApiBillVersion
+	getApiResponse(t, ts.URL+"/bills/116hr1500/versions", "", &versions)
+	assert.Equal(t, []ApiBillVersion{
+		{Version: "eh", BillNumberVersion: "116hr1500eh", HasDocument: true},
+		{Version: "ih", BillNumberVersion: "116hr1500ih", HasDocument: true},
+		{Version: "rfs", BillNumberVersion: "116hr1500rfs", HasDocument: true},
+		{Version: "rh", BillNumberVersion: "116hr1500rh", HasDocument: true},
+	}, versions)
+
+	var page ApiPage
+	getApiResponse(t, ts.URL+"/bills/116hr1500/related", "", &page)
+	var relatedBills []RelatedBillItem
+	assert.Nil(t, apiPageItems(page, &relatedBills))
+	assert.Equal(t, page.Total, len(relatedBills))
+	assert.Contains(t, relatedBillKeysOfItems(relatedBills), "115hr6972")
+
+	getApiResponse(t, ts.URL+"/bills/116hr1500/categories?limit=1", "", &page)
+	var billCategories []ApiBillCategory
+	assert.Nil(t, apiPageItems(page, &billCategories))
+	assert.Equal(t, 2, page.Total)
+	assert.Equal(t, 1, page.Limit)
+	assert.Equal(t, []ApiBillCategory{{BillNumberVersion: "116hr1500rh", CompareItem: CompareItem{Score: 0.9, Explanation: "_identical_"}}}, billCategories)
+
+	// No esSimilarity.json for the bill
+	getApiResponse(t, ts.URL+"/bills/116hr1500/similar-sections", "", &page)
+	assert.Equal(t, 0, page.Total)
+
+	getApiResponse(t, ts.URL+"/bills?sponsor=W000187", "", &page)
+	var summaries []ApiBillSummary
+	assert.Nil(t, apiPageItems(page, &summaries))
+	assert.Equal(t, 2, page.Total)
+	assert.Equal(t, "115hr6972", summaries[0].BillNumber)
+	assert.Equal(t, "116hr1500", summaries[1].BillNumber)
+	assert.Equal(t, "Consumers First Act", summaries[1].Title)
+
+	getApiResponse(t, ts.URL+"/bills?subject=aging&committee=HSBA&offset=1", "", &page)
+	assert.Equal(t, 1, page.Total)
+	assert.Equal(t, 1, page.Offset)
+	assert.Nil(t, apiPageItems(page, &summaries))
+	assert.Equal(t, 0, len(summaries))
+	getApiResponse(t, ts.URL+"/bills?congress=117&limit=1000", "", &page)
+	assert.Equal(t, 4, page.Total)
+	assert.Equal(t, ApiPageSizeMax, page.Limit)
+	assert.Equal(t, http.StatusBadRequest, getApiResponse(t, ts.URL+"/bills?limit=0", "", nil).StatusCode)
+
+	var titleBills ApiTitleBills
+	resp = getApiResponse(t, ts.URL+"/titles?title=Consumers%20First%20Act%20of%202019", "", &titleBills)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"115hr6972", "116hr1500"}, titleBills.Bills)
+	// Titles are normalized in every store
+	getApiResponse(t, ts.URL+"/titles?title=the%20consumers%20first%20act.", "", &titleBills)
+	assert.Equal(t, []string{"115hr6972", "116hr1500"}, titleBills.Bills)
+	assert.Equal(t, http.StatusNotFound, getApiResponse(t, ts.URL+"/titles?title=No%20Such%20Act", "", nil).StatusCode)
+}
+
+func relatedBillKeysOfItems(relatedBills []RelatedBillItem) (billNumbers []string) {
+	for _, relatedBill := range relatedBills {
+		billNumbers = append(billNumbers, relatedBill.BillCongressTypeNumber)
+	}
+	return billNumbers
+}
@@ -0,0 +1,184 @@
+package bills
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+	bh "github.com/timshannon/badgerhold"
+)
+
+// Number of records written in each Badger transaction when saving a title index
+var badgerBatchSize = 1000
+
+// Bill data saved in Badger, keyed by billNumber + "/" + fileName
+type badgerBillData struct {
+	BillNumber string
+	FileName   string
+	Data       json.RawMessage
+}
+
+// Sends Badger's log messages to zerolog; Badger's info messages are logged at debug level
+type badgerLogger struct{}
+
+func (badgerLogger) Errorf(format string, v ...interface{})   { log.Error().Msgf(format, v...) }
+func (badgerLogger) Warningf(format string, v ...interface{}) { log.Warn().Msgf(format, v...) }
+func (badgerLogger) Infof(format string, v ...interface{})    { log.Debug().Msgf(format, v...) }
+func (badgerLogger) Debugf(format string, v ...interface{})   { log.Debug().Msgf(format, v...) }
+
+// Stores the bill data in a Badger database through badgerhold.
+// Records are encoded as JSON, since BillMeta.History can hold any JSON value.
+type BadgerBillStore struct {
+	store *bh.Store
+}
+
+// Opens (or creates) the Badger database in the badgerPath directory.
+// Badger holds a lock on the directory, so only one process can open it at a time.
+func OpenBadgerBillStore(badgerPath string) (*BadgerBillStore, error) {
+	options := bh.DefaultOptions
+	options.Encoder = json.Marshal
+	options.Decoder = json.Unmarshal
+	options.Dir = badgerPath
+	options.ValueDir = badgerPath
+	options.Logger = badgerLogger{}
+	store, err := bh.Open(options)
+	if err != nil {
+		return nil, err
+	}
+	billStore := &BadgerBillStore{store: store}
+	// Title keys made with an earlier NormalizeTitle could not be found
+	for _, indexName := range []string{TitleNoYearIndex, MainTitleNoYearIndex} {
+		if err := billStore.TitleIndex(indexName).RebuildIfNeeded(); err != nil {
+			store.Close()
+			return nil, fmt.Errorf("error rebuilding the %s title index: %w", indexName, err)
+		}
+	}
+	return billStore, nil
+}
+
+func badgerNotFound(err error) error {
+	if err == bh.ErrNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *BadgerBillStore) GetBillMeta(billNumber string) (billMeta BillMeta, err error) {
+	err = s.store.Get(billNumber, &billMeta)
+	return billMeta, badgerNotFound(err)
+}
+
+func (s *BadgerBillStore) PutBillMeta(billMeta BillMeta) error {
+	return s.store.Upsert(billMeta.BillCongressTypeNumber, billMeta)
+}
+
+func (s *BadgerBillStore) ListBillMeta() (billNumbers []string, err error) {
+	var billMetas []BillMeta
+	if err := s.store.Find(&billMetas, nil); err != nil {
+		return nil, err
+	}
+	for _, billMeta := range billMetas {
+		billNumbers = append(billNumbers, billMeta.BillCongressTypeNumber)
+	}
+	sort.Strings(billNumbers)
+	return billNumbers, nil
+}
+
+func (s *BadgerBillStore) QueryBillMeta(query BillMetaQuery) (billMetas []BillMeta, err error) {
+	// Congress and bill type are filtered by badgerhold; the lists in BillMeta are filtered below
+	var bhQuery *bh.Query
+	if query.Congress != "" {
+		bhQuery = bh.Where("Congress").Eq(query.Congress)
+	}
+	if query.BillType != "" {
+		if bhQuery == nil {
+			bhQuery = bh.Where("BillType").Eq(query.BillType)
+		} else {
+			bhQuery = bhQuery.And("BillType").Eq(query.BillType)
+		}
+	}
+	var found []BillMeta
+	if err := s.store.Find(&found, bhQuery); err != nil {
+		return nil, err
+	}
+	for _, billMeta := range found {
+		if query.Matches(billMeta) {
+			billMetas = append(billMetas, billMeta)
+		}
+	}
+	return billMetas, nil
+}
+
+func (s *BadgerBillStore) GetBillData(billNumber string, fileName string) ([]byte, error) {
+	var billData badgerBillData
+	if err := s.store.Get(billNumber+"/"+fileName, &billData); err != nil {
+		return nil, badgerNotFound(err)
+	}
+	return billData.Data, nil
+}
+
+func (s *BadgerBillStore) PutBillData(billNumber string, fileName string, data []byte) error {
+	// Compacts the data, so that it is stored the same way whether or not it was indented
+	var billData interface{}
+	if err := json.Unmarshal(data, &billData); err != nil {
+		return err
+	}
+	compacted, err := json.Marshal(billData)
+	if err != nil {
+		return err
+	}
+	return s.store.Upsert(billNumber+"/"+fileName, badgerBillData{BillNumber: billNumber, FileName: fileName, Data: compacted})
+}
+
+func (s *BadgerBillStore) ListBillData(fileName string) (billNumbers []string, err error) {
+	var billData []badgerBillData
+	if err := s.store.Find(&billData, bh.Where("FileName").Eq(fileName)); err != nil {
+		return nil, err
+	}
+	for _, item := range billData {
+		billNumbers = append(billNumbers, item.BillNumber)
+	}
+	sort.Strings(billNumbers)
+	return billNumbers, nil
+}
+
+// Gets the title index with the name (e.g. TitleNoYearIndex), for prefix lookups and incremental updates
+func (s *BadgerBillStore) TitleIndex(indexName string) *BadgerTitleIndex {
+	return NewBadgerTitleIndex(s.store.Badger(), indexName)
+}
+
+func (s *BadgerBillStore) GetTitleIndex(indexName string) (map[string][]string, error) {
+	titleIndex, err := s.TitleIndex(indexName).Map()
+	if err != nil {
+		return nil, err
+	}
+	if len(titleIndex) == 0 {
+		return nil, ErrNotFound
+	}
+	return titleIndex, nil
+}
+
+func (s *BadgerBillStore) PutTitleIndex(indexName string, titleIndex map[string][]string) error {
+	return s.TitleIndex(indexName).Put(titleIndex)
+}
+
+// Gets the bills that share the normalized title (see NormalizeTitle)
+func (s *BadgerBillStore) QueryTitleIndex(indexName string, title string) ([]string, error) {
+	billNumbers, err := s.TitleIndex(indexName).Bills(title)
+	if err != nil {
+		return nil, err
+	}
+	if len(billNumbers) == 0 {
+		return nil, ErrNotFound
+	}
+	return billNumbers, nil
+}
+
+func (s *BadgerBillStore) UpdateBillTitles(indexName string, billNumber string, titles []string) error {
+	return s.TitleIndex(indexName).UpdateBillTitles(billNumber, titles)
+}
+
+func (s *BadgerBillStore) Close() error {
+	return s.store.Close()
+}
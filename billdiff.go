@@ -0,0 +1,489 @@
+package bills
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DiffStatusUnchanged = "unchanged"
+	DiffStatusModified  = "modified"
+	DiffStatusAdded     = "added"
+	DiffStatusRemoved   = "removed"
+
+	DiffOpEqual  = "equal"
+	DiffOpInsert = "insert"
+	DiffOpDelete = "delete"
+)
+
+// Output formats for WriteBillDiff
+const (
+	BillDiffFormatJSON = "json"
+	BillDiffFormatText = "text"
+	BillDiffFormatHTML = "html"
+)
+
+var BillDiffFormats = []string{BillDiffFormatJSON, BillDiffFormatText, BillDiffFormatHTML}
+
+var (
+	// Above this number of word edits, a modified section is shown as a full replacement
+	maxWordEdits = 2000
+)
+
+// A top-level section of a bill, with its text split into words
+type BillSection struct {
+	SectionIndex  int
+	SectionNumber string
+	SectionHeader string
+	Words         []string
+}
+
+// A run of words that are equal, inserted or deleted
+type WordDiff struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+type SectionDiff struct {
+	Status            string     `json:"status"`
+	FromSectionNumber string     `json:"from_section_number,omitempty"`
+	FromSectionHeader string     `json:"from_section_header,omitempty"`
+	ToSectionNumber   string     `json:"to_section_number,omitempty"`
+	ToSectionHeader   string     `json:"to_section_header,omitempty"`
+	WordDiffs         []WordDiff `json:"word_diffs,omitempty"`
+}
+
+type BillDiff struct {
+	From      string        `json:"from"` // bill number version, e.g. 116hr1500ih
+	To        string        `json:"to"`   // bill number version, e.g. 116hr1500eh
+	Added     int           `json:"added"`
+	Removed   int           `json:"removed"`
+	Modified  int           `json:"modified"`
+	Unchanged int           `json:"unchanged"`
+	Sections  []SectionDiff `json:"sections"`
+}
+
+// True if the node is inside another section or a quoted block (e.g. text of an amendment to existing law)
+func isNestedSection(node *xmlquery.Node) bool {
+	for parent := node.Parent; parent != nil; parent = parent.Parent {
+		if parent.Data == "section" || parent.Data == "quoted-block" {
+			return true
+		}
+	}
+	return false
+}
+
+// Gets the top-level sections of a bill xml file
+func ParseBillSections(docPath string) (sections []BillSection, err error) {
+	xmlFile, err := os.Open(docPath)
+	if err != nil {
+		return nil, err
+	}
+	defer xmlFile.Close()
+	doc, err := xmlquery.Parse(xmlFile)
+	if err != nil {
+		return nil, err
+	}
+	return parseBillSections(doc), nil
+}
+
+// Gets the top-level sections of a parsed bill xml document
+func parseBillSections(doc *xmlquery.Node) (sections []BillSection) {
+	for _, sectionNode := range xmlquery.Find(doc, "//section") {
+		if isNestedSection(sectionNode) {
+			continue
+		}
+		section := BillSection{SectionIndex: len(sections)}
+		var body []string
+		for child := sectionNode.FirstChild; child != nil; child = child.NextSibling {
+			switch child.Data {
+			case "enum":
+				section.SectionNumber = strings.TrimSpace(child.InnerText())
+			case "header":
+				section.SectionHeader = strings.TrimSpace(child.InnerText())
+			default:
+				body = append(body, nodeText(child))
+			}
+		}
+		section.Words = strings.Fields(strings.Join(body, " "))
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+// Gets the text of a node, with a space between the text of each element
+// (InnerText would join e.g. the enum and header of a subsection)
+func nodeText(node *xmlquery.Node) string {
+	if node.Type == xmlquery.TextNode || node.Type == xmlquery.CharDataNode {
+		return node.Data
+	}
+	var texts []string
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		texts = append(texts, nodeText(child))
+	}
+	return strings.Join(texts, " ")
+}
+
+func normalizeHeader(header string) string {
+	return strings.ToLower(strings.Join(strings.Fields(header), " "))
+}
+
+// Aligns the sections of two versions of a bill.
+// Sections are matched first by header, then (for the remaining sections) by section number.
+// Returns, for each section of toSections, the index of the matching section of fromSections or -1
+func AlignSections(fromSections, toSections []BillSection) []int {
+	alignment := make([]int, len(toSections))
+	matched := make([]bool, len(fromSections))
+	for i := range alignment {
+		alignment[i] = -1
+	}
+	keys := []func(BillSection) string{
+		func(s BillSection) string { return normalizeHeader(s.SectionHeader) },
+		func(s BillSection) string { return strings.TrimSpace(s.SectionNumber) },
+	}
+	for _, key := range keys {
+		for i, toSection := range toSections {
+			if alignment[i] >= 0 || key(toSection) == "" {
+				continue
+			}
+			for j, fromSection := range fromSections {
+				if !matched[j] && key(fromSection) == key(toSection) {
+					alignment[i] = j
+					matched[j] = true
+					break
+				}
+			}
+		}
+	}
+	return alignment
+}
+
+// Diffs two lists of words, using the Myers algorithm.
+// Consecutive words with the same operation are joined into one WordDiff.
+func DiffWords(from, to []string) (wordDiffs []WordDiff) {
+	// Equal prefix and suffix do not need to go through the diff
+	prefix := 0
+	for prefix < len(from) && prefix < len(to) && from[prefix] == to[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(from)-prefix && suffix < len(to)-prefix && from[len(from)-1-suffix] == to[len(to)-1-suffix] {
+		suffix++
+	}
+	var ops []string
+	var words []string
+	add := func(op, word string) {
+		ops = append(ops, op)
+		words = append(words, word)
+	}
+	for _, word := range from[:prefix] {
+		add(DiffOpEqual, word)
+	}
+	a := from[prefix : len(from)-suffix]
+	b := to[prefix : len(to)-suffix]
+	middleOps, ok := myersDiff(a, b, maxWordEdits)
+	if !ok {
+		middleOps = nil
+		for range a {
+			middleOps = append(middleOps, DiffOpDelete)
+		}
+		for range b {
+			middleOps = append(middleOps, DiffOpInsert)
+		}
+	}
+	x, y := 0, 0
+	for _, op := range middleOps {
+		switch op {
+		case DiffOpEqual:
+			add(op, a[x])
+			x++
+			y++
+		case DiffOpDelete:
+			add(op, a[x])
+			x++
+		case DiffOpInsert:
+			add(op, b[y])
+			y++
+		}
+	}
+	for _, word := range from[len(from)-suffix:] {
+		add(DiffOpEqual, word)
+	}
+
+	for i, op := range ops {
+		if len(wordDiffs) > 0 && wordDiffs[len(wordDiffs)-1].Op == op {
+			wordDiffs[len(wordDiffs)-1].Text += " " + words[i]
+		} else {
+			wordDiffs = append(wordDiffs, WordDiff{Op: op, Text: words[i]})
+		}
+	}
+	return wordDiffs
+}
+
+// Returns the edit script from a to b, as a list of DiffOp* operations.
+// Returns ok = false if the number of edits is larger than maxEdits
+// See http://www.xmailserver.org/diff2.pdf
+func myersDiff(a, b []string, maxEdits int) (ops []string, ok bool) {
+	n, m := len(a), len(b)
+	max := n + m
+	if max > maxEdits {
+		max = maxEdits
+	}
+	// v[k] is the furthest x reached on diagonal k; stored with an offset of max+1
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d] holds v[-d-1...d+1] before step d
+	var trace [][]int
+	done := false
+	for d := 0; d <= max && !done; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				done = true
+				break
+			}
+		}
+	}
+	if !done {
+		return nil, false
+	}
+
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		vd := trace[d]
+		get := func(k int) int { return vd[k+d+1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && get(k-1) < get(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := get(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, DiffOpEqual)
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, DiffOpInsert)
+			} else {
+				ops = append(ops, DiffOpDelete)
+			}
+		}
+		x, y = prevX, prevY
+	}
+	ReverseStrings(ops)
+	return ops, true
+}
+
+func wordsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Compares the sections of two versions of a bill.
+// Removed sections are listed before the next section that is in both versions.
+func DiffBillSections(fromSections, toSections []BillSection) (billDiff BillDiff) {
+	alignment := AlignSections(fromSections, toSections)
+	inTo := make([]bool, len(fromSections))
+	for _, j := range alignment {
+		if j >= 0 {
+			inTo[j] = true
+		}
+	}
+	nextFrom := 0
+	addRemoved := func(upTo int) {
+		for ; nextFrom < upTo; nextFrom++ {
+			if !inTo[nextFrom] {
+				fromSection := fromSections[nextFrom]
+				billDiff.Removed++
+				billDiff.Sections = append(billDiff.Sections, SectionDiff{
+					Status:            DiffStatusRemoved,
+					FromSectionNumber: fromSection.SectionNumber,
+					FromSectionHeader: fromSection.SectionHeader,
+					WordDiffs:         []WordDiff{{Op: DiffOpDelete, Text: strings.Join(fromSection.Words, " ")}},
+				})
+			}
+		}
+	}
+	for i, toSection := range toSections {
+		j := alignment[i]
+		if j < 0 {
+			billDiff.Added++
+			billDiff.Sections = append(billDiff.Sections, SectionDiff{
+				Status:          DiffStatusAdded,
+				ToSectionNumber: toSection.SectionNumber,
+				ToSectionHeader: toSection.SectionHeader,
+				WordDiffs:       []WordDiff{{Op: DiffOpInsert, Text: strings.Join(toSection.Words, " ")}},
+			})
+			continue
+		}
+		if j >= nextFrom {
+			addRemoved(j + 1)
+		}
+		fromSection := fromSections[j]
+		sectionDiff := SectionDiff{
+			FromSectionNumber: fromSection.SectionNumber,
+			FromSectionHeader: fromSection.SectionHeader,
+			ToSectionNumber:   toSection.SectionNumber,
+			ToSectionHeader:   toSection.SectionHeader,
+		}
+		if wordsEqual(fromSection.Words, toSection.Words) && fromSection.SectionHeader == toSection.SectionHeader {
+			sectionDiff.Status = DiffStatusUnchanged
+			billDiff.Unchanged++
+		} else {
+			sectionDiff.Status = DiffStatusModified
+			sectionDiff.WordDiffs = DiffWords(fromSection.Words, toSection.Words)
+			billDiff.Modified++
+		}
+		billDiff.Sections = append(billDiff.Sections, sectionDiff)
+	}
+	addRemoved(len(fromSections))
+	return billDiff
+}
+
+// Compares two bill xml files, section by section
+func DiffBillsFromPaths(fromPath, toPath string) (billDiff BillDiff, err error) {
+	fromSections, err := ParseBillSections(fromPath)
+	if err != nil {
+		log.Error().Msgf("Error parsing %s: %s", fromPath, err)
+		return billDiff, err
+	}
+	toSections, err := ParseBillSections(toPath)
+	if err != nil {
+		log.Error().Msgf("Error parsing %s: %s", toPath, err)
+		return billDiff, err
+	}
+	billDiff = DiffBillSections(fromSections, toSections)
+	billDiff.From = BillNumberFromPath(fromPath)
+	billDiff.To = BillNumberFromPath(toPath)
+	return billDiff, nil
+}
+
+// Compares two versions of a bill, given as bill number versions (e.g. 116hr1500ih and 116hr1500eh).
+// parentPath is the path to the 'data' directory of the congress tree, as in CompareBills
+func DiffBills(parentPath, fromBillNumberVersion, toBillNumberVersion string) (billDiff BillDiff, err error) {
+	var docPaths []string
+	for _, billNumber := range []string{fromBillNumberVersion, toBillNumberVersion} {
+		billPath, err := PathFromBillNumber(billNumber)
+		if err != nil {
+			return billDiff, fmt.Errorf("could not get path for %s: %s", billNumber, err)
+		}
+		docPaths = append(docPaths, path.Join(parentPath, billPath, "document.xml"))
+	}
+	return DiffBillsFromPaths(docPaths[0], docPaths[1])
+}
+
+func sectionTitle(number, header string) string {
+	return strings.TrimSpace(number + " " + header)
+}
+
+// Renders the diff as text, in the form of a unified diff with word-level changes marked as [-deleted-] and {+inserted+}
+func (billDiff BillDiff) Text() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", billDiff.From, billDiff.To)
+	fmt.Fprintf(&sb, "# %d added, %d removed, %d modified, %d unchanged sections\n", billDiff.Added, billDiff.Removed, billDiff.Modified, billDiff.Unchanged)
+	for _, section := range billDiff.Sections {
+		switch section.Status {
+		case DiffStatusAdded:
+			fmt.Fprintf(&sb, "\n+ SEC. %s\n", sectionTitle(section.ToSectionNumber, section.ToSectionHeader))
+		case DiffStatusRemoved:
+			fmt.Fprintf(&sb, "\n- SEC. %s\n", sectionTitle(section.FromSectionNumber, section.FromSectionHeader))
+		case DiffStatusModified:
+			fmt.Fprintf(&sb, "\n@@ SEC. %s -> SEC. %s @@\n", sectionTitle(section.FromSectionNumber, section.FromSectionHeader), sectionTitle(section.ToSectionNumber, section.ToSectionHeader))
+		default:
+			continue
+		}
+		for _, wordDiff := range section.WordDiffs {
+			switch wordDiff.Op {
+			case DiffOpInsert:
+				fmt.Fprintf(&sb, "{+%s+} ", wordDiff.Text)
+			case DiffOpDelete:
+				fmt.Fprintf(&sb, "[-%s-] ", wordDiff.Text)
+			default:
+				sb.WriteString(wordDiff.Text + " ")
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// Renders the diff as an HTML fragment, with <ins> and <del> for word-level changes
+func (billDiff BillDiff) HTML() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<div class=\"billdiff\">\n<h2>%s &rarr; %s</h2>\n", html.EscapeString(billDiff.From), html.EscapeString(billDiff.To))
+	fmt.Fprintf(&sb, "<p>%d added, %d removed, %d modified, %d unchanged sections</p>\n", billDiff.Added, billDiff.Removed, billDiff.Modified, billDiff.Unchanged)
+	for _, section := range billDiff.Sections {
+		title := sectionTitle(section.ToSectionNumber, section.ToSectionHeader)
+		if section.Status == DiffStatusRemoved {
+			title = sectionTitle(section.FromSectionNumber, section.FromSectionHeader)
+		}
+		fmt.Fprintf(&sb, "<section class=\"%s\">\n<h3>SEC. %s</h3>\n<p>", section.Status, html.EscapeString(title))
+		for _, wordDiff := range section.WordDiffs {
+			text := html.EscapeString(wordDiff.Text)
+			switch wordDiff.Op {
+			case DiffOpInsert:
+				fmt.Fprintf(&sb, "<ins>%s</ins> ", text)
+			case DiffOpDelete:
+				fmt.Fprintf(&sb, "<del>%s</del> ", text)
+			default:
+				sb.WriteString(text + " ")
+			}
+		}
+		sb.WriteString("</p>\n</section>\n")
+	}
+	sb.WriteString("</div>\n")
+	return sb.String()
+}
+
+// Writes the diff to w, in one of the formats:
+// BillDiffFormatJSON: the diff as indented JSON
+// BillDiffFormatText: a unified diff of the sections (see Text)
+// BillDiffFormatHTML: an HTML fragment (see HTML)
+func WriteBillDiff(w io.Writer, billDiff BillDiff, format string) error {
+	switch format {
+	case BillDiffFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", " ")
+		return encoder.Encode(billDiff)
+	case BillDiffFormatText:
+		_, err := io.WriteString(w, billDiff.Text())
+		return err
+	case BillDiffFormatHTML:
+		_, err := io.WriteString(w, billDiff.HTML())
+		return err
+	default:
+		return fmt.Errorf("unknown bill diff format: %s (options: %s)", format, strings.Join(BillDiffFormats, ", "))
+	}
+}
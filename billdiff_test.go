@@ -0,0 +1,58 @@
+package bills
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var sampleFilePathIH = path.Join("samples", "congress", "data", "116", "bills", "hr", "hr1500", "text-versions", "ih", "document.xml")
+
+func TestDiffWords(t *testing.T) {
+	log.Info().Msg("Test word-level diff")
+	testutils.SetLogLevel()
+	from := strings.Fields("The Bureau may not retire the HMDA Explorer tool")
+	to := strings.Fields("The Consumer Financial Protection Bureau may retire the HMDA Explorer tool")
+	wordDiffs := DiffWords(from, to)
+	assert.Equal(t, []WordDiff{
+		{Op: DiffOpEqual, Text: "The"},
+		{Op: DiffOpInsert, Text: "Consumer Financial Protection"},
+		{Op: DiffOpEqual, Text: "Bureau may"},
+		{Op: DiffOpDelete, Text: "not"},
+		{Op: DiffOpEqual, Text: "retire the HMDA Explorer tool"},
+	}, wordDiffs)
+}
+
+func TestDiffBillsFromPaths(t *testing.T) {
+	log.Info().Msg("Test diff of two versions of a bill")
+	testutils.SetLogLevel()
+	billDiff, err := DiffBillsFromPaths(sampleFilePathIH, sampleFilePath)
+	if err != nil {
+		t.Fatalf("Error comparing bill versions: %v", err)
+	}
+	assert.Equal(t, "116hr1500ih", billDiff.From)
+	assert.Equal(t, "116hr1500eh", billDiff.To)
+	assert.Greater(t, billDiff.Added, 0)
+	assert.Equal(t, len(billDiff.Sections), billDiff.Added+billDiff.Removed+billDiff.Modified+billDiff.Unchanged)
+	assert.Contains(t, billDiff.Text(), "+++ 116hr1500eh")
+	assert.Contains(t, billDiff.HTML(), "<ins>")
+
+	var buf bytes.Buffer
+	assert.Nil(t, WriteBillDiff(&buf, billDiff, BillDiffFormatJSON))
+	var billDiffJson BillDiff
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &billDiffJson))
+	assert.Equal(t, billDiff.To, billDiffJson.To)
+	buf.Reset()
+	assert.Nil(t, WriteBillDiff(&buf, billDiff, BillDiffFormatText))
+	assert.Equal(t, billDiff.Text(), buf.String())
+	assert.NotNil(t, WriteBillDiff(&buf, billDiff, "xml"))
+
+	sameDiff, _ := DiffBillsFromPaths(sampleFilePath, sampleFilePath)
+	assert.Equal(t, len(sameDiff.Sections), sameDiff.Unchanged)
+}
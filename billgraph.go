@@ -0,0 +1,446 @@
+package bills
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Output formats for WriteBillGraph
+const (
+	GraphFormatJSON    = "json"
+	GraphFormatGraphML = "graphml"
+	GraphFormatDOT     = "dot"
+)
+
+var (
+	GraphFormats = []string{GraphFormatJSON, GraphFormatGraphML, GraphFormatDOT}
+	// Similarity categories (see getExplanation) that mean one bill incorporates the other
+	IncorporatesReason     = "bills-incorporates"
+	IncorporatedByReason   = "bills-incorporated_by"
+	UnrelatedReason        = "bills-unrelated"
+	IdentifiedBySimilarity = "esSimilarCategory"
+)
+
+// A bill in the graph. Bills that are only known as the related bill of another bill have no metadata.
+type BillGraphNode struct {
+	BillNumber string `json:"bill_number"`
+	Congress   string `json:"congress,omitempty"`
+	BillType   string `json:"bill_type,omitempty"`
+	Title      string `json:"title,omitempty"`
+}
+
+// A relation from one bill to another, from the relatedDict.json and esSimilarCategory.json of the From bill.
+// Reasons are the reasons in relatedDict.json (e.g. bills-title_match) and the similarity category (e.g. bills-incorporates);
+// Score and ScoreOther are the similarity of From to To, and of To to From, if the bills were compared.
+type BillGraphEdge struct {
+	From         string   `json:"from"`
+	To           string   `json:"to"`
+	Reasons      []string `json:"reasons"`
+	IdentifiedBy []string `json:"identified_by"`
+	Score        float64  `json:"score"`
+	ScoreOther   float64  `json:"score_other"`
+}
+
+func (edge BillGraphEdge) HasReason(reason string) bool {
+	for _, edgeReason := range edge.Reasons {
+		if edgeReason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Directed graph of bills and their related bills
+type BillGraph struct {
+	Nodes map[string]*BillGraphNode
+	// from -> to -> edge
+	edges map[string]map[string]*BillGraphEdge
+	// to -> from, for traversals that ignore the direction of edges
+	reverse map[string]map[string]bool
+}
+
+func NewBillGraph() *BillGraph {
+	return &BillGraph{
+		Nodes:   make(map[string]*BillGraphNode),
+		edges:   make(map[string]map[string]*BillGraphEdge),
+		reverse: make(map[string]map[string]bool),
+	}
+}
+
+// Adds the bill, or updates it with the metadata
+func (g *BillGraph) AddNode(billMeta BillMeta) {
+	node := g.node(billMeta.BillCongressTypeNumber)
+	node.Congress = billMeta.Congress
+	node.BillType = billMeta.BillType
+	node.Title = billMeta.ShortTitle
+	if node.Title == "" {
+		node.Title = billMeta.OfficialTitle
+	}
+}
+
+func (g *BillGraph) node(billNumber string) *BillGraphNode {
+	node, ok := g.Nodes[billNumber]
+	if !ok {
+		node = &BillGraphNode{BillNumber: billNumber}
+		g.Nodes[billNumber] = node
+	}
+	return node
+}
+
+// Gets the edge from one bill to another, adding it (and the bills) if it is not in the graph
+func (g *BillGraph) edge(from string, to string) *BillGraphEdge {
+	g.node(from)
+	g.node(to)
+	if g.edges[from] == nil {
+		g.edges[from] = make(map[string]*BillGraphEdge)
+	}
+	edge, ok := g.edges[from][to]
+	if !ok {
+		edge = &BillGraphEdge{From: from, To: to, Reasons: []string{}, IdentifiedBy: []string{}}
+		g.edges[from][to] = edge
+		if g.reverse[to] == nil {
+			g.reverse[to] = make(map[string]bool)
+		}
+		g.reverse[to][from] = true
+	}
+	return edge
+}
+
+// Adds the edges from the bill to the bills in its relatedDict.json (related bills) and esSimilarCategory.json (similarCategory).
+// Bills compared as unrelated are only added as a score on an edge from related bills.
+func (g *BillGraph) AddRelatedBills(billNumber string, relatedBills RelatedBillMap, similarCategory map[string]CompareItem) {
+	g.node(billNumber)
+	for relatedBillNumber, relatedBillItem := range relatedBills {
+		if relatedBillNumber == billNumber {
+			continue
+		}
+		edge := g.edge(billNumber, relatedBillNumber)
+		edge.Reasons = SortReasons(RemoveDuplicates(append(edge.Reasons, relatedBillItem.Reason...)))
+		edge.IdentifiedBy = RemoveDuplicates(append(edge.IdentifiedBy, relatedBillItem.IdentifiedBy...))
+	}
+	for relatedBillNumber, billNumberVersion := range BestCompareVersions(billNumber, similarCategory) {
+		compareItem := similarCategory[billNumberVersion]
+		if _, related := relatedBills[relatedBillNumber]; !related && (compareItem.Explanation == UnrelatedReason || compareItem.Explanation == "") {
+			continue
+		}
+		edge := g.edge(billNumber, relatedBillNumber)
+		edge.Score, edge.ScoreOther = compareItem.Score, compareItem.ScoreOther
+		if compareItem.Explanation != "" && compareItem.Explanation != UnrelatedReason {
+			edge.Reasons = SortReasons(RemoveDuplicates(append(edge.Reasons, compareItem.Explanation)))
+			edge.IdentifiedBy = RemoveDuplicates(append(edge.IdentifiedBy, IdentifiedBySimilarity))
+		}
+	}
+}
+
+// Gets the edge from one bill to another
+func (g *BillGraph) Edge(from string, to string) (BillGraphEdge, bool) {
+	edge, ok := g.edges[from][to]
+	if !ok {
+		return BillGraphEdge{}, false
+	}
+	return *edge, true
+}
+
+// Gets all of the edges, sorted by From and To
+func (g *BillGraph) Edges() []BillGraphEdge {
+	edges := []BillGraphEdge{}
+	for _, from := range g.BillNumbers() {
+		for _, edge := range g.edges[from] {
+			edges = append(edges, *edge)
+		}
+	}
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	return edges
+}
+
+// Gets the sorted bill numbers of the bills in the graph
+func (g *BillGraph) BillNumbers() []string {
+	billNumbers := make([]string, 0, len(g.Nodes))
+	for billNumber := range g.Nodes {
+		billNumbers = append(billNumbers, billNumber)
+	}
+	sort.Strings(billNumbers)
+	return billNumbers
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Gets the bills with an edge to or from the bill, sorted
+func (g *BillGraph) Neighbors(billNumber string) []string {
+	neighbors := make(map[string]bool)
+	for to := range g.edges[billNumber] {
+		neighbors[to] = true
+	}
+	for from := range g.reverse[billNumber] {
+		neighbors[from] = true
+	}
+	return sortedKeys(neighbors)
+}
+
+// Gets the groups of bills that are connected (in either direction), largest first
+func (g *BillGraph) ConnectedComponents() [][]string {
+	visited := make(map[string]bool)
+	components := [][]string{}
+	for _, billNumber := range g.BillNumbers() {
+		if visited[billNumber] {
+			continue
+		}
+		component := []string{}
+		queue := []string{billNumber}
+		visited[billNumber] = true
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			component = append(component, current)
+			for _, neighbor := range g.Neighbors(current) {
+				if !visited[neighbor] {
+					visited[neighbor] = true
+					queue = append(queue, neighbor)
+				}
+			}
+		}
+		sort.Strings(component)
+		components = append(components, component)
+	}
+	sort.SliceStable(components, func(i, j int) bool {
+		return len(components[i]) > len(components[j])
+	})
+	return components
+}
+
+// Gets the shortest chain of related bills from one bill to another (following edges in either direction),
+// including both bills; nil if they are not connected
+func (g *BillGraph) ShortestPath(from string, to string) []string {
+	if _, ok := g.Nodes[from]; !ok {
+		return nil
+	}
+	if _, ok := g.Nodes[to]; !ok {
+		return nil
+	}
+	previous := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == to {
+			path := []string{}
+			for billNumber := to; billNumber != ""; billNumber = previous[billNumber] {
+				path = append([]string{billNumber}, path...)
+			}
+			return path
+		}
+		for _, neighbor := range g.Neighbors(current) {
+			if _, seen := previous[neighbor]; !seen {
+				previous[neighbor] = current
+				queue = append(queue, neighbor)
+			}
+		}
+	}
+	return nil
+}
+
+// Gets the bills that are incorporated into the bill (e.g. an enrolled omnibus bill): the bills it has a
+// bills-incorporates edge to, and the bills with a bills-incorporated_by edge to it. Returns the edges, sorted by bill number.
+func (g *BillGraph) IncorporatedInto(billNumber string) []BillGraphEdge {
+	incorporated := make(map[string]BillGraphEdge)
+	for to, edge := range g.edges[billNumber] {
+		if edge.HasReason(IncorporatesReason) {
+			incorporated[to] = *edge
+		}
+	}
+	for from := range g.reverse[billNumber] {
+		if edge := g.edges[from][billNumber]; edge.HasReason(IncorporatedByReason) {
+			if _, ok := incorporated[from]; !ok {
+				incorporated[from] = *edge
+			}
+		}
+	}
+	incorporatedBills := make(map[string]bool)
+	for incorporatedBill := range incorporated {
+		incorporatedBills[incorporatedBill] = true
+	}
+	edges := []BillGraphEdge{}
+	for _, incorporatedBill := range sortedKeys(incorporatedBills) {
+		edges = append(edges, incorporated[incorporatedBill])
+	}
+	return edges
+}
+
+// Gets a graph with only the bills, and the edges between them
+func (g *BillGraph) Subgraph(billNumbers []string) *BillGraph {
+	subgraph := NewBillGraph()
+	include := make(map[string]bool)
+	for _, billNumber := range billNumbers {
+		if node, ok := g.Nodes[billNumber]; ok {
+			nodeCopy := *node
+			subgraph.Nodes[billNumber] = &nodeCopy
+			include[billNumber] = true
+		}
+	}
+	for from := range include {
+		for to, edge := range g.edges[from] {
+			if include[to] {
+				*subgraph.edge(from, to) = *edge
+			}
+		}
+	}
+	return subgraph
+}
+
+// Builds the graph of the bills in the store, from congress fromCongress to toCongress (inclusive; 0 for no limit)
+func LoadBillGraph(billStore BillStore, fromCongress int, toCongress int) (*BillGraph, error) {
+	inRange := func(billNumber string) bool {
+		congress, err := strconv.Atoi(BillnumberRegexCompiled.ReplaceAllString(billNumber, "$1"))
+		if err != nil {
+			return false
+		}
+		return (fromCongress == 0 || congress >= fromCongress) && (toCongress == 0 || congress <= toCongress)
+	}
+	graph := NewBillGraph()
+	billNumbers, err := billStore.ListBillMeta()
+	if err != nil {
+		return nil, err
+	}
+	for _, billNumber := range billNumbers {
+		if !inRange(billNumber) {
+			continue
+		}
+		billMeta, err := billStore.GetBillMeta(billNumber)
+		if err != nil {
+			log.Error().Msgf("Error reading metadata for %s: %s", billNumber, err)
+			continue
+		}
+		if billMeta.BillCongressTypeNumber == "" {
+			billMeta.BillCongressTypeNumber = billNumber
+		}
+		graph.AddNode(billMeta)
+		// The related bills in billMeta.json are the same as in relatedDict.json, when it exists
+		relatedBills := billMeta.RelatedBillsByBillnumber
+		if data, err := billStore.GetBillData(billNumber, RelatedDictFile); err == nil {
+			var relatedDict RelatedBillMap
+			if err := json.Unmarshal(data, &relatedDict); err != nil {
+				log.Error().Msgf("Error parsing %s for %s: %s", RelatedDictFile, billNumber, err)
+			} else {
+				relatedBills = relatedDict
+			}
+		}
+		var similarCategory map[string]CompareItem
+		if data, err := billStore.GetBillData(billNumber, EsSimilarCategoryFile); err == nil {
+			if err := json.Unmarshal(data, &similarCategory); err != nil {
+				log.Error().Msgf("Error parsing %s for %s: %s", EsSimilarCategoryFile, billNumber, err)
+			}
+		}
+		graph.AddRelatedBills(billNumber, relatedBills, similarCategory)
+	}
+	return graph, nil
+}
+
+// The graph in GraphFormatJSON
+type billGraphJson struct {
+	Nodes []BillGraphNode `json:"nodes"`
+	Edges []BillGraphEdge `json:"edges"`
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+// Writes the graph to w, in one of the formats:
+// GraphFormatJSON: {"nodes": [...], "edges": [...]}
+// GraphFormatGraphML: GraphML, with the title and congress of each bill and the reasons, identified_by and scores of each edge as data
+// GraphFormatDOT: a Graphviz digraph, with the reasons as edge labels
+func WriteBillGraph(w io.Writer, graph *BillGraph, format string) error {
+	switch format {
+	case GraphFormatJSON:
+		graphJson := billGraphJson{Nodes: []BillGraphNode{}, Edges: graph.Edges()}
+		for _, billNumber := range graph.BillNumbers() {
+			graphJson.Nodes = append(graphJson.Nodes, *graph.Nodes[billNumber])
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", " ")
+		return encoder.Encode(graphJson)
+	case GraphFormatGraphML:
+		var b strings.Builder
+		b.WriteString(xml.Header)
+		b.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+		for _, key := range [][]string{
+			{"title", "node", "string"}, {"congress", "node", "string"}, {"bill_type", "node", "string"},
+			{"reasons", "edge", "string"}, {"identified_by", "edge", "string"}, {"score", "edge", "double"}, {"score_other", "edge", "double"},
+		} {
+			fmt.Fprintf(&b, `  <key id="%s" for="%s" attr.name="%s" attr.type="%s"/>`+"\n", key[0], key[1], key[0], key[2])
+		}
+		b.WriteString(`  <graph id="bills" edgedefault="directed">` + "\n")
+		for _, billNumber := range graph.BillNumbers() {
+			node := graph.Nodes[billNumber]
+			fmt.Fprintf(&b, `    <node id="%s">`, xmlEscape(billNumber))
+			for _, data := range [][2]string{{"title", node.Title}, {"congress", node.Congress}, {"bill_type", node.BillType}} {
+				if data[1] != "" {
+					fmt.Fprintf(&b, `<data key="%s">%s</data>`, data[0], xmlEscape(data[1]))
+				}
+			}
+			b.WriteString("</node>\n")
+		}
+		for _, edge := range graph.Edges() {
+			fmt.Fprintf(&b, `    <edge source="%s" target="%s">`, xmlEscape(edge.From), xmlEscape(edge.To))
+			fmt.Fprintf(&b, `<data key="reasons">%s</data>`, xmlEscape(strings.Join(edge.Reasons, ", ")))
+			fmt.Fprintf(&b, `<data key="identified_by">%s</data>`, xmlEscape(strings.Join(edge.IdentifiedBy, ", ")))
+			fmt.Fprintf(&b, `<data key="score">%s</data><data key="score_other">%s</data>`, formatScore(edge.Score), formatScore(edge.ScoreOther))
+			b.WriteString("</edge>\n")
+		}
+		b.WriteString("  </graph>\n</graphml>\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	case GraphFormatDOT:
+		var b strings.Builder
+		b.WriteString("digraph bills {\n")
+		for _, billNumber := range graph.BillNumbers() {
+			node := graph.Nodes[billNumber]
+			label := billNumber
+			if node.Title != "" {
+				label += "\n" + node.Title
+			}
+			fmt.Fprintf(&b, "  %s [label=%s];\n", dotQuote(billNumber), dotQuote(label))
+		}
+		for _, edge := range graph.Edges() {
+			reasons := make([]string, len(edge.Reasons))
+			for i, reason := range edge.Reasons {
+				reasons[i] = strings.TrimPrefix(reason, "bills-")
+			}
+			fmt.Fprintf(&b, "  %s -> %s [label=%s, score=%s];\n", dotQuote(edge.From), dotQuote(edge.To), dotQuote(strings.Join(reasons, ", ")), formatScore(edge.Score))
+		}
+		b.WriteString("}\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	default:
+		return fmt.Errorf("unknown graph format: %s (options: %s)", format, strings.Join(GraphFormats, ", "))
+	}
+}
@@ -0,0 +1,133 @@
+package bills
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"path"
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// 116hr133 (enrolled) incorporates 116hr7617 and 116s1000; 116hr7617 and 116hr7000 share a title; 116hr1500 is on its own
+func sampleBillGraph() *BillGraph {
+	graph := NewBillGraph()
+	for _, billNumber := range []string{"116hr133", "116hr7617", "116hr7000", "116s1000", "116hr1500"} {
+		graph.AddNode(BillMeta{BillCongressTypeNumber: billNumber, Congress: "116", ShortTitle: "Title of " + billNumber})
+	}
+	graph.AddRelatedBills("116hr133", nil, map[string]CompareItem{
+		"116hr133enr":  {Score: 1, ScoreOther: 1, Explanation: "bills-identical"},
+		"116hr7617rh":  {Score: 0.9, ScoreOther: 0.05, Explanation: IncorporatesReason},
+		"116hr7617ih":  {Score: 0.85, ScoreOther: 0.04, Explanation: IncorporatesReason},
+		"116hr1500ih":  {Score: 0.01, ScoreOther: 0.01, Explanation: UnrelatedReason},
+		"116hr7000ih":  {Score: 0.02, ScoreOther: 0.01, Explanation: UnrelatedReason},
+		"116hr1500eh":  {Score: 0.02, ScoreOther: 0.01, Explanation: UnrelatedReason},
+		"116hr7617eh":  {Score: 0.5, ScoreOther: 0.04, Explanation: "bills-some_similarity"},
+		"116hr133eas":  {Score: 0.9, ScoreOther: 0.9, Explanation: "bills-nearly_identical"},
+		"116hr7617rfs": {Score: 0.1, ScoreOther: 0.1, Explanation: "bills-some_similarity"},
+	})
+	graph.AddRelatedBills("116s1000", nil, map[string]CompareItem{
+		"116hr133enr": {Score: 0.05, ScoreOther: 0.9, Explanation: IncorporatedByReason},
+	})
+	graph.AddRelatedBills("116hr7617", RelatedBillMap{
+		"116hr7617": {Reason: NewReasonSet("identical")},
+		"116hr7000": {Reason: NewReasonSet("bills-title_match, bills-title_match_main"), IdentifiedBy: NewSourceSet("BillMap")},
+	}, nil)
+	return graph
+}
+
+func TestBillGraphQueries(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test querying the related bills graph")
+	graph := sampleBillGraph()
+
+	edge, ok := graph.Edge("116hr133", "116hr7617")
+	assert.True(t, ok)
+	// The highest scoring version is kept
+	assert.Equal(t, 0.9, edge.Score)
+	assert.Equal(t, []string{IncorporatesReason}, edge.Reasons)
+	assert.Equal(t, []string{IdentifiedBySimilarity}, edge.IdentifiedBy)
+	_, ok = graph.Edge("116hr133", "116hr1500")
+	assert.False(t, ok)
+	edge, _ = graph.Edge("116hr7617", "116hr7000")
+	assert.Equal(t, []string{"bills-title_match", "bills-title_match_main"}, edge.Reasons)
+	assert.Equal(t, []string{"BillMap"}, edge.IdentifiedBy)
+
+	assert.Equal(t, [][]string{{"116hr133", "116hr7000", "116hr7617", "116s1000"}, {"116hr1500"}}, graph.ConnectedComponents())
+	assert.Equal(t, []string{"116s1000", "116hr133", "116hr7617", "116hr7000"}, graph.ShortestPath("116s1000", "116hr7000"))
+	assert.Nil(t, graph.ShortestPath("116s1000", "116hr1500"))
+	assert.Nil(t, graph.ShortestPath("116s1000", "117hr1"))
+
+	incorporated := graph.IncorporatedInto("116hr133")
+	assert.Equal(t, 2, len(incorporated))
+	assert.Equal(t, "116hr7617", incorporated[0].To)
+	assert.Equal(t, "116s1000", incorporated[1].From)
+	assert.Equal(t, 0, len(graph.IncorporatedInto("116hr7617")))
+}
+
+func TestWriteBillGraph(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test exporting the related bills graph")
+	graph := sampleBillGraph().Subgraph([]string{"116hr7617", "116hr7000", "116hr1500"})
+	assert.Equal(t, 3, len(graph.Nodes))
+	assert.Equal(t, 1, len(graph.Edges()))
+
+	var buf bytes.Buffer
+	assert.Nil(t, WriteBillGraph(&buf, graph, GraphFormatJSON))
+	var graphJson billGraphJson
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &graphJson))
+	assert.Equal(t, 3, len(graphJson.Nodes))
+	assert.Equal(t, "116hr7617", graphJson.Edges[0].From)
+
+	buf.Reset()
+	assert.Nil(t, WriteBillGraph(&buf, graph, GraphFormatGraphML))
+	var graphML struct {
+		Graph struct {
+			Nodes []struct {
+				Id string `xml:"id,attr"`
+			} `xml:"node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+	assert.Nil(t, xml.Unmarshal(buf.Bytes(), &graphML))
+	assert.Equal(t, 3, len(graphML.Graph.Nodes))
+	assert.Equal(t, "116hr7000", graphML.Graph.Edges[0].Target)
+
+	buf.Reset()
+	assert.Nil(t, WriteBillGraph(&buf, graph, GraphFormatDOT))
+	assert.Contains(t, buf.String(), `"116hr7617" -> "116hr7000" [label="title_match, title_match_main", score=0];`)
+
+	assert.NotNil(t, WriteBillGraph(&buf, graph, "png"))
+}
+
+func TestLoadBillGraph(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test loading the related bills graph from a store")
+	billStore, err := OpenBadgerBillStore(path.Join(t.TempDir(), "badger"))
+	assert.Nil(t, err)
+	defer billStore.Close()
+	for _, billMetaPath := range sampleBillMetaPaths {
+		assert.Nil(t, billStore.PutBillMeta(readSampleBillMeta(t, billMetaPath)))
+	}
+	assert.Nil(t, billStore.PutBillData("116hr1500", RelatedDictFile, []byte(`{"116hr1500": {"reason": "identical"}, "116s3000": {"reason": "bills-title_match"}}`)))
+	assert.Nil(t, billStore.PutBillData("116hr1500", EsSimilarCategoryFile, []byte(`{"116s3000is": {"Score": 0.4, "ScoreOther": 0.3, "Explanation": "bills-some_similarity"}}`)))
+
+	graph, err := LoadBillGraph(billStore, 116, 116)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"116hr1500", "116s3000"}, graph.BillNumbers())
+	assert.Equal(t, "Consumers First Act", graph.Nodes["116hr1500"].Title)
+	edge, ok := graph.Edge("116hr1500", "116s3000")
+	assert.True(t, ok)
+	assert.ElementsMatch(t, []string{"bills-some_similarity", "bills-title_match"}, edge.Reasons)
+	assert.Equal(t, 0.4, edge.Score)
+
+	graph, err = LoadBillGraph(billStore, 117, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"117s100"}, graph.BillNumbers()[:1])
+}
@@ -22,161 +22,111 @@ func GetSyncMapKeys(m *sync.Map) (s string) {
 	return
 }
 
-func LoadTitles(titleSyncMap *sync.Map, billMetaSyncMap *sync.Map) {
-	log.Info().Msg("***** Processing title matches ******")
-	titleSyncMap.Range(func(billTitle, titleBills interface{}) bool {
-		//log.Info().Msg(titleBills)
-		for _, titleBill := range titleBills.([]string) {
-			//log.Info().Msg("titleBill ", titleBill)
+// Fills in the BillId of the item from the BillCongressTypeNumber, or the BillCongressTypeNumber from the BillId
+func normalizeRelatedBillIds(relatedBillItem *RelatedBillItem) {
+	if relatedBillItem.BillId == "" && relatedBillItem.BillCongressTypeNumber != "" {
+		relatedBillItem.BillId = BillNumberToBillId(relatedBillItem.BillCongressTypeNumber)
+	}
+	if relatedBillItem.BillCongressTypeNumber == "" && relatedBillItem.BillId != "" {
+		relatedBillItem.BillCongressTypeNumber = BillIdToBillNumber(relatedBillItem.BillId)
+	}
+}
+
+// Relates the bill (billNumber) in relatedBills with the reason, identified by IdentifiedByBillMap unless
+// provenance.IdentifiedBy is set. If the bill is already related, the reason is added to its reasons.
+// update, if not nil, sets the other fields of the item (e.g. the matching titles).
+func mergeRelatedBill(relatedBills RelatedBillMap, billNumber string, reason string, provenance ReasonProvenance, update func(relatedBillItem *RelatedBillItem)) {
+	relatedBillItem, ok := relatedBills[billNumber]
+	if !ok {
+		relatedBillItem = RelatedBillItem{BillCongressTypeNumber: billNumber}
+	}
+	if provenance.IdentifiedBy == "" {
+		provenance.IdentifiedBy = IdentifiedByBillMap
+	}
+	relatedBillItem.AddReason(reason, provenance)
+	if update != nil {
+		update(&relatedBillItem)
+	}
+	normalizeRelatedBillIds(&relatedBillItem)
+	relatedBills[billNumber] = relatedBillItem
+}
+
+// Relates the bills in each entry of the title index to each other (and to themselves), with the reason.
+// addTitle adds the title of the entry to the related bill item. The caller holds the lock.
+func (mi *MetaIndex) loadTitleIndex(titleIndex map[string][]string, reason string, addTitle func(relatedBillItem *RelatedBillItem, title string)) {
+	for billTitle, titleBills := range titleIndex {
+		for _, titleBill := range titleBills {
 			// titleBill is a bill number
-			if billItem, ok := billMetaSyncMap.Load(titleBill); ok {
-				billItemStruct := billItem.(BillMeta)
-				relatedBills := billItemStruct.RelatedBillsByBillnumber
-				/*
-					if relatedBills != nil && len(relatedBills) > 0 {
-						relatedBills = bills.RelatedBillMap{}
-					}
-				*/
-				// TODO check that each of titleBills is in relatedBills
-				// If it is, make sure 'title match' is one of the reasons
-				// Add the billTitle to Titles, if it is not already there
-				// If it's not, add it with 'title match'
-				for _, titleBillRelated := range titleBills.([]string) {
-					// titleBillRelated is the bill number of the related bill
-					if relatedBillItem, ok := relatedBills[titleBillRelated]; ok {
-						log.Debug().Msgf("Bill with Related Title: %s", titleBillRelated)
-						relatedBillItem.Reason = strings.Join(SortReasons(RemoveDuplicates(append(strings.Split(relatedBillItem.Reason, ", "), TitleMatchReason))), ", ")
-						relatedBillItem.IdentifiedBy = strings.Join(RemoveDuplicates(append(strings.Split(relatedBillItem.IdentifiedBy, ", "), IdentifiedByBillMap)), ", ")
-						relatedBillItem.Titles = RemoveDuplicates(append(relatedBillItem.Titles, billTitle.(string)))
-						log.Debug().Msgf("Titles: %v", relatedBillItem.Titles)
-						relatedBills[titleBillRelated] = relatedBillItem
-						if relatedBillItem.BillId == "" && relatedBillItem.BillCongressTypeNumber != "" {
-							relatedBillItem.BillId = BillNumberToBillId(relatedBillItem.BillCongressTypeNumber)
-						}
-						log.Debug().Msgf("relatedBillItem: %v", relatedBillItem)
-						if relatedBillItem.BillCongressTypeNumber == "" && relatedBillItem.BillId != "" {
-							relatedBillItem.BillCongressTypeNumber = BillIdToBillNumber(relatedBillItem.BillId)
-						}
-					} else {
-						newRelatedBillItem := new(RelatedBillItem)
-						newRelatedBillItem.BillCongressTypeNumber = titleBillRelated
-						newRelatedBillItem.Titles = []string{billTitle.(string)}
-						newRelatedBillItem.Reason = TitleMatchReason
-						newRelatedBillItem.IdentifiedBy = IdentifiedByBillMap
-						if newRelatedBillItem.BillId == "" && newRelatedBillItem.BillCongressTypeNumber != "" {
-							newRelatedBillItem.BillId = BillNumberToBillId(newRelatedBillItem.BillCongressTypeNumber)
-						}
-						if newRelatedBillItem.BillCongressTypeNumber == "" && newRelatedBillItem.BillId != "" {
-							newRelatedBillItem.BillCongressTypeNumber = BillIdToBillNumber(newRelatedBillItem.BillId)
-						}
-						//if relatedBillItem.Type == "" {
-						//}
-						relatedBills[titleBillRelated] = *newRelatedBillItem
-					}
-				}
-				// Store new relatedbills
-				billItemStruct.RelatedBillsByBillnumber = relatedBills
-				billMetaSyncMap.Store(titleBill, billItemStruct)
-			} else {
-				log.Error().Msgf("No metadata in BillMetaSyncMap for bill: %s", titleBill)
+			billItemStruct, ok := mi.billMeta[titleBill]
+			if !ok {
+				log.Error().Msgf("No metadata in the MetaIndex for bill: %s", titleBill)
+				continue
 			}
-
+			relatedBills := billItemStruct.RelatedBillsByBillnumber
+			if relatedBills == nil {
+				relatedBills = make(RelatedBillMap)
+			}
+			for _, titleBillRelated := range titleBills {
+				// titleBillRelated is the bill number of the related bill
+				log.Debug().Msgf("Bill with related title (%s): %s", reason, titleBillRelated)
+				mergeRelatedBill(relatedBills, titleBillRelated, reason, ReasonProvenance{Titles: []string{billTitle}, RunId: mi.RunId}, func(relatedBillItem *RelatedBillItem) {
+					addTitle(relatedBillItem, billTitle)
+				})
+			}
+			// Store new relatedbills
+			billItemStruct.RelatedBillsByBillnumber = relatedBills
+			mi.billMeta[titleBill] = billItemStruct
 		}
-		return true
+	}
+}
+
+// Adds the bills with the same title (without the year) to the related bills of each bill in the index
+func (mi *MetaIndex) LoadTitles() {
+	log.Info().Msg("***** Processing title matches ******")
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	mi.loadTitleIndex(mi.titleNoYear, TitleMatchReason, func(relatedBillItem *RelatedBillItem, title string) {
+		relatedBillItem.Titles = RemoveDuplicates(append(relatedBillItem.Titles, title))
 	})
 }
 
-func LoadMainTitles(mainTitleSyncMap *sync.Map, billMetaSyncMap *sync.Map) {
+// Adds the bills with the same main title (without the year) to the related bills of each bill in the index
+func (mi *MetaIndex) LoadMainTitles() {
 	log.Info().Msg("***** Processing main title matches ******")
-
-	mainTitleSyncMap.Range(func(billTitle, titleBills interface{}) bool {
-		//log.Info().Msg(titleBills)
-		for _, titleBill := range titleBills.([]string) {
-			//log.Info().Msg("titleBill ", titleBill)
-			// titleBill is a bill number
-			if billItem, ok := billMetaSyncMap.Load(titleBill); ok {
-				billItemStruct := billItem.(BillMeta)
-				relatedBills := billItemStruct.RelatedBillsByBillnumber
-				/*
-					if relatedBills != nil && len(relatedBills) > 0 {
-						relatedBills = bills.RelatedBillMap{}
-					}
-				*/
-				// Check that each of titleBills is in relatedBills
-				// If it is, make sure 'title match' is one of the reasons
-				// Add the billTitle to Titles, if it is not already there
-				// If it's not, add it with bills.MainTitleMatchReason
-				for _, titleBillRelated := range titleBills.([]string) {
-					// titleBillRelated is the bill number of the related bill
-					if relatedBillItem, ok := relatedBills[titleBillRelated]; ok {
-						log.Debug().Msgf("Bill with Related Main Title: %s", titleBillRelated)
-						relatedBillItem.Reason = strings.Join(SortReasons(RemoveDuplicates(append(strings.Split(relatedBillItem.Reason, ", "), MainTitleMatchReason))), ", ")
-						relatedBillItem.IdentifiedBy = strings.Join(RemoveDuplicates(append(strings.Split(relatedBillItem.IdentifiedBy, ", "), IdentifiedByBillMap)), ", ")
-						relatedBillItem.TitlesWholeBill = RemoveDuplicates(append(relatedBillItem.TitlesWholeBill, billTitle.(string)))
-						if relatedBillItem.BillId == "" && relatedBillItem.BillCongressTypeNumber != "" {
-							relatedBillItem.BillId = BillNumberToBillId(relatedBillItem.BillCongressTypeNumber)
-						}
-						if relatedBillItem.BillCongressTypeNumber == "" && relatedBillItem.BillId != "" {
-							relatedBillItem.BillCongressTypeNumber = BillIdToBillNumber(relatedBillItem.BillId)
-						}
-						relatedBills[titleBillRelated] = relatedBillItem
-					} else {
-						newRelatedBillItem := new(RelatedBillItem)
-						newRelatedBillItem.BillCongressTypeNumber = titleBillRelated
-						newRelatedBillItem.TitlesWholeBill = []string{billTitle.(string)}
-						newRelatedBillItem.Reason = MainTitleMatchReason
-						relatedBillItem.IdentifiedBy = IdentifiedByBillMap
-						if newRelatedBillItem.BillId == "" && newRelatedBillItem.BillCongressTypeNumber != "" {
-							newRelatedBillItem.BillId = BillNumberToBillId(newRelatedBillItem.BillCongressTypeNumber)
-						}
-						if newRelatedBillItem.BillCongressTypeNumber == "" && newRelatedBillItem.BillId != "" {
-							newRelatedBillItem.BillCongressTypeNumber = BillIdToBillNumber(newRelatedBillItem.BillId)
-						}
-						relatedBills[titleBillRelated] = *newRelatedBillItem
-					}
-				}
-				// Store new relatedbills
-				billItemStruct.RelatedBillsByBillnumber = relatedBills
-				billMetaSyncMap.Store(titleBill, billItemStruct)
-			} else {
-				log.Error().Msgf("No metadata in BillMetaSyncMap for bill: %s", titleBill)
-			}
-
-		}
-		return true
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	mi.loadTitleIndex(mi.mainTitleNoYear, MainTitleMatchReason, func(relatedBillItem *RelatedBillItem, title string) {
+		relatedBillItem.TitlesWholeBill = RemoveDuplicates(append(relatedBillItem.TitlesWholeBill, title))
 	})
 }
 
 // TODO: return saved path
 func WriteBillMetaFile(billMeta interface{}, parentPath string) (saved bool) {
-	// Write bill meta file
-	billCongressTypeNumber := billMeta.(BillMeta).BillCongressTypeNumber
+	return WriteBillMetaToStore(billMeta.(BillMeta), NewFileBillStore(parentPath))
+}
+
+// Saves the bill metadata to the store (for the filesystem store, `billMeta.json` in the bill directory)
+func WriteBillMetaToStore(billMeta BillMeta, billStore BillStore) (saved bool) {
+	billCongressTypeNumber := billMeta.BillCongressTypeNumber
 	log.Info().Msgf("Writing metadata for: %s", billCongressTypeNumber)
-	file, marshalErr := json.MarshalIndent(billMeta.(BillMeta), "", " ")
-	if marshalErr != nil {
-		log.Error().Msgf("error marshalling metadata for: %s\nErr: %s", billCongressTypeNumber, marshalErr)
-	}
-	savePath, saveErr := SaveBillDataJson(billCongressTypeNumber, file, parentPath, "billMeta.json")
-	if saveErr != nil {
-		log.Error().Msgf("Error saving meta file: %s", saveErr)
+	if err := billStore.PutBillMeta(billMeta); err != nil {
+		log.Error().Msgf("Error saving metadata: %s", err)
 		return false
 	}
-	if len(savePath) > 0 {
-		log.Info().Msgf("Saved metadata for: %s to: %s", billCongressTypeNumber, savePath)
-	}
+	log.Info().Msgf("Saved metadata for: %s", billCongressTypeNumber)
 	return true
 }
 
-func WriteBillMetaFiles(billMetaSyncMap *sync.Map, parentPath string) {
+func WriteBillMetaFiles(metaIndex *MetaIndex, parentPath string) {
 	log.Info().Msg("***** Writing individual bill metadata to files ******")
 
-	billMetaSyncMap.Range(func(billCongressTypeNumber, billMeta interface{}) bool {
+	metaIndex.RangeBillMeta(func(billCongressTypeNumber string, billMeta BillMeta) bool {
 		log.Info().Msgf("Writing metadata for: %s", billCongressTypeNumber)
-		file, marshalErr := json.MarshalIndent(billMeta.(BillMeta), "", " ")
+		file, marshalErr := json.MarshalIndent(billMeta, "", " ")
 		if marshalErr != nil {
 			log.Error().Msgf("error marshalling metadata for: %s\nErr: %s", billCongressTypeNumber, marshalErr)
 		}
-		_, saveErr := SaveBillDataJson(billCongressTypeNumber.(string), file, parentPath, "billMeta.json")
+		_, saveErr := SaveBillDataJson(billCongressTypeNumber, file, parentPath, BillMetaJsonFile)
 		if saveErr != nil {
 			log.Error().Msgf("Error saving meta file: %s", saveErr)
 		}
@@ -184,16 +134,21 @@ func WriteBillMetaFiles(billMetaSyncMap *sync.Map, parentPath string) {
 	})
 }
 
-func WriteRelatedDictFiles(billMetaSyncMap *sync.Map, parentPath string) {
+func WriteRelatedDictFiles(metaIndex *MetaIndex, parentPath string) {
+	WriteRelatedDictsToStore(metaIndex, NewFileBillStore(parentPath))
+}
+
+// Saves the related_dict of each bill to the store (for the filesystem store, `relatedDict.json` in the bill directory)
+func WriteRelatedDictsToStore(metaIndex *MetaIndex, billStore BillStore) {
 	log.Info().Msg("***** Writing individual related_dict json to files ******")
 
-	billMetaSyncMap.Range(func(billCongressTypeNumber, billMeta interface{}) bool {
+	metaIndex.RangeBillMeta(func(billCongressTypeNumber string, billMeta BillMeta) bool {
 		log.Info().Msgf("Writing related dict data for: %s", billCongressTypeNumber)
-		file, marshalErr := json.MarshalIndent(billMeta.(BillMeta).RelatedBillsByBillnumber, "", " ")
+		file, marshalErr := json.MarshalIndent(billMeta.RelatedBillsByBillnumber, "", " ")
 		if marshalErr != nil {
 			log.Error().Msgf("error marshalling related dict for: %s\nErr: %s", billCongressTypeNumber, marshalErr)
 		}
-		_, saveErr := SaveBillDataJson(billCongressTypeNumber.(string), file, parentPath, "relatedDict.json")
+		saveErr := billStore.PutBillData(billCongressTypeNumber, RelatedDictFile, file)
 		if saveErr != nil {
 			log.Error().Msgf("Error saving meta file: %s", saveErr)
 		}
@@ -240,12 +195,57 @@ func MakeBillMeta(parentPath, billDirPath string) BillMeta {
 
 }
 
+// Gets the titles of the bill without the year (e.g. '... of 2019'), for the MetaIndex title index,
+// and the titles for the whole bill without the year, for the main title index.
+// The bill may have one or more of: OfficialTitle, PopularTitle, ShortTitle;
+// the short title is added to both lists and the official title to the main titles.
+func BillTitlesNoYear(billMeta BillMeta) (titlesNoYear []string, mainTitlesNoYear []string) {
+	officialTitle := billMeta.OfficialTitle
+	shortTitle := billMeta.ShortTitle
+	titles := billMeta.Titles
+	mainTitles := append([]string{}, billMeta.TitlesWholeBill...)
+
+	if officialTitle != "" {
+		mainTitles = RemoveDuplicates(append(mainTitles, officialTitle))
+	}
+
+	if shortTitle != "" {
+		mainTitles = RemoveDuplicates(append(mainTitles, shortTitle))
+		log.Debug().Msgf("Main Titles: %v", mainTitles)
+		// Add 	billMeta.ShortTitle to billMeta.Titles
+		titles = RemoveDuplicates(append(append([]string{}, billMeta.Titles...), shortTitle))
+		log.Debug().Msgf("Titles: %v", titles)
+	}
+
+	for _, title := range titles {
+		titlesNoYear = append(titlesNoYear, strings.Trim(TitleNoYearRegexCompiled.ReplaceAllString(title, ""), " "))
+	}
+	for _, title := range mainTitles {
+		mainTitlesNoYear = append(mainTitlesNoYear, strings.Trim(TitleNoYearRegexCompiled.ReplaceAllString(title, ""), " "))
+	}
+	return RemoveDuplicates(titlesNoYear), RemoveDuplicates(mainTitlesNoYear)
+}
+
 // Walks the 'congress' directory
 // Creates three metadata files: bills, titlesJson and billMeta
 // bills is the list of bill numbers (billCongressTypeNumber)
 // titles is a list of titles (no year)
 // billMeta collects metadata from data.json files
-func MakeBillsMeta(parentPath string) {
+// Returns a new MetaIndex with the metadata and title indexes
+func MakeBillsMeta(parentPath string) *MetaIndex {
+	return MakeBillsMetaToStore(parentPath, NewFileBillStore(parentPath))
+}
+
+// Same as MakeBillsMeta, but saves the metadata of each bill to the billStore
+func MakeBillsMetaToStore(parentPath string, billStore BillStore) *MetaIndex {
+	metaIndex := NewMetaIndex()
+	metaIndex.MakeBillsMeta(parentPath, billStore)
+	return metaIndex
+}
+
+// Adds the metadata of the bills in the 'congress' directory of the parentPath to the index, and their titles to the title indexes,
+// and saves the metadata of each bill to the billStore. If congresses are given (e.g. "116", "117"), only the bills of those congresses are processed.
+func (mi *MetaIndex) MakeBillsMeta(parentPath string, billStore BillStore, congresses ...string) {
 	//pathToBillMeta := BillMetaPath
 	pathToCongressDir := PathToCongressDataDir
 	if parentPath != "" {
@@ -259,7 +259,14 @@ func MakeBillsMeta(parentPath string) {
 	sem := make(chan bool, maxopenfiles)
 	billMetaStorageChannel := make(chan BillMeta)
 	log.Info().Msgf("Getting all files in %s.  This may take a while.", pathToCongressDir)
-	dataJsonFiles, _ := ListDataJsonFiles(pathToCongressDir)
+	var dataJsonFiles []string
+	if len(congresses) == 0 {
+		dataJsonFiles, _ = ListDataJsonFiles(pathToCongressDir)
+	}
+	for _, congress := range congresses {
+		congressDataJsonFiles, _ := ListDataJsonFiles(path.Join(pathToCongressDir, "data", congress))
+		dataJsonFiles = append(dataJsonFiles, congressDataJsonFiles...)
+	}
 	ReverseStrings(dataJsonFiles)
 	wg := &sync.WaitGroup{}
 	wg2 := &sync.WaitGroup{}
@@ -275,10 +282,15 @@ func MakeBillsMeta(parentPath string) {
 			}
 			billCounter++
 			log.Info().Msgf("[%d] Storing metadata for %s.", billCounter, billMeta.BillCongressTypeNumber)
+			// Adds the party and chamber of the sponsor and cosponsors, if the index has legislators
+			mi.Legislators.EnrichBillMeta(&billMeta)
+			// Adds the canonical names and jurisdiction of the committees, if the index has a committee registry
+			mi.Committees.EnrichBillMeta(&billMeta)
+			mi.CommitteeMembership.EnrichBillMeta(&billMeta)
 			// Get related bill data
-			BillMetaSyncMap.Store(billMeta.BillCongressTypeNumber, billMeta)
-			// Saves bill JSON to a file
-			WriteBillMetaFile(billMeta, parentPath)
+			mi.SetBillMeta(billMeta)
+			// Saves bill JSON to the store
+			WriteBillMetaToStore(billMeta, billStore)
 
 			/* Saves each bill JSON to an item in db
 			saveDbErr := SaveBillJsonToDB(billMeta.BillCongressTypeNumber, billMeta)
@@ -287,44 +299,8 @@ func MakeBillsMeta(parentPath string) {
 			}
 			*/
 
-			// The bill may have one or more of: OfficialTitle, PopularTitle, ShortTitle
-			officialTitle := billMeta.OfficialTitle
-			shortTitle := billMeta.ShortTitle
-			titles := billMeta.Titles
-			mainTitles := billMeta.TitlesWholeBill
-
-			if officialTitle != "" {
-				mainTitles = RemoveDuplicates(append(mainTitles, officialTitle))
-			}
-
-			if shortTitle != "" {
-				mainTitles = RemoveDuplicates(append(mainTitles, shortTitle))
-				log.Debug().Msgf("Main Titles: %v", mainTitles)
-				// Add 	billMeta.ShortTitle to billMeta.Titles
-				titles = RemoveDuplicates(append(billMeta.Titles, shortTitle))
-				log.Debug().Msgf("Titles: %v", titles)
-			}
-
-			for _, title := range titles {
-				//for _, title := range billMeta.Titles {
-				log.Info().Msgf("[%d] Getting titles for %s.", billCounter, billMeta.BillCongressTypeNumber)
-				titleNoYear := strings.Trim(TitleNoYearRegexCompiled.ReplaceAllString(title, ""), " ")
-				if titleBills, loaded := TitleNoYearSyncMap.LoadOrStore(titleNoYear, []string{billMeta.BillCongressTypeNumber}); loaded {
-					titleBills = RemoveDuplicates(append(titleBills.([]string), billMeta.BillCongressTypeNumber))
-					TitleNoYearSyncMap.Store(titleNoYear, titleBills)
-				}
-
-			}
-
-			for _, title := range mainTitles {
-				log.Info().Msgf("[%d] Getting main titles for %s.", billCounter, billMeta.BillCongressTypeNumber)
-				mainTitleNoYear := strings.Trim(TitleNoYearRegexCompiled.ReplaceAllString(title, ""), " ")
-				if mainTitleBills, loaded := MainTitleNoYearSyncMap.LoadOrStore(mainTitleNoYear, []string{billMeta.BillCongressTypeNumber}); loaded {
-					mainTitleBills = RemoveDuplicates(append(mainTitleBills.([]string), billMeta.BillCongressTypeNumber))
-					MainTitleNoYearSyncMap.Store(mainTitleNoYear, mainTitleBills)
-				}
-
-			}
+			log.Info().Msgf("[%d] Getting titles for %s.", billCounter, billMeta.BillCongressTypeNumber)
+			mi.AddBillTitles(billMeta)
 		}
 	}()
 
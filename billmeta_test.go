@@ -19,4 +19,4 @@ func TestMakeBillMeta(t *testing.T) {
 }
 */
 
-//TODO make tests for MakeBillsMeta, LoadTitles, LoadMainTitles
+//TODO make tests for MakeBillMeta (see metaindex_test.go for MakeBillsMeta, LoadTitles, LoadMainTitles)
@@ -0,0 +1,154 @@
+package bills
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var sampleBillMetaPaths = []string{
+	"samples/congress/data/116/bills/hr/hr1500/billMeta.json",
+	"samples/congress/data/117/bills/s/s100/billMeta.json",
+}
+
+func readSampleBillMeta(t *testing.T, billMetaPath string) (billMeta BillMeta) {
+	file, err := os.ReadFile(billMetaPath)
+	if err != nil {
+		t.Fatalf("Error reading sample billMeta: %v", err)
+	}
+	if err := json.Unmarshal(file, &billMeta); err != nil {
+		t.Fatalf("Error parsing sample billMeta: %v", err)
+	}
+	return billMeta
+}
+
+// Runs the same checks against each BillStore implementation
+func checkBillStore(t *testing.T, billStore BillStore) {
+	for _, billMetaPath := range sampleBillMetaPaths {
+		assert.Nil(t, billStore.PutBillMeta(readSampleBillMeta(t, billMetaPath)))
+	}
+	billNumbers, err := billStore.ListBillMeta()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"116hr1500", "117s100"}, billNumbers)
+
+	billMeta, err := billStore.GetBillMeta("116hr1500")
+	assert.Nil(t, err)
+	assert.Equal(t, "Consumers First Act", billMeta.ShortTitle)
+	_, err = billStore.GetBillMeta("116hr1")
+	assert.Equal(t, ErrNotFound, err)
+
+	billMetas, err := billStore.QueryBillMeta(BillMetaQuery{Congress: "116", CommitteeId: "HSBA"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(billMetas))
+	billMetas, err = billStore.QueryBillMeta(BillMetaQuery{Title: "consumers first act"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(billMetas))
+	billMetas, err = billStore.QueryBillMeta(BillMetaQuery{Congress: "117", CommitteeId: "HSBA"})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(billMetas))
+
+	similarBills := []byte(`{"116hr1500": [{"score": 1}]}`)
+	assert.Nil(t, billStore.PutBillData("116hr1500", EsSimilarBillsDictFile, similarBills))
+	data, err := billStore.GetBillData("116hr1500", EsSimilarBillsDictFile)
+	assert.Nil(t, err)
+	assert.JSONEq(t, string(similarBills), string(data))
+	billNumbers, err = billStore.ListBillData(EsSimilarBillsDictFile)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"116hr1500"}, billNumbers)
+	_, err = billStore.GetBillData("117s100", EsSimilarBillsDictFile)
+	assert.Equal(t, ErrNotFound, err)
+
+	titleIndex := map[string][]string{"Consumers First Act": {"116hr1500", "116s1"}, "Other Act": {"117s100"}}
+	assert.Nil(t, billStore.PutTitleIndex(TitleNoYearIndex, titleIndex))
+	storedIndex, err := billStore.GetTitleIndex(TitleNoYearIndex)
+	assert.Nil(t, err)
+	assert.Equal(t, titleIndex, storedIndex)
+	titleBills, err := billStore.QueryTitleIndex(TitleNoYearIndex, "Consumers First Act")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"116hr1500", "116s1"}, titleBills)
+	// Titles are compared by their normalized form
+	titleBills, err = billStore.QueryTitleIndex(TitleNoYearIndex, "The consumers first act of 2019")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"116hr1500", "116s1"}, titleBills)
+	_, err = billStore.QueryTitleIndex(TitleNoYearIndex, "No Such Act")
+	assert.Equal(t, ErrNotFound, err)
+	// Putting an index replaces it
+	assert.Nil(t, billStore.PutTitleIndex(TitleNoYearIndex, map[string][]string{"Other Act": {"117s100"}}))
+	_, err = billStore.QueryTitleIndex(TitleNoYearIndex, "Consumers First Act")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestFileBillStore(t *testing.T) {
+	log.Info().Msg("Test saving and querying bill data in the filesystem store")
+	testutils.SetLogLevel()
+	parentPath := t.TempDir()
+	for _, billDir := range []string{"116/bills/hr/hr1500", "117/bills/s/s100"} {
+		if err := os.MkdirAll(path.Join(parentPath, CongressDir, "data", billDir), 0755); err != nil {
+			t.Fatalf("Error making bill directory: %v", err)
+		}
+	}
+	billStore, err := OpenBillStore(BillStoreOptions{Type: BillStoreFS, ParentPath: parentPath})
+	assert.Nil(t, err)
+	defer billStore.Close()
+	checkBillStore(t, billStore)
+	_, err = os.Stat(path.Join(parentPath, CongressDir, "data", "116/bills/hr/hr1500", BillMetaJsonFile))
+	assert.Nil(t, err)
+
+	// Bill numbers that are not of the form 116hr1500 have no directory
+	for _, billNumber := range []string{"", "hr1500", "116hr1500ih", "116hr1500/../s100"} {
+		_, err = billStore.GetBillData(billNumber, BillMetaJsonFile)
+		assert.NotNil(t, err, billNumber)
+		assert.NotEqual(t, ErrNotFound, err, billNumber)
+		assert.NotNil(t, billStore.PutBillData(billNumber, BillMetaJsonFile, []byte("{}")), billNumber)
+	}
+}
+
+func TestBadgerBillStore(t *testing.T) {
+	log.Info().Msg("Test saving and querying bill data in the Badger store")
+	testutils.SetLogLevel()
+	billStore, err := OpenBillStore(BillStoreOptions{Type: BillStoreBadger, BadgerPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Error opening Badger store: %v", err)
+	}
+	defer billStore.Close()
+	checkBillStore(t, billStore)
+}
+
+// Set TEST_DATABASE_URL to a disposable database to run this test; the bill tables are emptied first
+func TestPgBillStore(t *testing.T) {
+	log.Info().Msg("Test saving and querying bill data in the Postgres store")
+	testutils.SetLogLevel()
+	databaseUrl := os.Getenv("TEST_DATABASE_URL")
+	if databaseUrl == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	billStore, err := OpenPgBillStore(databaseUrl)
+	if err != nil {
+		t.Fatalf("Error opening Postgres store: %v", err)
+	}
+	defer billStore.Close()
+	if _, err := billStore.Pool().Exec(context.Background(), "TRUNCATE bills, bill_data, title_index CASCADE"); err != nil {
+		t.Fatalf("Error emptying the tables: %v", err)
+	}
+	checkBillStore(t, billStore)
+}
+
+func TestPgBillMetaQuery(t *testing.T) {
+	log.Info().Msg("Test building the SQL for a bill metadata query")
+	testutils.SetLogLevel()
+	sql, args := pgBillMetaQuery(BillMetaQuery{})
+	assert.Equal(t, "SELECT meta FROM bills ORDER BY bill_number", sql)
+	assert.Equal(t, 0, len(args))
+	sql, args = pgBillMetaQuery(BillMetaQuery{Congress: "116", CommitteeId: "HSBA"})
+	assert.Equal(t, "SELECT meta FROM bills WHERE congress = $1 AND meta->'committees' @> $2::jsonb ORDER BY bill_number", sql)
+	assert.Equal(t, []interface{}{"116", `[{"committee_id":"HSBA"}]`}, args)
+	sql, args = pgBillMetaQuery(BillMetaQuery{Sponsor: "W000187", Subject: "Aging"})
+	assert.Equal(t, "SELECT meta FROM bills WHERE meta->'sponsor'->>'bioguide_id' = $1 AND (lower(meta->>'subjects_top_term') = lower($2) OR EXISTS (SELECT 1 FROM jsonb_array_elements_text(COALESCE(meta->'subjects', '[]'::jsonb)) AS s(subject) WHERE lower(s.subject) = lower($2))) ORDER BY bill_number", sql)
+	assert.Equal(t, []interface{}{"W000187", "Aging"}, args)
+}
@@ -3,13 +3,11 @@ package bills
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path"
 	"strings"
 
 	"github.com/rs/zerolog/log"
-	bh "github.com/timshannon/badgerhold"
 )
 
 // Update the 'related bills' list in BillJSON. For example, this is used to add similarity data to the bill json.
@@ -61,24 +59,21 @@ func SaveBillJson(billCongressTypeNumber string, billMetaItem BillMeta, parentPa
 	if marshalErr != nil {
 		return fmt.Errorf("error marshalling metadata for: %s\nErr: %s", billCongressTypeNumber, marshalErr)
 	}
-	SaveBillDataJson(billCongressTypeNumber, file, parentPath, "billMeta.json")
+	SaveBillDataJson(billCongressTypeNumber, file, parentPath, BillMetaJsonFile)
 	return nil
 }
 
-// Saves bill metadata to db (badger or bolt) via bh
+// Saves bill metadata to the Badger store in BadgerPathDefault.
+// This opens the store for each bill; to save many bills, use OpenBillStore and PutBillMeta.
 func SaveBillJsonToDB(billCongressTypeNumber string, billMetaItem BillMeta) error {
-	var options = bh.DefaultOptions
-	options.Dir = "data"
-	options.ValueDir = "data"
-
-	store, err := bh.Open(options)
+	store, err := OpenBadgerBillStore(BadgerPathDefault)
 	if err != nil {
-		// handle error
-		log.Fatal().Err(err)
+		return fmt.Errorf("error opening store for: %s\nErr: %s", billCongressTypeNumber, err)
 	}
 	defer store.Close()
 
-	return store.Insert(billCongressTypeNumber, billMetaItem)
+	billMetaItem.BillCongressTypeNumber = billCongressTypeNumber
+	return store.PutBillMeta(billMetaItem)
 }
 
 //similarSectionsStorageChannel := make(chan bills.SimilarSectionsItems)
@@ -88,8 +83,13 @@ func SaveBillJsonToDB(billCongressTypeNumber string, billMetaItem BillMeta) erro
 // TODO update billJSON with similarity information
 // TODO update billJSON with title information?
 
-// Saves Data in JSON to bill directory
+// Saves Data in JSON to bill directory. The file is replaced atomically (see WriteFileAtomic).
 func SaveBillDataJson(billCongressTypeNumber string, dataJson []byte, parentPath string, fileName string) (savePath string, err error) {
+	return SaveBillDataJsonToManifest(billCongressTypeNumber, dataJson, parentPath, fileName, nil)
+}
+
+// Same as SaveBillDataJson, and records the file in the manifest
+func SaveBillDataJsonToManifest(billCongressTypeNumber string, dataJson []byte, parentPath string, fileName string, manifest *Manifest) (savePath string, err error) {
 
 	dataPath, _ := PathFromBillNumber(billCongressTypeNumber)
 
@@ -103,7 +103,7 @@ func SaveBillDataJson(billCongressTypeNumber string, dataJson []byte, parentPath
 	defer func() {
 		log.Info().Msgf("Finished saving data for: %s; \n", billCongressTypeNumber)
 	}()
-	writeErr := ioutil.WriteFile(savePath, dataJson, 0644)
+	writeErr := manifest.WriteFile(savePath, dataJson, 0644)
 
 	if writeErr != nil {
 		log.Error().Msgf("error writing data to: %s\nErr: %s", savePath, writeErr)
@@ -113,20 +113,20 @@ func UnmarshalJsonFile(jpath string) (*sync.Map, error) {
 // Marshals a sync.Map object of the type map[string]BillMeta
 // see https://stackoverflow.com/a/46390611/628748
 // and https://stackoverflow.com/a/65442862/628748
-func MarshalJSONBillMeta(m *sync.Map) ([]byte, error) {
+func MarshalJSONBillMeta(metaIndex *MetaIndex) ([]byte, error) {
 	tmpMap := make(map[string]BillMeta)
-	m.Range(func(k interface{}, v interface{}) bool {
-		tmpMap[k.(string)] = v.(BillMeta)
+	metaIndex.RangeBillMeta(func(billNumber string, billMeta BillMeta) bool {
+		tmpMap[billNumber] = billMeta
 		return true
 	})
 	return json.Marshal(tmpMap)
 }
 
-func MarshalJSONBillSimilarity(m *sync.Map) ([]byte, error) {
+func MarshalJSONBillSimilarity(metaIndex *MetaIndex) ([]byte, error) {
 
 	tmpMap := make(map[string][]RelatedBillItem)
-	m.Range(func(k interface{}, v interface{}) bool {
-		tmpMap[k.(string)] = v.(BillMeta).RelatedBills
+	metaIndex.RangeBillMeta(func(billNumber string, billMeta BillMeta) bool {
+		tmpMap[billNumber] = billMeta.RelatedBills
 		return true
 	})
 	return json.Marshal(tmpMap)
@@ -177,6 +177,17 @@ func ExtractBillMeta(billPath string, billMetaStorageChannel chan BillMeta, sem
 	}
 	billMeta.BillCongressTypeNumber = billCongressTypeNumber
 	billMeta.Committees = dat.Committees
+	for _, subject := range dat.Subjects {
+		if subjectString, ok := subject.(string); ok {
+			billMeta.Subjects = append(billMeta.Subjects, subjectString)
+		}
+	}
+	billMeta.SubjectsTopTerm = dat.SubjectsTopTerm
+	billMeta.IntroducedAt = dat.IntroducedAt
+	billMeta.Sponsor = dat.Sponsor
+	if billMeta.Sponsor.SponsoredAt == "" {
+		billMeta.Sponsor.SponsoredAt = dat.IntroducedAt
+	}
 	billMeta.Cosponsors = dat.Cosponsors
 	billMeta.History = dat.History
 	billMeta.ShortTitle = dat.ShortTitle
@@ -0,0 +1,166 @@
+package bills
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// XPath expressions for the elements that are removed from the bill xml before making n-grams.
+	// These are the parts of a bill that are the same in many unrelated bills.
+	BoilerplateElements = []string{
+		"//toc",
+		"//enacting-clause",
+		"//attestation",
+		"//endorsement",
+		"//section[header='Short title' or header='Short title; table of contents']",
+		"//subsection[header='Short title']",
+	}
+	// Hashed n-grams (see HashShingle) that are not counted in similarity scores
+	// Set with LoadBoilerplateNgrams
+	BoilerplateNgrams = map[uint64]bool{}
+	// Default fraction of documents an n-gram must be in to be considered boilerplate
+	BoilerplateMinDocFraction = .2
+)
+
+// Removes the elements matching the xpath expressions from the bill xml,
+// and returns the remaining text (with the xml tags removed)
+func RemoveBoilerplateElements(file []byte, elements []string) (string, error) {
+	if len(elements) == 0 {
+		return removeXMLRegexCompiled.ReplaceAllString(string(file), " "), nil
+	}
+	doc, err := xmlquery.Parse(bytes.NewReader(file))
+	if err != nil {
+		return "", err
+	}
+	for _, element := range elements {
+		nodes, err := xmlquery.QueryAll(doc, element)
+		if err != nil {
+			return "", err
+		}
+		for _, node := range nodes {
+			xmlquery.RemoveFromTree(node)
+		}
+	}
+	return removeXMLRegexCompiled.ReplaceAllString(doc.OutputXML(false), " "), nil
+}
+
+// Sets the BoilerplateElements from a command-line option: an XPath expression for the elements to remove
+// (join several with '|', e.g. "//toc | //attestation"), or 'none' to remove no elements.
+// An empty expression keeps the default elements.
+func SetBoilerplateElements(xpathExpr string) error {
+	switch strings.TrimSpace(xpathExpr) {
+	case "":
+		return nil
+	case "none":
+		BoilerplateElements = []string{}
+		return nil
+	}
+	if _, err := xmlquery.QueryAll(&xmlquery.Node{Type: xmlquery.DocumentNode}, xpathExpr); err != nil {
+		return fmt.Errorf("invalid boilerplate elements %q: %s", xpathExpr, err)
+	}
+	BoilerplateElements = []string{xpathExpr}
+	return nil
+}
+
+// Returns the n-grams of a document, after removing the BoilerplateElements
+func boilerplateFreeNgramMap(file []byte, n int) map[string]int {
+	fileText, err := RemoveBoilerplateElements(file, BoilerplateElements)
+	if err != nil {
+		log.Error().Msgf("Error removing boilerplate elements: %s", err)
+		fileText = removeXMLRegexCompiled.ReplaceAllString(string(file), " ")
+	}
+	return MakeNgramMap(fileText, n)
+}
+
+// Finds the n-grams that occur in at least minDocFraction of the documents in docPaths.
+// These are typically boilerplate phrases (e.g. "Be it enacted by the Senate and House ...").
+// Returns the n-grams, sorted by the number of documents they occur in (highest first)
+func MakeBoilerplateNgrams(docPaths []string, n int, minDocFraction float64) (nGrams []string, err error) {
+	docFrequency := make(map[string]int)
+	for i, docPath := range docPaths {
+		log.Debug().Msgf("Getting Ngrams for file %d of %d", i+1, len(docPaths))
+		file, err := os.ReadFile(docPath)
+		if err != nil {
+			log.Error().Msgf("Error reading document: %s\n", err)
+			return nil, err
+		}
+		for nGram := range boilerplateFreeNgramMap(file, n) {
+			docFrequency[nGram]++
+		}
+	}
+	minDocs := int(minDocFraction * float64(len(docPaths)))
+	if minDocs < 2 {
+		minDocs = 2
+	}
+	for nGram, count := range docFrequency {
+		if count >= minDocs {
+			nGrams = append(nGrams, nGram)
+		}
+	}
+	sort.SliceStable(nGrams, func(i, j int) bool {
+		if docFrequency[nGrams[i]] == docFrequency[nGrams[j]] {
+			return nGrams[i] < nGrams[j]
+		}
+		return docFrequency[nGrams[i]] > docFrequency[nGrams[j]]
+	})
+	log.Info().Msgf("Found %d boilerplate n-grams in %d documents", len(nGrams), len(docPaths))
+	return nGrams, nil
+}
+
+// Sets the BoilerplateNgrams to the n-grams in a list
+func SetBoilerplateNgrams(nGrams []string) {
+	BoilerplateNgrams = make(map[uint64]bool, len(nGrams))
+	for _, nGram := range nGrams {
+		BoilerplateNgrams[HashShingle(strings.TrimSpace(nGram))] = true
+	}
+}
+
+// Loads a JSON list of boilerplate n-grams (as created by MakeBoilerplateNgrams) and sets BoilerplateNgrams
+func LoadBoilerplateNgrams(jpath string) error {
+	file, err := os.ReadFile(jpath)
+	if err != nil {
+		return err
+	}
+	var nGrams []string
+	if err := json.Unmarshal(file, &nGrams); err != nil {
+		return err
+	}
+	SetBoilerplateNgrams(nGrams)
+	log.Info().Msgf("Loaded %d boilerplate n-grams from %s", len(nGrams), jpath)
+	return nil
+}
+
+// Loads the boilerplate n-grams from the boilerplatePath or, if it is empty, from [parentPath]/boilerplateNgramsGo.json
+// if that file exists (as the boilerplate command saves it)
+func LoadBoilerplateNgramsDefault(parentPath string, boilerplatePath string) error {
+	if boilerplatePath == "" {
+		boilerplatePath = path.Join(parentPath, BoilerplateNgramsFile)
+		if _, err := os.Stat(boilerplatePath); err != nil {
+			return nil
+		}
+	}
+	return LoadBoilerplateNgrams(boilerplatePath)
+}
+
+// Returns a copy of the shingle map, without BoilerplateNgrams
+func FilterBoilerplateShingles(shingles map[uint64]int) map[uint64]int {
+	if len(BoilerplateNgrams) == 0 {
+		return shingles
+	}
+	filtered := make(map[uint64]int, len(shingles))
+	for shingle, count := range shingles {
+		if !BoilerplateNgrams[shingle] {
+			filtered[shingle] = count
+		}
+	}
+	return filtered
+}
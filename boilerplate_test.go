@@ -0,0 +1,86 @@
+package bills
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveBoilerplateElements(t *testing.T) {
+	log.Info().Msg("Test removing boilerplate elements from bill xml")
+	testutils.SetLogLevel()
+	file, err := os.ReadFile(sampleFilePath)
+	if err != nil {
+		t.Fatalf("Error reading sample file: %v", err)
+	}
+	fileText, err := RemoveBoilerplateElements(file, BoilerplateElements)
+	if err != nil {
+		t.Fatalf("Error removing boilerplate: %v", err)
+	}
+	// The table of contents and attestation are removed; the sections remain
+	assert.NotContains(t, fileText, "Sec. 12. Maintaining the HMDA Explorer tool")
+	assert.NotContains(t, fileText, "Passed the House of Representatives May 22, 2019.")
+	assert.Contains(t, fileText, "may not retire the HMDA Explorer tool")
+}
+
+func TestSetBoilerplateElements(t *testing.T) {
+	log.Info().Msg("Test setting the boilerplate elements from a command-line option")
+	testutils.SetLogLevel()
+	defaultElements := BoilerplateElements
+	defer func() { BoilerplateElements = defaultElements }()
+	assert.Nil(t, SetBoilerplateElements(""))
+	assert.Equal(t, defaultElements, BoilerplateElements)
+	assert.NotNil(t, SetBoilerplateElements("//toc["))
+	assert.Equal(t, defaultElements, BoilerplateElements)
+
+	file, err := os.ReadFile(sampleFilePath)
+	if err != nil {
+		t.Fatalf("Error reading sample file: %v", err)
+	}
+	// The table of contents separates the section number and header with en spaces
+	tocEntry := "Sec.\u200212.\u2002Maintaining the HMDA Explorer tool"
+	assert.Nil(t, SetBoilerplateElements("//toc | //enacting-clause"))
+	fileText, err := RemoveBoilerplateElements(file, BoilerplateElements)
+	assert.Nil(t, err)
+	assert.NotContains(t, fileText, tocEntry)
+	assert.Contains(t, fileText, "Passed the House of Representatives May 22, 2019.")
+	assert.Nil(t, SetBoilerplateElements("none"))
+	fileText, err = RemoveBoilerplateElements(file, BoilerplateElements)
+	assert.Nil(t, err)
+	assert.Contains(t, fileText, tocEntry)
+}
+
+func TestLoadBoilerplateNgramsDefault(t *testing.T) {
+	log.Info().Msg("Test loading the boilerplate n-grams from the parent path")
+	testutils.SetLogLevel()
+	defer SetBoilerplateNgrams(nil)
+	parentPath := t.TempDir()
+	// No file in the parent path is not an error
+	assert.Nil(t, LoadBoilerplateNgramsDefault(parentPath, ""))
+	assert.Equal(t, 0, len(BoilerplateNgrams))
+	assert.NotNil(t, LoadBoilerplateNgramsDefault(parentPath, path.Join(parentPath, "missing.json")))
+
+	assert.Nil(t, os.WriteFile(path.Join(parentPath, BoilerplateNgramsFile), []byte(`["be it enacted by the"]`), 0666))
+	assert.Nil(t, LoadBoilerplateNgramsDefault(parentPath, ""))
+	assert.True(t, BoilerplateNgrams[HashShingle("be it enacted by the")])
+}
+
+func TestBoilerplateNgrams(t *testing.T) {
+	log.Info().Msg("Test finding and filtering boilerplate n-grams")
+	testutils.SetLogLevel()
+	defer SetBoilerplateNgrams(nil)
+	docPaths := append(documentXMLFilesSample, "samples/congress/data/115/bills/hr/hr6972/text-versions/ih/document.xml")
+	nGrams, err := MakeBoilerplateNgrams(docPaths, NgramSize, .8)
+	if err != nil {
+		t.Fatalf("Error getting boilerplate n-grams: %v", err)
+	}
+	assert.Greater(t, len(nGrams), 0)
+
+	SetBoilerplateNgrams(nGrams)
+	shingles := ShingleMap(map[string]int{nGrams[0]: 1, "not a boilerplate ngram": 2})
+	assert.Equal(t, map[uint64]int{HashShingle("not a boilerplate ngram"): 2}, FilterBoilerplateShingles(shingles))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/aih/bills"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Read-only REST API for the bill metadata and the similarity results written by billmeta and esquery
+// (see bills.ApiServer for the endpoints). Reads from the output files in the parentPath, or from a store.
+// e.g. curl 'http://localhost:8080/bills?sponsor=W000187&limit=10'
+func main() {
+	debug := flag.Bool("debug", false, "sets log level to debug")
+
+	flagPathUsage := "Absolute path to the parent directory for 'congress' and json metadata files"
+	var parentPath string
+	flag.StringVar(&parentPath, "parentPath", string(bills.ParentPathDefault), flagPathUsage)
+	flag.StringVar(&parentPath, "p", string(bills.ParentPathDefault), flagPathUsage+" (shorthand)")
+
+	var addr string
+	flag.StringVar(&addr, "addr", ":8080", "address to listen on")
+
+	var storeOptions bills.BillStoreOptions
+	flag.StringVar(&storeOptions.Type, "store", bills.BillStoreFS, "Where to read the metadata. Options: "+strings.Join(bills.BillStoreTypes, ", "))
+	flag.StringVar(&storeOptions.BadgerPath, "badgerPath", bills.BadgerPathDefault, "Directory of the Badger database, for -store badger")
+	flag.StringVar(&storeOptions.DatabaseUrl, "databaseUrl", "", "Postgres url, for -store postgres (default: DATABASE_URL from the environment or .env)")
+
+	flag.Parse()
+
+	// Default level for this example is info, unless debug flag is present
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	if *debug {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	}
+
+	// UNIX Time is faster and smaller than most timestamps
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	log.Debug().Msg("Log level set to Debug")
+
+	storeOptions.ParentPath = parentPath
+	billStore, err := bills.OpenBillStore(storeOptions)
+	if err != nil {
+		log.Fatal().Msgf("Error opening %s store: %s", storeOptions.Type, err)
+	}
+	defer billStore.Close()
+
+	apiServer := bills.NewApiServer(billStore, path.Join(parentPath, bills.CongressDir, "data"))
+	log.Info().Msgf("Serving the bills API from the %s store on %s", storeOptions.Type, addr)
+	if err := http.ListenAndServe(addr, apiServer.Handler()); err != nil {
+		log.Fatal().Msgf("Error running server: %s", err)
+	}
+}
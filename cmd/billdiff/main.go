@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aih/bills"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// BillList is a string slice
+type BillList []string
+
+func (bl *BillList) String() string {
+	return fmt.Sprintln(*bl)
+}
+
+// Set string value in MyList
+func (bl *BillList) Set(s string) error {
+	*bl = strings.Split(s, ",")
+	return nil
+}
+
+// Command-line tool to show what changed between two versions of a bill, e.g.
+// billdiff -p ../../../congress/data -b 116hr1500ih,116hr1500eh -format text
+func main() {
+
+	debug := flag.Bool("debug", false, "sets log level to debug")
+
+	// Default level for this example is info, unless debug flag is present
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+	// UNIX Time is faster and smaller than most timestamps
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+
+	flagPathUsage := "Absolute path to the parent directory for 'congress' and json metadata files"
+	flagPathValue := string(bills.ParentPathDefault)
+	var parentPath string
+	flag.StringVar(&parentPath, "parentPath", flagPathValue, flagPathUsage)
+	flag.StringVar(&parentPath, "p", flagPathValue, flagPathUsage+" (shorthand)")
+
+	var billList BillList
+	flag.Var(&billList, "billnumbers", "comma-separated list of two billnumber versions, from and to (e.g. 116hr1500ih,116hr1500eh)")
+	flag.Var(&billList, "b", "comma-separated list of two billnumber versions (shorthand)")
+
+	var absPathList string
+	flag.StringVar(&absPathList, "abspaths", "", "comma-separated list of absolute paths to two bill xml files")
+
+	var format string
+	flag.StringVar(&format, "format", bills.BillDiffFormatJSON, "output format. Options: "+strings.Join(bills.BillDiffFormats, ", "))
+	flag.Parse()
+	if *debug {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	}
+	log.Debug().Msg("Log level set to Debug")
+
+	if _, ok := bills.Find(bills.BillDiffFormats, format); !ok {
+		log.Fatal().Msgf("Unknown -format %s (options: %s)", format, strings.Join(bills.BillDiffFormats, ", "))
+	}
+
+	var billDiff bills.BillDiff
+	var err error
+	if absPathList != "" {
+		absPathListSlice := strings.Split(absPathList, ",")
+		if len(absPathListSlice) != 2 {
+			log.Fatal().Msg("Two paths are required to compare bills")
+		}
+		billDiff, err = bills.DiffBillsFromPaths(strings.TrimSpace(absPathListSlice[0]), strings.TrimSpace(absPathListSlice[1]))
+	} else {
+		if len(billList) != 2 {
+			log.Fatal().Msg("Two bill number versions are required to compare bills")
+		}
+		billDiff, err = bills.DiffBills(parentPath, strings.TrimSpace(billList[0]), strings.TrimSpace(billList[1]))
+	}
+	if err != nil {
+		log.Fatal().Msgf("Error comparing bills: %s", err)
+	}
+
+	if err := bills.WriteBillDiff(os.Stdout, billDiff, format); err != nil {
+		log.Fatal().Msgf("Error writing the diff: %s", err)
+	}
+}
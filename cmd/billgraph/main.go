@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aih/bills"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Command-line tool to build the graph of related bills (from relatedDict.json and esSimilarCategory.json of each bill)
+// for a range of congresses, and export or query it.
+// With no query, writes the whole graph in the -format (json, graphml or dot). Queries print JSON:
+// -components for the groups of connected bills, -path 116hr133,116hr7617 for the shortest chain of related bills
+// and -incorporatedInto 116hr133 for the bills incorporated into an (enrolled) bill.
+// -component 116hr133 exports only the bills connected to a bill.
+func main() {
+	debug := flag.Bool("debug", false, "sets log level to debug")
+	components := flag.Bool("components", false, "print the groups of connected bills, largest first")
+
+	flagPathUsage := "Absolute path to the parent directory for 'congress' and json metadata files"
+	var parentPath string
+	flag.StringVar(&parentPath, "parentPath", string(bills.ParentPathDefault), flagPathUsage)
+	flag.StringVar(&parentPath, "p", string(bills.ParentPathDefault), flagPathUsage+" (shorthand)")
+
+	var storeOptions bills.BillStoreOptions
+	flag.StringVar(&storeOptions.Type, "store", bills.BillStoreFS, "Where to read the metadata. Options: "+strings.Join(bills.BillStoreTypes, ", "))
+	flag.StringVar(&storeOptions.BadgerPath, "badgerPath", bills.BadgerPathDefault, "Directory of the Badger database, for -store badger")
+	flag.StringVar(&storeOptions.DatabaseUrl, "databaseUrl", "", "Postgres url, for -store postgres (default: DATABASE_URL from the environment or .env)")
+
+	var fromCongress, toCongress int
+	flag.IntVar(&fromCongress, "from", 0, "first congress to load (0 for no limit)")
+	flag.IntVar(&toCongress, "to", 0, "last congress to load (0 for no limit)")
+
+	var format, billPath, incorporatedInto, component string
+	flag.StringVar(&format, "format", bills.GraphFormatJSON, "output format for the graph. Options: "+strings.Join(bills.GraphFormats, ", "))
+	flag.StringVar(&billPath, "path", "", "two bill numbers, separated by a comma; prints the shortest path between them")
+	flag.StringVar(&incorporatedInto, "incorporatedInto", "", "prints the bills incorporated into this bill (e.g. 116hr133)")
+	flag.StringVar(&component, "component", "", "exports only the bills connected to this bill")
+
+	flag.Parse()
+
+	// Default level for this example is info, unless debug flag is present
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	if *debug {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	}
+
+	// UNIX Time is faster and smaller than most timestamps
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	log.Debug().Msg("Log level set to Debug")
+
+	validFormat := false
+	for _, graphFormat := range bills.GraphFormats {
+		validFormat = validFormat || format == graphFormat
+	}
+	if !validFormat {
+		log.Fatal().Msgf("Unknown -format: %s (options: %s)", format, strings.Join(bills.GraphFormats, ", "))
+	}
+
+	storeOptions.ParentPath = parentPath
+	billStore, err := bills.OpenBillStore(storeOptions)
+	if err != nil {
+		log.Fatal().Msgf("Error opening %s store: %s", storeOptions.Type, err)
+	}
+	defer billStore.Close()
+
+	graph, err := bills.LoadBillGraph(billStore, fromCongress, toCongress)
+	if err != nil {
+		log.Fatal().Msgf("Error loading the graph: %s", err)
+	}
+	log.Info().Msgf("Loaded %d bills and %d edges", len(graph.Nodes), len(graph.Edges()))
+
+	var result interface{}
+	switch {
+	case *components:
+		result = graph.ConnectedComponents()
+	case billPath != "":
+		billNumbers := strings.Split(billPath, ",")
+		if len(billNumbers) != 2 {
+			log.Fatal().Msgf("-path needs two bill numbers, separated by a comma: %s", billPath)
+		}
+		result = graph.ShortestPath(strings.TrimSpace(billNumbers[0]), strings.TrimSpace(billNumbers[1]))
+	case incorporatedInto != "":
+		result = graph.IncorporatedInto(incorporatedInto)
+	default:
+		if component != "" {
+			if _, ok := graph.Nodes[component]; !ok {
+				log.Fatal().Msgf("Bill %s is not in the graph", component)
+			}
+			for _, billNumbers := range graph.ConnectedComponents() {
+				for _, billNumber := range billNumbers {
+					if billNumber == component {
+						graph = graph.Subgraph(billNumbers)
+						break
+					}
+				}
+			}
+		}
+		if err := bills.WriteBillGraph(os.Stdout, graph, format); err != nil {
+			log.Fatal().Msgf("Error writing the graph: %s", err)
+		}
+		return
+	}
+	resultJson, _ := json.MarshalIndent(result, "", " ")
+	fmt.Println(string(resultJson))
+}
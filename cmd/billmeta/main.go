@@ -2,10 +2,11 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
 	"path"
+	"strconv"
 	"strings"
-	"sync"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -18,23 +19,15 @@ type flagDef struct {
 	usage string
 }
 
-// Gets keys of a sync.Map
-func getSyncMapKeys(m *sync.Map) (s string) {
-	m.Range(func(k, v interface{}) bool {
-		if s != "" {
-			s += ", "
-		}
-		s += k.(string)
-		return true
-	})
-	return
-}
-
 // Command-line function to process and save metadata, with flags for paths.
 // Walks the 'congress' directory of the `parentPath`. Runs the following:
-// bills.MakeBillsMeta(parentPath) to create bill metadata and store it in a sync file and JSON files for: bills, titlesJson and billMeta
-// bills.LoadTitles(bills.TitleNoYearSyncMap, bills.BillMetaSyncMap) to create an index of bill titles without year info
-// loadMainTitles(bills.MainTitleNoYearSyncMap, bills.BillMetaSyncMap) to create an index of main bill titles without year info
+// metaIndex.MakeBillsMeta(parentPath, billStore) to create bill metadata in a bills.MetaIndex and save it to the store
+// (with -legislatorsPath, adding the party and chamber of the sponsor and cosponsors, and with -committeesPath,
+// the names and jurisdiction of the committees; with -committeeMembershipPath, the cosponsors on the committees of referral)
+// metaIndex.LoadTitles() to add the bills with the same title (without year info) to the related bills
+// metaIndex.LoadMainTitles() to add the bills with the same main title (without year info) to the related bills
+// metaIndex.LoadSimilarTitles(matcher), with -titleSimilarity, to add the bills with similar titles to the related bills
+// metaIndex.LoadReintroductions(detector), with -reintroductions, to find the bill that each bill reintroduces from the previous two congresses
 // bills.WriteBillMetaFiles writes `billMeta.json` in each bill directory
 // and then finally writes the whole meta sync file to a single JSON file, billMetaGo.json
 
@@ -44,18 +37,27 @@ func main() {
 	// Ensure we exit with an error code and log message
 	// when needed after deferred cleanups have run.
 	// Credit: https://medium.com/@matryer/golang-advent-calendar-day-three-fatally-exiting-a-command-line-tool-with-grace-874befeb64a4
+	// Errors are logged where they happen; setting err exits with status 1
 	var err error
 	defer func() {
 		if err != nil {
-			log.Fatal()
+			os.Exit(1)
 		}
 	}()
 
 	flagDefs := map[string]flagDef{
-		"billMetaPath": {string(bills.BillMetaPath), "Absolute path to store the bill json metadata file"},
-		"parentPath":   {string(bills.ParentPathDefault), "Absolute path to the parent directory for 'congress' and json metadata files"},
-		"billNumber":   {"", "Get and print billMeta for one bill"},
-		"log":          {"Info", "Sets Log level. Options: Error, Info, Debug"},
+		"billMetaPath":       {string(bills.BillMetaPath), "Absolute path to store the bill json metadata file"},
+		"parentPath":         {string(bills.ParentPathDefault), "Absolute path to the parent directory for 'congress' and json metadata files"},
+		"billNumber":         {"", "Get and print billMeta for one bill"},
+		"log":                {"Info", "Sets Log level. Options: Error, Info, Debug"},
+		"store":              {bills.BillStoreFS, "Where to save the metadata. Options: " + strings.Join(bills.BillStoreTypes, ", ")},
+		"badgerPath":         {bills.BadgerPathDefault, "Directory of the Badger database, for -store badger"},
+		"databaseUrl":        {"", "Postgres url, for -store postgres (default: DATABASE_URL from the environment or .env)"},
+		"committees":         {"", "Path to committees.yaml (e.g. tmp/committees.yaml), to add the names and jurisdiction of the committees"},
+		"membership":         {"", "Path to committee-membership-current.yaml (e.g. tmp/committee-membership.yaml), to add the cosponsors on the committees of referral"},
+		"titleNormalization": {strings.Join(bills.DefaultTitleNormalizeSteps, ","), "Steps to normalize titles for the title indexes, separated by commas, or 'none' to match only titles that are the same without the year. Options: " + strings.Join(bills.TitleNormalizeSteps, ", ")},
+		"titleSimilarity":    {"0", "Minimum share of words in common for titles to be similar (e.g. 0.8), to add the bills with similar titles to the related bills (bills-title_similar); 0 does not match similar titles"},
+		"legislators":        {"", "Paths to legislators yaml files, separated by commas (e.g. tmp/legislators.yaml,tmp/legislators-historical.yaml), to add the party and chamber of sponsors and cosponsors"},
 	}
 
 	// Default level for this example is info, unless debug flag is present
@@ -71,6 +73,7 @@ func main() {
 	flag.StringVar(&parentPath, "p", flagDefs["parentPath"].value, flagDefs["parentPath"].usage+" (shorthand)")
 	flag.StringVar(&pathToBillMeta, "billMetaPath", flagDefs["billMetaPath"].value, flagDefs["billMetaPath"].usage)
 	debug := flag.Bool("debug", false, "sets log level to debug")
+	reintroductions := flag.Bool("reintroductions", false, "find the bill in the previous two congresses that each bill reintroduces, by title, sponsor and text")
 
 	var logLevel string
 	flag.StringVar(&logLevel, "logLevel", flagDefs["log"].value, flagDefs["log"].usage)
@@ -79,6 +82,22 @@ func main() {
 	var billNumber string
 	flag.StringVar(&billNumber, "billNumber", flagDefs["billNumber"].value, flagDefs["billNumber"].usage)
 
+	var storeOptions bills.BillStoreOptions
+	flag.StringVar(&storeOptions.Type, "store", flagDefs["store"].value, flagDefs["store"].usage)
+	flag.StringVar(&storeOptions.BadgerPath, "badgerPath", flagDefs["badgerPath"].value, flagDefs["badgerPath"].usage)
+	flag.StringVar(&storeOptions.DatabaseUrl, "databaseUrl", flagDefs["databaseUrl"].value, flagDefs["databaseUrl"].usage)
+
+	var legislatorsPath string
+	flag.StringVar(&legislatorsPath, "legislatorsPath", flagDefs["legislators"].value, flagDefs["legislators"].usage)
+	var titleNormalization string
+	flag.StringVar(&titleNormalization, "titleNormalization", flagDefs["titleNormalization"].value, flagDefs["titleNormalization"].usage)
+	var titleSimilarity string
+	flag.StringVar(&titleSimilarity, "titleSimilarity", flagDefs["titleSimilarity"].value, flagDefs["titleSimilarity"].usage)
+	var committeesPath string
+	flag.StringVar(&committeesPath, "committeesPath", flagDefs["committees"].value, flagDefs["committees"].usage)
+	var membershipPath string
+	flag.StringVar(&membershipPath, "committeeMembershipPath", flagDefs["membership"].value, flagDefs["membership"].usage)
+
 	flag.Parse()
 
 	zLogLevel := bills.ZLogLevels[logLevel]
@@ -92,32 +111,132 @@ func main() {
 	}
 	log.Debug().Msg("Log level set to Debug")
 
+	storeOptions.ParentPath = parentPath
+	runId := bills.NewRunId()
+	if storeOptions.Type == bills.BillStoreFS {
+		// Records the files written in this run, for the verify command
+		var manifest *bills.Manifest
+		manifest, err = bills.OpenManifest(parentPath)
+		if err != nil {
+			log.Error().Msgf("Error opening manifest: %s", err)
+			return
+		}
+		defer manifest.Close()
+		storeOptions.Manifest = manifest
+		runId = manifest.RunId
+	}
+	billStore, err := bills.OpenBillStore(storeOptions)
+	if err != nil {
+		log.Error().Msgf("Error opening %s store: %s", storeOptions.Type, err)
+		return
+	}
+	defer billStore.Close()
+
+	var legislators *bills.LegislatorIndex
+	if legislatorsPath != "" {
+		legislators, err = bills.ReadLegislatorIndex(strings.Split(legislatorsPath, ",")...)
+		if err != nil {
+			log.Error().Msgf("Error reading legislators from %s: %s", legislatorsPath, err)
+			return
+		}
+		log.Info().Msgf("Read %d legislators", legislators.Len())
+	}
+
+	titleNormalizer := bills.DefaultTitleNormalizer
+	if titleNormalization == "none" {
+		titleNormalizer = nil
+	} else {
+		titleNormalizer, err = bills.NewTitleNormalizer(strings.Split(titleNormalization, ",")...)
+		if err != nil {
+			log.Error().Msgf("Error in -titleNormalization: %s", err)
+			return
+		}
+	}
+
+	minTitleSimilarity, err := strconv.ParseFloat(titleSimilarity, 64)
+	if err != nil || minTitleSimilarity < 0 || minTitleSimilarity > 1 {
+		log.Error().Msgf("Error in -titleSimilarity: %s is not a number from 0 to 1", titleSimilarity)
+		if err == nil {
+			err = fmt.Errorf("invalid -titleSimilarity: %s", titleSimilarity)
+		}
+		return
+	}
+
+	var committees *bills.CommitteeRegistry
+	if committeesPath != "" {
+		committees, err = bills.ReadCommitteeRegistry(committeesPath)
+		if err != nil {
+			log.Error().Msgf("Error reading committees from %s: %s", committeesPath, err)
+			return
+		}
+		log.Info().Msgf("Read %d committees", committees.Len())
+	}
+
+	var membership *bills.CommitteeMembership
+	if membershipPath != "" {
+		membership, err = bills.ReadCommitteeMembership(membershipPath, legislators)
+		if err != nil {
+			log.Error().Msgf("Error reading committee membership from %s: %s", membershipPath, err)
+			return
+		}
+	}
+
 	// Processes a single bill, based on the bill number
 	if billNumber != "" {
-		billPath, err := bills.PathFromBillNumber(billNumber)
+		var billPath string
+		billPath, err = bills.PathFromBillNumber(billNumber)
 		if err != nil {
 			log.Error().Msgf("Error getting path from billnumber: %s", billNumber)
 			return
 		}
 		billPath = strings.ReplaceAll(billPath, "/text-versions", "")
 		billMeta := bills.MakeBillMeta(parentPath, billPath)
+		legislators.EnrichBillMeta(&billMeta)
+		committees.EnrichBillMeta(&billMeta)
+		membership.EnrichBillMeta(&billMeta)
 		log.Debug().Msgf("billMeta: %v/n", billMeta)
-		bills.WriteBillMetaFile(billMeta, parentPath)
+		bills.WriteBillMetaToStore(billMeta, billStore)
+		if err = bills.UpdateBillTitleIndexes(billStore, billMeta); err != nil {
+			log.Error().Msgf("Error updating title indexes for %s: %s", billNumber, err)
+		}
 		return
 	}
 
 	// Wait until MakeBillsMeta is done until moving on to the next steps
-	bills.MakeBillsMeta(parentPath)
-	bills.LoadTitles(bills.TitleNoYearSyncMap, bills.BillMetaSyncMap)
-	bills.LoadMainTitles(bills.MainTitleNoYearSyncMap, bills.BillMetaSyncMap)
-	billlist := getSyncMapKeys(bills.BillMetaSyncMap)
-	log.Debug().Msgf("BillMetaSyncMap keys: %v", billlist)
-	log.Info().Msgf("BillMetaSyncMap length (number of bills processed): %v", len(strings.Split(billlist, ", ")))
-	bills.WriteRelatedDictFiles(bills.BillMetaSyncMap, parentPath)
+	metaIndex := bills.NewMetaIndex()
+	metaIndex.Legislators = legislators
+	metaIndex.Committees = committees
+	metaIndex.CommitteeMembership = membership
+	metaIndex.TitleNormalizer = titleNormalizer
+	metaIndex.RunId = runId
+	metaIndex.MakeBillsMeta(parentPath, billStore)
+	titleStats, mainTitleStats := metaIndex.TitleMergeStats()
+	for _, stats := range []bills.TitleMergeStats{titleStats, mainTitleStats} {
+		log.Info().Msgf("%s: %d titles in %d entries (%d merged by normalization)", stats.Index, stats.Titles, stats.Buckets, stats.Merged)
+	}
+	metaIndex.LoadTitles()
+	metaIndex.LoadMainTitles()
+	if minTitleSimilarity > 0 {
+		titleMatcher := bills.NewTitleMatcher()
+		titleMatcher.MinTokenSimilarity = minTitleSimilarity
+		if titleNormalizer != nil {
+			titleMatcher.Normalizer = titleNormalizer
+		}
+		metaIndex.LoadSimilarTitles(titleMatcher)
+	}
+	if *reintroductions {
+		metaIndex.LoadReintroductions(bills.NewReintroductionDetector(path.Join(parentPath, bills.CongressDir, "data")))
+	}
+	log.Debug().Msgf("MetaIndex bills: %v", metaIndex.BillNumbers())
+	log.Info().Msgf("MetaIndex length (number of bills processed): %v", metaIndex.Len())
+	bills.WriteRelatedDictsToStore(metaIndex, billStore)
+	if *reintroductions {
+		bills.WriteReintroductionsToStore(metaIndex, billStore)
+	}
 	/*
 
 			Do not store all of the data in one file; instead, store each map in the directory for that bill
-			bills.WriteBillMetaFiles(bills.BillMetaSyncMap, parentPath)
+			bills.WriteBillMetaFiles(metaIndex, parentPath)
 			log.Info().Msgf("pathToBillMeta: %v", pathToBillMeta)
 			if pathToBillMeta == "" {
 			if parentPath != "" {
@@ -126,16 +245,15 @@ func main() {
 				pathToBillMeta = bills.BillMetaPath
 			}
 		}
-			log.Info().Msg("Creating string from  billMetaSyncMap")
-			jsonString, err := bills.MarshalJSONBillMeta(bills.BillMetaSyncMap)
+			log.Info().Msg("Creating string from metaIndex")
+			jsonString, err := bills.MarshalJSONBillMeta(metaIndex)
 			if err != nil {
 				log.Error().Msgf("Error making JSON data for billMetaMap: %s", err)
 			}
 			log.Info().Msgf("Writing billMeta JSON data to file: %v", pathToBillMeta)
 			os.WriteFile(pathToBillMeta, []byte(jsonString), 0666)
 
-			//log.Debug().Msgf("BillMetaSyncMap: %v", bills.BillMetaSyncMap)
-			billslist := bills.GetSyncMapKeys(bills.BillMetaSyncMap)
+			billslist := metaIndex.BillNumbers()
 			billsString, err := json.Marshal(billslist)
 			log.Debug().Msgf("Bills: %s", billsString)
 			if err != nil {
@@ -147,7 +265,7 @@ func main() {
 	*/
 
 	/*
-		jsonSimString, err := bills.MarshalJSONBillSimilarity(bills.BillMetaSyncMap)
+		jsonSimString, err := bills.MarshalJSONBillSimilarity(metaIndex)
 		if err != nil {
 			log.Error().Msgf("Error making JSON data for billSimilarity file: %s", err)
 		}
@@ -156,19 +274,14 @@ func main() {
 		os.WriteFile(currentBillSimilarityPath, []byte(jsonSimString), 0666)
 	*/
 
-	jsonTitleNoYearString, err := bills.MarshalJSONStringArray(bills.TitleNoYearSyncMap)
-	if err != nil {
-		log.Error().Msgf("Error making JSON data for TitleNoYearSyncMap: %s", err)
+	log.Info().Msgf("Saving %s to the %s store", bills.TitleNoYearIndex, storeOptions.Type)
+	if err = billStore.PutTitleIndex(bills.TitleNoYearIndex, metaIndex.TitleIndex()); err != nil {
+		log.Error().Msgf("Error saving the title index: %s", err)
+		return
 	}
-	currentTitleNoYearIndexPath := path.Join(parentPath, bills.TitleNoYearIndex)
-	log.Info().Msgf("Writing titleNoYearIndex JSON data to: %s", currentTitleNoYearIndexPath)
-	os.WriteFile(currentTitleNoYearIndexPath, []byte(jsonTitleNoYearString), 0666)
-	jsonMainTitleNoYearString, err := bills.MarshalJSONStringArray(bills.MainTitleNoYearSyncMap)
-
-	if err != nil {
-		log.Error().Msgf("Error making JSON data for MainTitleNoYearMap: %s", err)
+	log.Info().Msgf("Saving %s to the %s store", bills.MainTitleNoYearIndex, storeOptions.Type)
+	if err = billStore.PutTitleIndex(bills.MainTitleNoYearIndex, metaIndex.MainTitleIndex()); err != nil {
+		log.Error().Msgf("Error saving the main title index: %s", err)
+		return
 	}
-	currentMainTitleNoYearIndexPath := path.Join(parentPath, bills.MainTitleNoYearIndex)
-	log.Info().Msgf("Writing maintitleNoYearIndex JSON data to : %s", currentMainTitleNoYearIndexPath)
-	os.WriteFile(currentMainTitleNoYearIndexPath, []byte(jsonMainTitleNoYearString), 0666)
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path"
+
+	"github.com/aih/bills"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Command-line tool to find the n-grams that are in many bills (e.g. enacting clauses and other boilerplate).
+// Walks the 'congress' directory of the `parentPath` and writes the list of n-grams to boilerplateNgramsGo.json.
+// comparematrix and esquery load this list and exclude these n-grams from similarity scores.
+func main() {
+	debug := flag.Bool("debug", false, "sets log level to debug")
+
+	flagPathUsage := "Absolute path to the parent directory for 'congress' and json metadata files"
+	var parentPath string
+	flag.StringVar(&parentPath, "parentPath", string(bills.ParentPathDefault), flagPathUsage)
+	flag.StringVar(&parentPath, "p", string(bills.ParentPathDefault), flagPathUsage+" (shorthand)")
+
+	var boilerplatePath string
+	flag.StringVar(&boilerplatePath, "boilerplatePath", "", "Path to save the boilerplate n-grams (default: [parentPath]/"+bills.BoilerplateNgramsFile+")")
+
+	var minDocFraction float64
+	flag.Float64Var(&minDocFraction, "minDocFraction", bills.BoilerplateMinDocFraction, "minimum fraction of bills an n-gram must be in to be considered boilerplate")
+
+	flag.Parse()
+
+	// Default level for this example is info, unless debug flag is present
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	if *debug {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	}
+
+	// UNIX Time is faster and smaller than most timestamps
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	log.Debug().Msg("Log level set to Debug")
+
+	if boilerplatePath == "" {
+		boilerplatePath = path.Join(parentPath, bills.BoilerplateNgramsFile)
+	}
+
+	documentXMLFiles, err := bills.ListDocumentXMLFiles(path.Join(parentPath, bills.CongressDir))
+	if err != nil {
+		log.Fatal().Msgf("Error getting document.xml files: %s", err)
+	}
+	nGrams, err := bills.MakeBoilerplateNgrams(documentXMLFiles, bills.NgramSize, minDocFraction)
+	if err != nil {
+		log.Fatal().Msgf("Error getting boilerplate n-grams: %s", err)
+	}
+	nGramsJson, err := json.MarshalIndent(nGrams, "", " ")
+	if err != nil {
+		log.Fatal().Msgf("Error making JSON data for boilerplate n-grams: %s", err)
+	}
+	log.Info().Msgf("Writing boilerplate n-grams to: %s", boilerplatePath)
+	if err := os.WriteFile(boilerplatePath, nGramsJson, 0666); err != nil {
+		log.Fatal().Msgf("Error writing boilerplate n-grams: %s", err)
+	}
+}
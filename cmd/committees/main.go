@@ -1,7 +1,9 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
 	"os"
 
 	"github.com/aih/bills"
@@ -9,8 +11,12 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// Command-line tool to download committees.yaml (and, with -membership, the committee members) to the -cacheDir.
+// Files that have not changed upstream are not downloaded again. Prints the path and sha256 checksum of each file as JSON.
 func main() {
 	debug := flag.Bool("debug", false, "sets log level to debug")
+	membership := flag.Bool("membership", false, "also download committee-membership-current.yaml to "+bills.CommitteeMembershipYamlFile)
+	flag.StringVar(&bills.ReferenceCacheDir, "cacheDir", bills.ReferenceCacheDir, "directory for the downloaded files")
 
 	flag.Parse()
 
@@ -24,5 +30,20 @@ func main() {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 	log.Debug().Msg("Log level set to Debug")
-	bills.DownloadCommitteesYaml()
+
+	var results []bills.FetchResult
+	result, err := bills.DownloadCommitteesYaml()
+	if err != nil {
+		log.Fatal().Msgf("Error downloading committees: %s", err)
+	}
+	results = append(results, result)
+	if *membership {
+		result, err := bills.DownloadCommitteeMembershipYaml()
+		if err != nil {
+			log.Fatal().Msgf("Error downloading committee membership: %s", err)
+		}
+		results = append(results, result)
+	}
+	resultsJson, _ := json.MarshalIndent(results, "", " ")
+	fmt.Println(string(resultsJson))
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/aih/bills"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Long-running comparison service. Keeps the n-grams of bills in memory and compares bills over HTTP/JSON.
+// To call from Python:
+// import requests
+// requests.post('http://localhost:8080/compare', json={'bills': ['116hr1500rh', '115hr6972ih']}).json()['compare_matrix']
+func main() {
+	debug := flag.Bool("debug", false, "sets log level to debug")
+	noCache := flag.Bool("nocache", false, "do not read or write the ngram cache files next to each document.xml")
+
+	flagPathUsage := "Absolute path to the parent directory for 'congress' and json metadata files"
+	var parentPath string
+	flag.StringVar(&parentPath, "parentPath", string(bills.ParentPathDefault), flagPathUsage)
+	flag.StringVar(&parentPath, "p", string(bills.ParentPathDefault), flagPathUsage+" (shorthand)")
+
+	var addr string
+	flag.StringVar(&addr, "addr", ":8080", "address to listen on")
+
+	var maxCachedDocs int
+	flag.IntVar(&maxCachedDocs, "maxCachedDocs", 0, "maximum number of bill documents to keep in memory (0 for no limit)")
+
+	var boilerplatePath string
+	flag.StringVar(&boilerplatePath, "boilerplatePath", "", "path to a JSON list of boilerplate n-grams to exclude from scores (default: [parentPath]/"+bills.BoilerplateNgramsFile+", if it exists)")
+	var boilerplateElements string
+	flag.StringVar(&boilerplateElements, "boilerplateElements", "", "XPath expression for the elements to remove before comparing bills (join several with '|'), or 'none' (default: the BoilerplateElements: table of contents, enacting clause, attestation, endorsement and short title sections)")
+
+	flag.Parse()
+
+	// Default level for this example is info, unless debug flag is present
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	if *debug {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	}
+
+	// UNIX Time is faster and smaller than most timestamps
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	log.Debug().Msg("Log level set to Debug")
+
+	bills.UseNgramCache = !*noCache
+	if err := bills.SetBoilerplateElements(boilerplateElements); err != nil {
+		log.Fatal().Msgf("Error in -boilerplateElements: %s", err)
+	}
+	if err := bills.LoadBoilerplateNgramsDefault(parentPath, boilerplatePath); err != nil {
+		log.Fatal().Msgf("Error loading boilerplate n-grams: %s", err)
+	}
+
+	dataPath := path.Join(parentPath, bills.CongressDir, "data")
+	compareServer := bills.NewCompareServer(dataPath, maxCachedDocs)
+	log.Info().Msgf("Serving bill comparisons for %s on %s", dataPath, addr)
+	if err := http.ListenAndServe(addr, compareServer.Handler()); err != nil {
+		log.Fatal().Msgf("Error running server: %s", err)
+	}
+}
@@ -34,6 +34,7 @@ func (bl *BillList) Set(s string) error {
 func main() {
 
 	debug := flag.Bool("debug", false, "sets log level to debug")
+	noCache := flag.Bool("nocache", false, "do not read or write the ngram cache files next to each document.xml")
 
 	// Default level for this example is info, unless debug flag is present
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
@@ -54,20 +55,43 @@ func main() {
 
 	var absPathList string
 	flag.StringVar(&absPathList, "abspaths", "", "comma-separated list of absolute paths to bill xml files")
+
+	var format string
+	flag.StringVar(&format, "format", bills.CompareFormatDelimited, "output format. Options: delimited (JSON between ':compareMatrix:' delimiters), json, csv, table")
+
+	var boilerplatePath string
+	flag.StringVar(&boilerplatePath, "boilerplatePath", "", "path to a JSON list of boilerplate n-grams to exclude from scores (default: [parentPath]/"+bills.BoilerplateNgramsFile+", if it exists)")
+	var boilerplateElements string
+	flag.StringVar(&boilerplateElements, "boilerplateElements", "", "XPath expression for the elements to remove before comparing bills (join several with '|'), or 'none' (default: the BoilerplateElements: table of contents, enacting clause, attestation, endorsement and short title sections)")
 	flag.Parse()
 	if *debug {
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
 	}
 	log.Debug().Msg("Log level set to Debug")
+	bills.UseNgramCache = !*noCache
+	if err := bills.SetBoilerplateElements(boilerplateElements); err != nil {
+		log.Fatal().Msgf("Error in -boilerplateElements: %s", err)
+	}
+	if err := bills.LoadBoilerplateNgramsDefault(parentPath, boilerplatePath); err != nil {
+		log.Fatal().Msgf("Error loading boilerplate n-grams: %s", err)
+	}
 
+	var compareMatrix [][]bills.CompareItem
+	var err error
 	if absPathList != "" {
 		log.Debug().Msg("Absolute paths to bill xml files: " + absPathList)
 		absPathListSlice := strings.Split(absPathList, ",")
 		for i, absPath := range absPathListSlice {
 			absPathListSlice[i] = strings.TrimSpace(absPath)
 		}
-		bills.CompareBillsfromPaths(absPathListSlice, true)
+		compareMatrix, err = bills.CompareBillsfromPaths(absPathListSlice, false)
 	} else {
-		bills.CompareBills(parentPath, billList, true)
+		compareMatrix, err = bills.CompareBills(parentPath, billList, false)
+	}
+	if err != nil {
+		log.Fatal().Msgf("Error comparing bills: %s", err)
+	}
+	if err := bills.WriteCompareMatrix(os.Stdout, compareMatrix, format); err != nil {
+		log.Fatal().Msgf("Error writing compare matrix: %s", err)
 	}
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/aih/bills"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Command-line tool to build the cosponsorship network of a congress from the sponsors and cosponsors in the bill metadata.
+// Writes the edge list (cosponsor -> sponsor, weighted by the number of bills) as CSV, or a JSON summary with the
+// bipartisanship and top collaborators of each member. The parties of the members are taken from billMeta.json
+// (see billmeta -legislatorsPath) or from the legislators files in -legislatorsPath.
+func main() {
+	debug := flag.Bool("debug", false, "sets log level to debug")
+
+	flagPathUsage := "Absolute path to the parent directory for 'congress' and json metadata files"
+	var parentPath string
+	flag.StringVar(&parentPath, "parentPath", string(bills.ParentPathDefault), flagPathUsage)
+	flag.StringVar(&parentPath, "p", string(bills.ParentPathDefault), flagPathUsage+" (shorthand)")
+
+	var storeOptions bills.BillStoreOptions
+	flag.StringVar(&storeOptions.Type, "store", bills.BillStoreFS, "Where to read the metadata. Options: "+strings.Join(bills.BillStoreTypes, ", "))
+	flag.StringVar(&storeOptions.BadgerPath, "badgerPath", bills.BadgerPathDefault, "Directory of the Badger database, for -store badger")
+	flag.StringVar(&storeOptions.DatabaseUrl, "databaseUrl", "", "Postgres url, for -store postgres (default: DATABASE_URL from the environment or .env)")
+
+	var congress, format, legislatorsPath string
+	var topN int
+	flag.StringVar(&congress, "congress", "", "the congress of the bills (e.g. 116)")
+	flag.StringVar(&format, "format", bills.CosponsorshipFormatJSON, "output format: csv for the edge list, json for the summary. Options: "+strings.Join(bills.CosponsorshipFormats, ", "))
+	flag.IntVar(&topN, "top", 5, "number of top collaborators of each member in the summary")
+	flag.StringVar(&legislatorsPath, "legislatorsPath", "", "Paths to legislators yaml files, separated by commas (e.g. tmp/legislators.yaml,tmp/legislators-historical.yaml), for the parties of members")
+
+	flag.Parse()
+
+	// Default level for this example is info, unless debug flag is present
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	if *debug {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	}
+
+	// UNIX Time is faster and smaller than most timestamps
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	log.Debug().Msg("Log level set to Debug")
+
+	if congress == "" {
+		log.Fatal().Msg("Set the congress with -congress (e.g. 116)")
+	}
+
+	var legislators *bills.LegislatorIndex
+	if legislatorsPath != "" {
+		var err error
+		legislators, err = bills.ReadLegislatorIndex(strings.Split(legislatorsPath, ",")...)
+		if err != nil {
+			log.Fatal().Msgf("Error reading legislators from %s: %s", legislatorsPath, err)
+		}
+	}
+
+	storeOptions.ParentPath = parentPath
+	billStore, err := bills.OpenBillStore(storeOptions)
+	if err != nil {
+		log.Fatal().Msgf("Error opening %s store: %s", storeOptions.Type, err)
+	}
+	defer billStore.Close()
+
+	network, err := bills.LoadCosponsorshipNetwork(billStore, congress, legislators)
+	if err != nil {
+		log.Fatal().Msgf("Error loading the cosponsorship network: %s", err)
+	}
+	log.Info().Msgf("Loaded %d bills and %d edges", network.Bills, len(network.Edges()))
+	if err := bills.WriteCosponsorshipNetwork(os.Stdout, network, format, topN); err != nil {
+		log.Fatal().Msgf("Error writing the cosponsorship network: %s", err)
+	}
+}
@@ -14,10 +14,7 @@ import (
 )
 
 const (
-	max_bills                  = 15
-	esSimilarityFileName       = "esSimilarity.json"
-	esSimilarBillsDictFileName = "esSimilarBillsDict.json"
-	esSimilarCategoryFileName  = "esSimilarCategory.json"
+	max_bills = 15
 )
 
 type SimilarityContext struct {
@@ -25,6 +22,8 @@ type SimilarityContext struct {
 	MaxBills   int
 	SampleSize int
 	Save       bool
+	Store      bills.BillStore
+	Searcher   bills.SectionSearcher
 }
 type flagDef struct {
 	value string
@@ -48,20 +47,21 @@ func GetSimilarityForBill(billnumber string, context SimilarityContext, sem chan
 	defer func() { <-sem }()
 	// This is the equivalent of es_similarity in BillMap
 	log.Info().Msgf("Get versions of: %s", billnumber)
-	r := bills.GetBill_ES(billnumber)
-	log.Info().Msgf("Number of versions of %s:, %d", billnumber, len(r["hits"].(map[string]interface{})["hits"].([]interface{})))
-	latestBillItem, err := bills.GetLatestBill(r)
+	latestBillItem, err := context.Searcher.LatestBillItem(billnumber)
 	if err != nil {
 		log.Error().Msgf("Error getting latest bill: '%v'", err)
+		return
 	}
-	similaritySectionsByBillNumber := bills.GetSimilaritySectionsByBillNumber(latestBillItem, context.SampleSize)
+	similaritySectionsByBillNumber := bills.GetSimilaritySections(context.Searcher, latestBillItem, context.SampleSize)
 	if context.Save {
 		file, marshalErr := json.MarshalIndent(similaritySectionsByBillNumber, "", " ")
 		if marshalErr != nil {
 			log.Error().Msgf("error marshalling similaritySectionsByBillNumber for: %s\nErr: %s", billnumber, marshalErr)
 		}
 		log.Info().Msgf("Saving similaritySectionsByBillnumber for: %s\n", billnumber)
-		bills.SaveBillDataJson(billnumber, file, context.ParentPath, esSimilarityFileName)
+		if err := context.Store.PutBillData(billnumber, bills.EsSimilarityFile, file); err != nil {
+			log.Error().Msgf("Error saving similaritySectionsByBillnumber for: %s\nErr: %s", billnumber, err)
+		}
 	}
 
 	// This is the equivalent of es_similar_bills_dict in BillMap
@@ -73,7 +73,9 @@ func GetSimilarityForBill(billnumber string, context SimilarityContext, sem chan
 		if marshalErr != nil {
 			log.Error().Msgf("error marshalling similarBillsDict for: %s\nErr: %s", billnumber, marshalErr)
 		}
-		bills.SaveBillDataJson(billnumber, file, context.ParentPath, esSimilarBillsDictFileName)
+		if err := context.Store.PutBillData(billnumber, bills.EsSimilarBillsDictFile, file); err != nil {
+			log.Error().Msgf("Error saving similarBillsDict for: %s\nErr: %s", billnumber, err)
+		}
 	}
 
 	// This is a different data form that uses the section metadata as keys
@@ -114,7 +116,9 @@ func GetSimilarityForBill(billnumber string, context SimilarityContext, sem chan
 				if marshalErr != nil {
 					log.Error().Msgf("error marshalling compareMap for: %s\nErr: %s", billnumber, marshalErr)
 				} else {
-					bills.SaveBillDataJson(billnumber, compareMapMarshalled, context.ParentPath, esSimilarCategoryFileName)
+					if err := context.Store.PutBillData(billnumber, bills.EsSimilarCategoryFile, compareMapMarshalled); err != nil {
+						log.Error().Msgf("Error saving compareMap for: %s\nErr: %s", billnumber, err)
+					}
 				}
 			}
 		}
@@ -144,15 +148,20 @@ func main() {
 	save := flag.Bool("save", false, "save results files")
 	debug := flag.Bool("debug", false, "sets log level to debug")
 	all := flag.Bool("all", false, "processes all bills-- otherwise process a sample")
+	noCache := flag.Bool("nocache", false, "do not read or write the ngram cache files next to each document.xml")
 
 	// allow user to pass billnumbers as argument
 	var (
-		billList   BillList
-		congress   string
-		sampleSize int
-		parentPath string
-		maxBills   int
-		logLevel   string
+		billList            BillList
+		congress            string
+		backend             string
+		minScore            float64
+		boilerplatePath     string
+		boilerplateElements string
+		sampleSize          int
+		parentPath          string
+		maxBills            int
+		logLevel            string
 	)
 
 	shorthand := " (shorthand)"
@@ -162,6 +171,8 @@ func main() {
 		"congress":   {"117", "congress to process"},
 		"parentpath": {string(bills.ParentPathDefault), "Absolute path to the parent directory for 'congress' and json metadata files"},
 		"log":        {"Info", "Sets Log level. Options: Error, Info, Debug"},
+		"store":      {bills.BillStoreFS, "Where to save results files, with -save. Options: " + strings.Join(bills.BillStoreTypes, ", ")},
+		"backend":    {bills.SectionBackendES, "Where to find similar sections: es (Elasticsearch) or local (an index of the document.xml files in the parentPath, built in memory). Options: " + strings.Join(bills.SectionBackends, ", ")},
 	}
 	flag.Var(&billList, "b", flagDefs["billnumbers"].usage+shorthand)
 	flag.Var(&billList, "billnumbers", flagDefs["billnumbers"].usage)
@@ -172,6 +183,14 @@ func main() {
 	flag.IntVar(&maxBills, "maxBills", max_bills, "maximum number of similar bills to return")
 	flag.StringVar(&logLevel, "logLevel", flagDefs["log"].value, flagDefs["log"].usage)
 	flag.StringVar(&logLevel, "l", flagDefs["log"].value, flagDefs["log"].usage+" (shorthand)")
+	var storeOptions bills.BillStoreOptions
+	flag.StringVar(&storeOptions.Type, "store", flagDefs["store"].value, flagDefs["store"].usage)
+	flag.StringVar(&storeOptions.BadgerPath, "badgerPath", bills.BadgerPathDefault, "Directory of the Badger database, for -store badger")
+	flag.StringVar(&storeOptions.DatabaseUrl, "databaseUrl", "", "Postgres url, for -store postgres (default: DATABASE_URL from the environment or .env)")
+	flag.StringVar(&backend, "backend", flagDefs["backend"].value, flagDefs["backend"].usage)
+	flag.Float64Var(&minScore, "minScore", bills.NewSectionIndex().MinScore, "minimum BM25 score of a similar section, for -backend local")
+	flag.StringVar(&boilerplatePath, "boilerplatePath", "", "path to a JSON list of boilerplate n-grams to exclude from scores (default: [parentPath]/"+bills.BoilerplateNgramsFile+", if it exists)")
+	flag.StringVar(&boilerplateElements, "boilerplateElements", "", "XPath expression for the elements to remove before comparing bills (join several with '|'), or 'none' (default: the BoilerplateElements: table of contents, enacting clause, attestation, endorsement and short title sections)")
 
 	flag.Parse()
 	similarityContext := SimilarityContext{
@@ -191,21 +210,71 @@ func main() {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 	log.Debug().Msg("Log level set to Debug")
+	bills.UseNgramCache = !*noCache
+	if err := bills.SetBoilerplateElements(boilerplateElements); err != nil {
+		log.Fatal().Msgf("Error in -boilerplateElements: %s", err)
+	}
+	if err := bills.LoadBoilerplateNgramsDefault(parentPath, boilerplatePath); err != nil {
+		log.Fatal().Msgf("Error loading boilerplate n-grams: %s", err)
+	}
 	//bills.PrintESInfo()
 	//bills.SampleQuery()
 	if *save {
-		log.Info().Msgf("Saving files to: %s", similarityContext.ParentPath)
+		storeOptions.ParentPath = parentPath
+		if storeOptions.Type == bills.BillStoreFS {
+			manifest, err := bills.OpenManifest(parentPath)
+			if err != nil {
+				log.Fatal().Msgf("Error opening manifest: %s", err)
+			}
+			defer manifest.Close()
+			storeOptions.Manifest = manifest
+		}
+		billStore, err := bills.OpenBillStore(storeOptions)
+		if err != nil {
+			log.Fatal().Msgf("Error opening %s store: %s", storeOptions.Type, err)
+		}
+		defer billStore.Close()
+		similarityContext.Store = billStore
+		log.Info().Msgf("Saving files to the %s store (parent path: %s)", storeOptions.Type, similarityContext.ParentPath)
+	}
+
+	var sectionIndex *bills.SectionIndex
+	switch backend {
+	case bills.SectionBackendES:
+		similarityContext.Searcher = bills.ElasticSectionSearcher{}
+	case bills.SectionBackendLocal:
+		dataPath := path.Join(parentPath, bills.CongressDir, "data")
+		log.Info().Msgf("Indexing the sections of the bills in %s", dataPath)
+		var err error
+		sectionIndex, err = bills.BuildSectionIndex(dataPath)
+		if err != nil {
+			log.Fatal().Msgf("Error building the section index: %s", err)
+		}
+		sectionIndex.MinScore = minScore
+		similarityContext.Searcher = sectionIndex
+	default:
+		log.Fatal().Msgf("Unknown backend: %s (options: %s)", backend, strings.Join(bills.SectionBackends, ", "))
 	}
 
 	var billNumbers []string
 	if *all {
-		billNumberVersions := bills.GetAllBillNumbers()
+		var billNumberVersions []string
+		if sectionIndex != nil {
+			billNumberVersions = sectionIndex.BillNumberVersions()
+		} else {
+			billNumberVersions = bills.GetAllBillNumbers()
+		}
 		billNumbers = billNumberVersionsToBillNumbers(billNumberVersions)
 	} else if len(billList) > 0 {
 		billNumbers = billList
 	} else if len(congress) > 0 {
 		log.Info().Msgf("Processing similarity for congress: %s", congress)
-		billNumberVersions := bills.GetBillNumbersByCongress(congress)
+		var billNumberVersions []string
+		if sectionIndex != nil {
+			billNumberVersions = filterBillsByCongress(sectionIndex.BillNumberVersions(), congress)
+		} else {
+			billNumberVersions = bills.GetBillNumbersByCongress(congress)
+		}
 		billNumbers = billNumberVersionsToBillNumbers(billNumberVersions)
 		//billNumbers = filterBillsByCongress(bills.RemoveDuplicates(billNumbers), congress)
 		log.Info().Msgf("Length of billNumbers in congress %s: %d", congress, len(billNumbers))
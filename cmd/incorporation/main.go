@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/aih/bills"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Command-line tool to report which bills were incorporated into an enacted bill (e.g. an NDAA or appropriations bill).
+// Uses the section similarity (esSimilarity.json) and similarity categories (esSimilarCategory.json) saved by `esquery -save`
+// for the enacted bill. For each source bill, lists the enacted sections it maps to and the percentage of the source bill
+// found in the enacted bill. Run esquery -save on the source bills too, to measure coverage by sections instead of by text.
+func main() {
+	debug := flag.Bool("debug", false, "sets log level to debug")
+
+	flagPathUsage := "Absolute path to the parent directory for 'congress' and json metadata files"
+	var parentPath string
+	flag.StringVar(&parentPath, "parentPath", string(bills.ParentPathDefault), flagPathUsage)
+	flag.StringVar(&parentPath, "p", string(bills.ParentPathDefault), flagPathUsage+" (shorthand)")
+
+	var storeOptions bills.BillStoreOptions
+	flag.StringVar(&storeOptions.Type, "store", bills.BillStoreFS, "Where to read the similarity files. Options: "+strings.Join(bills.BillStoreTypes, ", "))
+	flag.StringVar(&storeOptions.BadgerPath, "badgerPath", bills.BadgerPathDefault, "Directory of the Badger database, for -store badger")
+	flag.StringVar(&storeOptions.DatabaseUrl, "databaseUrl", "", "Postgres url, for -store postgres (default: DATABASE_URL from the environment or .env)")
+
+	var billNumber, format string
+	var minScore float64
+	flag.StringVar(&billNumber, "billNumber", "", "the enacted bill (e.g. 116hr133)")
+	flag.StringVar(&format, "format", bills.IncorporationFormatJSON, "output format. Options: "+strings.Join(bills.IncorporationFormats, ", "))
+	flag.Float64Var(&minScore, "minScore", 0, "minimum Elasticsearch score for a section to count as incorporated")
+
+	flag.Parse()
+
+	// Default level for this example is info, unless debug flag is present
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	if *debug {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	}
+
+	// UNIX Time is faster and smaller than most timestamps
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	log.Debug().Msg("Log level set to Debug")
+
+	if billNumber == "" {
+		log.Fatal().Msg("Set the enacted bill with -billNumber (e.g. 116hr133)")
+	}
+
+	storeOptions.ParentPath = parentPath
+	billStore, err := bills.OpenBillStore(storeOptions)
+	if err != nil {
+		log.Fatal().Msgf("Error opening %s store: %s", storeOptions.Type, err)
+	}
+	defer billStore.Close()
+
+	report, err := bills.ReadIncorporationReport(billStore, path.Join(parentPath, bills.CongressDir, "data"), billNumber, float32(minScore))
+	if err != nil {
+		log.Fatal().Msgf("Error making the incorporation report: %s", err)
+	}
+	// esquery compares the latest version of a bill, which is the enrolled version once the bill is enacted
+	if enactedVersion := billNumber + "enr"; report.BillNumberVersion != enactedVersion {
+		log.Fatal().Msgf("%s has no enacted version in %s: the sections compared are from %s (run esquery -save again once %s is in the data)", billNumber, bills.EsSimilarityFile, report.BillNumberVersion, enactedVersion)
+	}
+	log.Info().Msgf("%d bills have sections in %s", len(report.Sources), report.BillNumberVersion)
+	if err := bills.WriteIncorporationReport(os.Stdout, report, format); err != nil {
+		log.Fatal().Msgf("Error writing the incorporation report: %s", err)
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
 	"os"
 
 	"github.com/aih/bills"
@@ -9,8 +11,12 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// Command-line tool to download legislators.yaml (and, with -historical, the legislators no longer in office) to the -cacheDir.
+// Files that have not changed upstream are not downloaded again. Prints the path and sha256 checksum of each file as JSON.
 func main() {
 	debug := flag.Bool("debug", false, "sets log level to debug")
+	historical := flag.Bool("historical", false, "also download legislators-historical.yaml, for legislators no longer in office")
+	flag.StringVar(&bills.ReferenceCacheDir, "cacheDir", bills.ReferenceCacheDir, "directory for the downloaded files")
 
 	flag.Parse()
 
@@ -25,6 +31,26 @@ func main() {
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 	log.Debug().Msg("Log level set to Debug")
 
-	bills.DownloadLegislatorsYaml()
-	bills.ReadLegislatorsYaml()
+	var results []bills.FetchResult
+	result, err := bills.DownloadLegislatorsYaml()
+	if err != nil {
+		log.Fatal().Msgf("Error downloading legislators: %s", err)
+	}
+	results = append(results, result)
+	paths := []string{result.Path}
+	if *historical {
+		result, err := bills.DownloadLegislatorsHistoricalYaml()
+		if err != nil {
+			log.Fatal().Msgf("Error downloading historical legislators: %s", err)
+		}
+		results = append(results, result)
+		paths = append(paths, result.Path)
+	}
+	index, err := bills.ReadLegislatorIndex(paths...)
+	if err != nil {
+		log.Fatal().Msgf("Error reading legislators: %s", err)
+	}
+	log.Info().Msgf("Read %d legislators", index.Len())
+	resultsJson, _ := json.MarshalIndent(results, "", " ")
+	fmt.Println(string(resultsJson))
 }
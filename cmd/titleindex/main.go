@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/aih/bills"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Command-line tool to query the title indexes in the Badger store (see `billmeta -store badger`).
+// Prints JSON for the bills that share a normalized title (-title), the titles that start with a prefix (-prefix)
+// or the titles of a bill (-billNumber). With -load, first loads titleNoYearIndexGo.json and mainTitleNoYearIndexGo.json from the parentPath.
+func main() {
+	debug := flag.Bool("debug", false, "sets log level to debug")
+	load := flag.Bool("load", false, "load the title index JSON files in the parentPath to the store")
+	mainTitles := flag.Bool("main", false, "use the index of main titles (mainTitleNoYearIndexGo.json)")
+
+	flagPathUsage := "Absolute path to the parent directory for 'congress' and json metadata files"
+	var parentPath string
+	flag.StringVar(&parentPath, "parentPath", string(bills.ParentPathDefault), flagPathUsage)
+	flag.StringVar(&parentPath, "p", string(bills.ParentPathDefault), flagPathUsage+" (shorthand)")
+
+	var badgerPath, title, prefix, billNumber string
+	var limit int
+	flag.StringVar(&badgerPath, "badgerPath", bills.BadgerPathDefault, "Directory of the Badger database")
+	flag.StringVar(&title, "title", "", "get the bills with this title (normalized; see bills.NormalizeTitle)")
+	flag.StringVar(&prefix, "prefix", "", "get the titles that start with this prefix, and their bills")
+	flag.IntVar(&limit, "limit", 20, "maximum number of titles for -prefix (0 for no limit)")
+	flag.StringVar(&billNumber, "billNumber", "", "get the titles of this bill (e.g. 116hr1500)")
+
+	flag.Parse()
+
+	// Default level for this example is info, unless debug flag is present
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	if *debug {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	}
+
+	// UNIX Time is faster and smaller than most timestamps
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	log.Debug().Msg("Log level set to Debug")
+
+	badgerStore, err := bills.OpenBadgerBillStore(badgerPath)
+	if err != nil {
+		log.Fatal().Msgf("Error opening Badger store: %s", err)
+	}
+	defer badgerStore.Close()
+
+	if *load {
+		fileStore := bills.NewFileBillStore(parentPath)
+		for _, indexName := range []string{bills.TitleNoYearIndex, bills.MainTitleNoYearIndex} {
+			titleIndex, err := fileStore.GetTitleIndex(indexName)
+			if err != nil {
+				log.Error().Msgf("Error reading %s: %s", indexName, err)
+				continue
+			}
+			log.Info().Msgf("Loading %d titles from %s", len(titleIndex), indexName)
+			if err := badgerStore.PutTitleIndex(indexName, titleIndex); err != nil {
+				log.Error().Msgf("Error loading %s: %s", indexName, err)
+			}
+		}
+	}
+
+	indexName := bills.TitleNoYearIndex
+	if *mainTitles {
+		indexName = bills.MainTitleNoYearIndex
+	}
+	titleIndex := badgerStore.TitleIndex(indexName)
+	var result interface{}
+	switch {
+	case title != "":
+		result, err = titleIndex.Bills(title)
+	case prefix != "":
+		result, err = titleIndex.TitlesWithPrefix(prefix, limit)
+	case billNumber != "":
+		result, err = titleIndex.BillTitles(billNumber)
+	default:
+		return
+	}
+	if err != nil {
+		badgerStore.Close()
+		log.Fatal().Msgf("Error querying %s: %s", indexName, err)
+	}
+	resultJson, _ := json.MarshalIndent(result, "", " ")
+	fmt.Println(string(resultJson))
+}
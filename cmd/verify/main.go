@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/aih/bills"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Command-line tool to check the output files (billMeta.json, relatedDict.json and the es*.json files) in the 'congress' directory of the `parentPath`.
+// Reports, as JSON, the files that are missing, that are not valid JSON or do not match the hash in the run manifests (see bills.Manifest),
+// and the temporary files left by writes that did not finish. Exits with status 1 if any are found.
+func main() {
+	debug := flag.Bool("debug", false, "sets log level to debug")
+	unrecorded := flag.Bool("unrecorded", false, "also list the output files that are not in any manifest")
+
+	flagPathUsage := "Absolute path to the parent directory for 'congress' and json metadata files"
+	var parentPath string
+	flag.StringVar(&parentPath, "parentPath", string(bills.ParentPathDefault), flagPathUsage)
+	flag.StringVar(&parentPath, "p", string(bills.ParentPathDefault), flagPathUsage+" (shorthand)")
+
+	flag.Parse()
+
+	// Default level for this example is info, unless debug flag is present
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	if *debug {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	}
+
+	// UNIX Time is faster and smaller than most timestamps
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	log.Debug().Msg("Log level set to Debug")
+
+	report, err := bills.VerifyOutputs(parentPath)
+	if err != nil {
+		log.Fatal().Msgf("Error verifying outputs: %s", err)
+	}
+	log.Info().Msgf("Checked %d files: %d missing, %d corrupt, %d stale, %d unrecorded", report.Checked, len(report.Missing), len(report.Corrupt), len(report.Stale), len(report.Unrecorded))
+	if !*unrecorded {
+		report.Unrecorded = nil
+	}
+	reportJson, _ := json.MarshalIndent(report, "", " ")
+	fmt.Println(string(reportJson))
+	if !report.Ok() {
+		os.Exit(1)
+	}
+}
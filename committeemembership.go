@@ -0,0 +1,173 @@
+package bills
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Name of the committee membership file in the ReferenceCacheDir
+const CommitteeMembershipYamlFile = "committee-membership.yaml"
+
+var (
+	committeeMembershipYamlUrl = "https://raw.githubusercontent.com/unitedstates/congress-legislators/master/committee-membership-current.yaml"
+)
+
+// A member of a committee or subcommittee, in committee-membership-current.yaml
+type CommitteeMember struct {
+	Name string `yaml:"name,omitempty,flow"`
+	// majority or minority
+	Party    string `yaml:"party,omitempty,flow"`
+	Rank     int    `yaml:"rank,omitempty,flow"`
+	Title    string `yaml:"title,omitempty,flow"`
+	Bioguide string `yaml:"bioguide,omitempty,flow"`
+	Thomas   string `yaml:"thomas,omitempty,flow"`
+}
+
+// A cosponsor of a bill who is a member of one of the committees the bill was referred to
+type CommitteeCosponsor struct {
+	CommitteeId string `json:"committee_id"`
+	// Set if the bill was referred to a subcommittee of the committee (e.g. 15 for HSBA15)
+	SubcommitteeId string `json:"subcommittee_id,omitempty"`
+	BioguideId     string `json:"bioguide_id"`
+	Name           string `json:"name"`
+	// majority or minority, and the rank on that side of the committee
+	CommitteeParty string `json:"committee_party,omitempty"`
+	Rank           int    `json:"rank,omitempty"`
+	Title          string `json:"title,omitempty"`
+}
+
+// Members of committees and subcommittees, keyed by the thomas id of the committee (HSBA) or the committee
+// and subcommittee (HSBA15). Members are linked to the legislators index by bioguide id, or by thomas id
+// for members without a bioguide id.
+type CommitteeMembership struct {
+	byCommittee map[string][]CommitteeMember
+	// bioguide id -> committee ids
+	byBioguide  map[string][]string
+	legislators *LegislatorIndex
+}
+
+// Makes the membership index from the parsed committee-membership-current.yaml. The legislators index may be nil.
+func NewCommitteeMembership(members map[string][]CommitteeMember, legislators *LegislatorIndex) *CommitteeMembership {
+	membership := &CommitteeMembership{
+		byCommittee: make(map[string][]CommitteeMember),
+		byBioguide:  make(map[string][]string),
+		legislators: legislators,
+	}
+	for committeeId, committeeMembers := range members {
+		committeeId = strings.ToUpper(committeeId)
+		for _, member := range committeeMembers {
+			if member.Bioguide == "" && member.Thomas != "" && legislators != nil {
+				if legislator, ok := legislators.ByThomas(member.Thomas); ok {
+					member.Bioguide = legislator.Bioguide
+				}
+			}
+			membership.byCommittee[committeeId] = append(membership.byCommittee[committeeId], member)
+			if member.Bioguide != "" {
+				membership.byBioguide[member.Bioguide] = append(membership.byBioguide[member.Bioguide], committeeId)
+			}
+		}
+	}
+	for _, committeeIds := range membership.byBioguide {
+		sort.Strings(committeeIds)
+	}
+	return membership
+}
+
+// Downloads committee-membership-current.yaml to the ReferenceCacheDir, if it changed upstream
+func DownloadCommitteeMembershipYaml() (FetchResult, error) {
+	return NewFetcher(ReferenceCacheDir).Fetch(committeeMembershipYamlUrl, CommitteeMembershipYamlFile)
+}
+
+// Reads committee-membership-current.yaml at the path (e.g. tmp/committee-membership.yaml)
+func ReadCommitteeMembership(pathToYaml string, legislators *LegislatorIndex) (*CommitteeMembership, error) {
+	yamlFile, err := ioutil.ReadFile(pathToYaml)
+	if err != nil {
+		return nil, err
+	}
+	var members map[string][]CommitteeMember
+	if err := yaml.Unmarshal(yamlFile, &members); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", pathToYaml, err)
+	}
+	return NewCommitteeMembership(members, legislators), nil
+}
+
+// Gets the members of the committee or subcommittee. The id may be in any of the forms in splitCommitteeId (HSBA, hsba00, hsba15).
+func (membership *CommitteeMembership) Members(committeeId string) []CommitteeMember {
+	committeeId, subcommitteeId := splitCommitteeId(committeeId)
+	return membership.byCommittee[committeeId+subcommitteeId]
+}
+
+// Gets the ids of the committees and subcommittees of the legislator
+func (membership *CommitteeMembership) Committees(bioguideId string) []string {
+	return membership.byBioguide[bioguideId]
+}
+
+// Whether the legislator is a member of the committee or subcommittee
+func (membership *CommitteeMembership) IsMember(committeeId string, bioguideId string) (CommitteeMember, bool) {
+	for _, member := range membership.Members(committeeId) {
+		if member.Bioguide == bioguideId {
+			return member, true
+		}
+	}
+	return CommitteeMember{}, false
+}
+
+// Gets the legislators on the committee or subcommittee, from the legislators index
+func (membership *CommitteeMembership) Legislators(committeeId string) (legislators []Legislator) {
+	if membership.legislators == nil {
+		return legislators
+	}
+	for _, member := range membership.Members(committeeId) {
+		if legislator, ok := membership.legislators.ByBioguide(member.Bioguide); ok {
+			legislators = append(legislators, legislator)
+		}
+	}
+	return legislators
+}
+
+// Gets the cosponsors of the bill who are members of the committees and subcommittees it was referred to.
+// A referral to a subcommittee is listed in data.json as another item with the same committee_id and a
+// subcommittee_id; its cosponsors are the members of the subcommittee (e.g. HSBA15).
+func (membership *CommitteeMembership) ReferralCommitteeCosponsors(billMeta BillMeta) []CommitteeCosponsor {
+	committeeCosponsors := []CommitteeCosponsor{}
+	referrals := make(map[string]bool)
+	for _, committeeItem := range billMeta.Committees {
+		if _, referred := Find(committeeItem.Activity, "referral"); !referred {
+			continue
+		}
+		committeeId, subcommitteeId := splitCommitteeId(committeeItem.CommitteeId)
+		if subcommitteeId == "" {
+			_, subcommitteeId = splitCommitteeId(committeeId + strings.TrimSpace(committeeItem.SubcommitteeId))
+		}
+		if referrals[committeeId+subcommitteeId] {
+			continue
+		}
+		referrals[committeeId+subcommitteeId] = true
+		for _, cosponsor := range billMeta.Cosponsors {
+			if member, ok := membership.IsMember(committeeId+subcommitteeId, cosponsor.BioguideId); ok && cosponsor.BioguideId != "" {
+				committeeCosponsors = append(committeeCosponsors, CommitteeCosponsor{
+					CommitteeId:    committeeId,
+					SubcommitteeId: subcommitteeId,
+					BioguideId:     cosponsor.BioguideId,
+					Name:           cosponsor.Name,
+					CommitteeParty: member.Party,
+					Rank:           member.Rank,
+					Title:          member.Title,
+				})
+			}
+		}
+	}
+	return committeeCosponsors
+}
+
+// Adds the cosponsors who are members of the committees of referral to the bill
+func (membership *CommitteeMembership) EnrichBillMeta(billMeta *BillMeta) {
+	if membership == nil {
+		return
+	}
+	billMeta.ReferralCommitteeCosponsors = membership.ReferralCommitteeCosponsors(*billMeta)
+}
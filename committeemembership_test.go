@@ -0,0 +1,88 @@
+package bills
+
+import (
+	"path"
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var sampleCommitteeMembershipPath = path.Join("samples", "committees", "committee-membership-current.yaml")
+
+func TestCommitteeMembership(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test looking up committee members and linking them to legislators")
+	legislators, err := ReadLegislatorIndex(sampleLegislatorsPath, sampleLegislatorsHistoricalPath)
+	assert.Nil(t, err)
+	membership, err := ReadCommitteeMembership(sampleCommitteeMembershipPath, legislators)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 3, len(membership.Members("HSBA")))
+	assert.Equal(t, 3, len(membership.Members("hsba00")))
+	assert.Equal(t, 1, len(membership.Members("hsba15")))
+	assert.Equal(t, 0, len(membership.Members("HSXX")))
+	member, ok := membership.IsMember("HSBA", "W000187")
+	assert.True(t, ok)
+	assert.Equal(t, "Chair", member.Title)
+	assert.Equal(t, []string{"HSBA", "HSBA15"}, membership.Committees("C001049"))
+
+	// The SSFI member has only a thomas id, which is linked to the legislator
+	assert.Equal(t, []string{"SSBK", "SSFI"}, membership.Committees("B000944"))
+	committeeLegislators := membership.Legislators("SSFI")
+	assert.Equal(t, 1, len(committeeLegislators))
+	assert.Equal(t, "Brown", committeeLegislators[0].Name.Last)
+
+	// Without the legislators index, the member is not linked
+	membership, err = ReadCommitteeMembership(sampleCommitteeMembershipPath, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"SSBK"}, membership.Committees("B000944"))
+	assert.Equal(t, 0, len(membership.Legislators("SSFI")))
+}
+
+func TestReferralCommitteeCosponsors(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test finding the cosponsors on the committees of referral")
+	membership, err := ReadCommitteeMembership(sampleCommitteeMembershipPath, nil)
+	assert.Nil(t, err)
+
+	billStore, err := OpenBadgerBillStore(path.Join(t.TempDir(), "badger"))
+	assert.Nil(t, err)
+	defer billStore.Close()
+	metaIndex := NewMetaIndex()
+	metaIndex.CommitteeMembership = membership
+	metaIndex.MakeBillsMeta("samples", billStore, "116")
+	billMeta, ok := metaIndex.GetBillMeta("116hr1500")
+	assert.True(t, ok)
+	// Adams is on two of the committees of referral and Clay on one; Waters is the sponsor, not a cosponsor
+	committeeCosponsors := make(map[string][]string)
+	for _, committeeCosponsor := range billMeta.ReferralCommitteeCosponsors {
+		committeeCosponsors[committeeCosponsor.CommitteeId] = append(committeeCosponsors[committeeCosponsor.CommitteeId], committeeCosponsor.BioguideId)
+	}
+	assert.Equal(t, map[string][]string{"HSBA": {"A000370", "C001049"}, "HSED": {"A000370"}}, committeeCosponsors)
+
+	// Only committees of referral are checked
+	billMeta = BillMeta{
+		Committees: []CommitteeItem{{CommitteeId: "HSBA", Activity: []string{"reporting"}}},
+		Cosponsors: []CosponsorItem{{BioguideId: "A000370"}},
+	}
+	membership.EnrichBillMeta(&billMeta)
+	assert.Equal(t, 0, len(billMeta.ReferralCommitteeCosponsors))
+
+	// A referral to a subcommittee is another item with the same committee_id; each referral is checked once
+	billMeta = BillMeta{
+		Committees: []CommitteeItem{
+			{CommitteeId: "HSBA", Activity: []string{"referral"}},
+			{CommitteeId: "HSBA", SubcommitteeId: "15", Subcommittee: "National Security, International Development and Monetary Policy", Activity: []string{"referral"}},
+			{CommitteeId: "HSBA", SubcommitteeId: "15", Activity: []string{"referral", "hearings"}},
+		},
+		Cosponsors: []CosponsorItem{{BioguideId: "A000370"}, {BioguideId: "C001049"}},
+	}
+	membership.EnrichBillMeta(&billMeta)
+	assert.Equal(t, []CommitteeCosponsor{
+		{CommitteeId: "HSBA", BioguideId: "A000370", CommitteeParty: "majority", Rank: 30},
+		{CommitteeId: "HSBA", BioguideId: "C001049", CommitteeParty: "majority", Rank: 3},
+		{CommitteeId: "HSBA", SubcommitteeId: "15", BioguideId: "C001049", CommitteeParty: "majority", Rank: 2},
+	}, billMeta.ReferralCommitteeCosponsors)
+}
@@ -1,13 +1,19 @@
 package bills
 
 import (
+	"fmt"
 	"io/ioutil"
+	"path"
+	"strings"
 
 	"gopkg.in/yaml.v2"
 
 	"github.com/rs/zerolog/log"
 )
 
+// Name of committees.yaml in the ReferenceCacheDir
+const CommitteesYamlFile = "committees.yaml"
+
 var (
 	committeesYamlUrl = "https://raw.githubusercontent.com/unitedstates/congress-legislators/master/committees-current.yaml"
 )
@@ -17,17 +23,18 @@ type Committees struct {
 }
 
 type Committee struct {
-	Type             string         `yaml:"type,omitempty,flow"`
-	Name             string         `yaml:"name,omitempty,flow"`
-	Url              string         `yaml:"url,omitempty,flow"`
-	MinorityUrl      string         `yaml:"minority_url,omitempty,flow"`
-	ThomasId         string         `yaml:"thomas_id,omitempty,flow"`
-	HouseCommitteeId string         `yaml:"house_committee_id,omitempty,flow"`
-	Subcommittees    []Subcommittee `yaml:"subcommittees,omitempty,flow"`
-	Address          string         `yaml:"address,omitempty,flow"`
-	Phone            string         `yaml:"phone,omitempty,flow"`
-	RssUrl           string         `yaml:"rss_url,omitempty,flow"`
-	Jurisdiction     string         `yaml:"jurisdiction,omitempty,flow"`
+	Type              string         `yaml:"type,omitempty,flow"`
+	Name              string         `yaml:"name,omitempty,flow"`
+	Url               string         `yaml:"url,omitempty,flow"`
+	MinorityUrl       string         `yaml:"minority_url,omitempty,flow"`
+	ThomasId          string         `yaml:"thomas_id,omitempty,flow"`
+	HouseCommitteeId  string         `yaml:"house_committee_id,omitempty,flow"`
+	SenateCommitteeId string         `yaml:"senate_committee_id,omitempty,flow"`
+	Subcommittees     []Subcommittee `yaml:"subcommittees,omitempty,flow"`
+	Address           string         `yaml:"address,omitempty,flow"`
+	Phone             string         `yaml:"phone,omitempty,flow"`
+	RssUrl            string         `yaml:"rss_url,omitempty,flow"`
+	Jurisdiction      string         `yaml:"jurisdiction,omitempty,flow"`
 }
 
 type Subcommittee struct {
@@ -37,39 +44,32 @@ type Subcommittee struct {
 	Phone    string `yaml:"phone,omitempty,flow"`
 }
 
-func DownloadCommitteesYaml() (downloadpath string, err error) {
-	MakeTempDir()
-	downloadpath = "tmp/committees.yaml"
-	err = DownloadFile(downloadpath, committeesYamlUrl)
-	if err != nil {
-		panic(err)
-	}
-	log.Info().Msg("Downloaded: " + committeesYamlUrl)
-	Prepend(downloadpath, "committees:")
-	if err != nil {
-		panic(err)
-	}
-	log.Info().Msg("Prepended 'committees:' to file")
-	return
+// Downloads committees-current.yaml to committees.yaml in the ReferenceCacheDir, if it changed upstream
+func DownloadCommitteesYaml() (FetchResult, error) {
+	return NewFetcher(ReferenceCacheDir).Fetch(committeesYamlUrl, CommitteesYamlFile)
 }
 
+// Parses committees.yaml, which is a list of committees. Files with a 'committees:' key
+// (prepended to the list by earlier versions of DownloadCommitteesYaml) are also accepted.
 func (c *Committees) ParseCommitteeYaml(data []byte) error {
-	if err := yaml.Unmarshal(data, c); err != nil {
-		return err
+	if err := yaml.Unmarshal(data, &c.Committees); err != nil {
+		if mapErr := yaml.Unmarshal(data, c); mapErr != nil {
+			return err
+		}
 	}
 	// ... Check for elements of the YAML
 	return nil
 }
 
 func ReadCommitteesYaml() (committees Committees, err error) {
-	pathToYaml := "tmp/committees.yaml"
+	pathToYaml := path.Join(ReferenceCacheDir, CommitteesYamlFile)
 	yamlFile, err := ioutil.ReadFile(pathToYaml)
 	if err != nil {
 		log.Error().Msgf("Error reading %s   #%v ", pathToYaml, err)
 		return committees, err
 	}
 	if err := committees.ParseCommitteeYaml(yamlFile); err != nil {
-		log.Fatal().Err(err)
+		log.Error().Msgf("Error parsing %s: %s", pathToYaml, err)
 		return committees, err
 	}
 
@@ -77,3 +77,126 @@ func ReadCommitteesYaml() (committees Committees, err error) {
 	return committees, err
 
 }
+
+// Committees by their ids, in any of the forms used in bill data and committees.yaml:
+// the thomas id (HSBA, SSFI), the house or senate committee id (BA) and the system code (hsba00).
+// Subcommittees are found by their id in the committee (15) or with the committee id (HSBA15, hsba15).
+// Ids are not case sensitive.
+type CommitteeRegistry struct {
+	byId map[string]*Committee
+}
+
+func NewCommitteeRegistry(committees []Committee) *CommitteeRegistry {
+	registry := &CommitteeRegistry{byId: make(map[string]*Committee)}
+	for i := range committees {
+		committee := &committees[i]
+		for _, id := range []string{committee.ThomasId, committee.SenateCommitteeId} {
+			if id != "" {
+				registry.byId[strings.ToUpper(id)] = committee
+			}
+		}
+		// The house committee id (e.g. BA) is the thomas id without the chamber prefix (HS)
+		if committee.HouseCommitteeId != "" {
+			registry.byId[strings.ToUpper(committee.HouseCommitteeId)] = committee
+		}
+	}
+	return registry
+}
+
+// Reads the committees yaml file at the path (e.g. tmp/committees.yaml) into a registry
+func ReadCommitteeRegistry(pathToYaml string) (*CommitteeRegistry, error) {
+	yamlFile, err := ioutil.ReadFile(pathToYaml)
+	if err != nil {
+		return nil, err
+	}
+	var committees Committees
+	if err := committees.ParseCommitteeYaml(yamlFile); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", pathToYaml, err)
+	}
+	return NewCommitteeRegistry(committees.Committees), nil
+}
+
+// Number of committees in the registry
+func (registry *CommitteeRegistry) Len() int {
+	committees := make(map[*Committee]bool)
+	for _, committee := range registry.byId {
+		committees[committee] = true
+	}
+	return len(committees)
+}
+
+// Splits an id into the committee id and the subcommittee id, if any (e.g. hsba15 -> HSBA, 15; SSFI00 -> SSFI, "")
+func splitCommitteeId(id string) (committeeId string, subcommitteeId string) {
+	id = strings.ToUpper(strings.TrimSpace(id))
+	if len(id) == 6 {
+		committeeId, subcommitteeId = id[:4], id[4:]
+		if subcommitteeId == "00" {
+			subcommitteeId = ""
+		}
+		return committeeId, subcommitteeId
+	}
+	return id, ""
+}
+
+// Gets the committee by any of its ids. For a subcommittee id (e.g. HSBA15), gets its committee.
+func (registry *CommitteeRegistry) Lookup(id string) (Committee, bool) {
+	committeeId, _ := splitCommitteeId(id)
+	committee, ok := registry.byId[committeeId]
+	if !ok {
+		return Committee{}, false
+	}
+	return *committee, true
+}
+
+// Gets the subcommittee and its committee. The subcommittee id may be the id in the committee (15),
+// or include the committee id (HSBA15, hsba15), in which case committeeId may be empty.
+func (registry *CommitteeRegistry) LookupSubcommittee(committeeId string, subcommitteeId string) (committee Committee, subcommittee Subcommittee, ok bool) {
+	if parentId, id := splitCommitteeId(subcommitteeId); id != "" {
+		committeeId, subcommitteeId = parentId, id
+	}
+	committee, ok = registry.Lookup(committeeId)
+	if !ok {
+		return committee, subcommittee, false
+	}
+	subcommitteeId = strings.ToUpper(strings.TrimSpace(subcommitteeId))
+	for _, subcommittee := range committee.Subcommittees {
+		if strings.ToUpper(subcommittee.ThomasId) == subcommitteeId {
+			return committee, subcommittee, true
+		}
+	}
+	return committee, subcommittee, false
+}
+
+// Adds the canonical names and the jurisdiction of the committee (and subcommittee) to a committee of a bill
+func (registry *CommitteeRegistry) EnrichCommittee(committeeItem CommitteeItem) CommitteeItem {
+	if registry == nil {
+		return committeeItem
+	}
+	committeeId := committeeItem.CommitteeId
+	if committeeId == "" {
+		committeeId = committeeItem.SubcommitteeId
+	}
+	committee, ok := registry.Lookup(committeeId)
+	if !ok {
+		log.Debug().Msgf("No committee found for %s (%s)", committeeItem.Committee, committeeItem.CommitteeId)
+		return committeeItem
+	}
+	committeeItem.CommitteeName = committee.Name
+	committeeItem.Jurisdiction = committee.Jurisdiction
+	if committeeItem.SubcommitteeId != "" {
+		if _, subcommittee, ok := registry.LookupSubcommittee(committee.ThomasId, committeeItem.SubcommitteeId); ok {
+			committeeItem.SubcommitteeName = subcommittee.Name
+		}
+	}
+	return committeeItem
+}
+
+// Adds the canonical names and the jurisdiction to the committees of the bill
+func (registry *CommitteeRegistry) EnrichBillMeta(billMeta *BillMeta) {
+	if registry == nil {
+		return
+	}
+	for i, committeeItem := range billMeta.Committees {
+		billMeta.Committees[i] = registry.EnrichCommittee(committeeItem)
+	}
+}
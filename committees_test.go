@@ -1,6 +1,7 @@
 package bills
 
 import (
+	"path"
 	"testing"
 
 	"github.com/aih/bills/internal/testutils"
@@ -9,18 +10,32 @@ import (
 )
 
 func TestDownloadCommitteesYaml(t *testing.T) {
-	log.Info().Msg("Test downloading Committees YAML from unitedstates repo")
+	log.Info().Msg("Test downloading Committees YAML from a local server")
 	testutils.SetLogLevel()
-	_, err := DownloadCommitteesYaml()
+	server, _ := newReferenceDataServer(t, sampleCommitteesPath)
+	defer server.Close()
+	defer func(url, cacheDir string) { committeesYamlUrl, ReferenceCacheDir = url, cacheDir }(committeesYamlUrl, ReferenceCacheDir)
+	committeesYamlUrl = server.URL + "/committees-current.yaml"
+	ReferenceCacheDir = t.TempDir()
+
+	result, err := DownloadCommitteesYaml()
 	if err != nil {
 		t.Errorf("Error downloading Committees YAML: %v", err)
 	}
-
+	assert.Equal(t, path.Join(ReferenceCacheDir, CommitteesYamlFile), result.Path)
 }
 
 func TestReadCommitteesYaml(t *testing.T) {
 	log.Info().Msg("Test parsing YAML to committees list")
 	testutils.SetLogLevel()
+	server, _ := newReferenceDataServer(t, sampleCommitteesPath)
+	defer server.Close()
+	defer func(url, cacheDir string) { committeesYamlUrl, ReferenceCacheDir = url, cacheDir }(committeesYamlUrl, ReferenceCacheDir)
+	committeesYamlUrl = server.URL + "/committees-current.yaml"
+	ReferenceCacheDir = t.TempDir()
+	_, err := DownloadCommitteesYaml()
+	assert.Nil(t, err)
+
 	committees, err := ReadCommitteesYaml()
 	if err != nil {
 		t.Errorf("Could not read Committees YAML from file: %v", err)
@@ -30,4 +45,70 @@ func TestReadCommitteesYaml(t *testing.T) {
 	}
 	assert.GreaterOrEqual(t, len(committees.Committees), 1)
 	assert.Equal(t, committees.Committees[0].Type, "house")
+
+	// Files with the 'committees:' key from earlier versions are also read
+	assert.Nil(t, committees.ParseCommitteeYaml([]byte("committees:\n- type: senate\n  thomas_id: SSFI\n")))
+	assert.Equal(t, "SSFI", committees.Committees[0].ThomasId)
+}
+
+var sampleCommitteesPath = path.Join("samples", "committees", "committees-current.yaml")
+
+func TestCommitteeRegistry(t *testing.T) {
+	log.Info().Msg("Test looking up committees and subcommittees by id")
+	testutils.SetLogLevel()
+	registry, err := ReadCommitteeRegistry(sampleCommitteesPath)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, registry.Len())
+
+	for _, id := range []string{"HSBA", "hsba", "hsba00", "BA", "HSBA15"} {
+		committee, ok := registry.Lookup(id)
+		assert.True(t, ok, id)
+		assert.Equal(t, "House Committee on Financial Services", committee.Name, id)
+	}
+	committee, ok := registry.Lookup("ssfi00")
+	assert.True(t, ok)
+	assert.Equal(t, "Senate Committee on Finance", committee.Name)
+	_, ok = registry.Lookup("HSXX")
+	assert.False(t, ok)
+
+	committee, subcommittee, ok := registry.LookupSubcommittee("HSBA", "15")
+	assert.True(t, ok)
+	assert.Equal(t, "HSBA", committee.ThomasId)
+	assert.Equal(t, "Consumer Protection and Financial Institutions", subcommittee.Name)
+	_, subcommittee, ok = registry.LookupSubcommittee("", "ssfi13")
+	assert.True(t, ok)
+	assert.Equal(t, "Taxation and IRS Oversight", subcommittee.Name)
+	_, _, ok = registry.LookupSubcommittee("HSBA", "99")
+	assert.False(t, ok)
+}
+
+func TestEnrichBillMetaCommittees(t *testing.T) {
+	log.Info().Msg("Test adding committee names and jurisdiction to bill metadata")
+	testutils.SetLogLevel()
+	registry, err := ReadCommitteeRegistry(sampleCommitteesPath)
+	assert.Nil(t, err)
+
+	billStore, err := OpenBadgerBillStore(path.Join(t.TempDir(), "badger"))
+	assert.Nil(t, err)
+	defer billStore.Close()
+	metaIndex := NewMetaIndex()
+	metaIndex.Committees = registry
+	metaIndex.MakeBillsMeta("samples", billStore, "116")
+	billMeta, ok := metaIndex.GetBillMeta("116hr1500")
+	assert.True(t, ok)
+	names := make(map[string]string)
+	for _, committeeItem := range billMeta.Committees {
+		names[committeeItem.CommitteeId] = committeeItem.CommitteeName
+	}
+	assert.Equal(t, map[string]string{
+		"SSBK": "Senate Committee on Banking, Housing, and Urban Affairs",
+		"HSED": "House Committee on Education and Labor",
+		"HSBA": "House Committee on Financial Services",
+	}, names)
+
+	billMeta = BillMeta{Committees: []CommitteeItem{{CommitteeId: "SSFI", SubcommitteeId: "13"}, {CommitteeId: "HSXX"}}}
+	registry.EnrichBillMeta(&billMeta)
+	assert.Equal(t, "Taxation and IRS Oversight", billMeta.Committees[0].SubcommitteeName)
+	assert.Contains(t, billMeta.Committees[0].Jurisdiction, "Revenue measures")
+	assert.Equal(t, "", billMeta.Committees[1].CommitteeName)
 }
@@ -0,0 +1,217 @@
+package bills
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Request body for the compare service: a list of bill number versions (e.g. 116hr1500ih)
+// or a list of paths to bill xml files
+type CompareRequest struct {
+	Bills []string `json:"bills"`
+	Paths []string `json:"paths"`
+}
+
+type CompareResponse struct {
+	Bills         []string        `json:"bills"`
+	CompareMatrix [][]CompareItem `json:"compare_matrix"`
+}
+
+type compareErrorResponse struct {
+	Error string `json:"error"`
+}
+
+type cachedDocMap struct {
+	modTime time.Time
+	size    int64
+	docMap  *docMap
+}
+
+// Compares bills over HTTP, keeping the n-grams of the bills in memory between requests.
+// Use Handler() to serve it, e.g. http.ListenAndServe(":8080", compareServer.Handler())
+//
+// Endpoints:
+// POST /compare with a JSON CompareRequest body; returns a CompareResponse
+// GET /compare?bills=116hr1500ih,116hr1500eh (or ?paths=...); returns a CompareResponse
+// GET /health; returns the number of documents in memory
+type CompareServer struct {
+	// Path to the 'data' directory of the congress tree, as in CompareBills.
+	// Paths in requests must be inside this directory.
+	DataPath string
+	// Maximum number of documents to keep in memory; 0 for no limit
+	MaxCachedDocs int
+
+	mu    sync.RWMutex
+	cache map[string]cachedDocMap
+}
+
+func NewCompareServer(dataPath string, maxCachedDocs int) *CompareServer {
+	return &CompareServer{
+		DataPath:      dataPath,
+		MaxCachedDocs: maxCachedDocs,
+		cache:         make(map[string]cachedDocMap),
+	}
+}
+
+// Number of documents with n-grams in memory
+func (s *CompareServer) CachedDocs() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.cache)
+}
+
+// Gets the docMap from memory, or from the fingerprint cache if the file has changed since it was loaded
+func (s *CompareServer) getDocMap(docPath string) (*docMap, error) {
+	info, err := os.Stat(docPath)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	cached, ok := s.cache[docPath]
+	s.mu.RUnlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.docMap, nil
+	}
+	docMapItem, err := makeDocMap(docPath)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cache == nil {
+		s.cache = make(map[string]cachedDocMap)
+	}
+	if s.MaxCachedDocs > 0 && len(s.cache) >= s.MaxCachedDocs {
+		// Evict an arbitrary document to make room
+		for k := range s.cache {
+			delete(s.cache, k)
+			break
+		}
+	}
+	s.cache[docPath] = cachedDocMap{modTime: info.ModTime(), size: info.Size(), docMap: docMapItem}
+	return docMapItem, nil
+}
+
+// Gets the absolute paths to the bill xml files in the request; the paths are also the keys of the documents in memory.
+// Returns an error for bill numbers without a version, or paths outside of the DataPath
+func (s *CompareServer) docPaths(compareRequest CompareRequest) (docPaths []string, err error) {
+	dataPath, err := filepath.Abs(s.DataPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, billNumber := range compareRequest.Bills {
+		billNumber = strings.TrimSpace(billNumber)
+		if FindNamedMatches(BillnumberRegexCompiled, billNumber)["version"] == "" {
+			return nil, fmt.Errorf("no version in bill number: %s", billNumber)
+		}
+		billPath, err := PathFromBillNumber(billNumber)
+		if err != nil {
+			return nil, fmt.Errorf("could not get path for %s: %s", billNumber, err)
+		}
+		docPaths = append(docPaths, filepath.Join(dataPath, billPath, "document.xml"))
+	}
+	for _, docPath := range compareRequest.Paths {
+		absPath, err := filepath.Abs(strings.TrimSpace(docPath))
+		if err != nil {
+			return nil, err
+		}
+		if rel, err := filepath.Rel(dataPath, absPath); err != nil || strings.HasPrefix(rel, "..") {
+			return nil, fmt.Errorf("path is not in the data directory: %s", docPath)
+		}
+		docPaths = append(docPaths, absPath)
+	}
+	return docPaths, nil
+}
+
+// Compares the bills in the request
+func (s *CompareServer) Compare(compareRequest CompareRequest) (compareResponse CompareResponse, status int, err error) {
+	docPaths, err := s.docPaths(compareRequest)
+	if err != nil {
+		return compareResponse, http.StatusBadRequest, err
+	}
+	if len(docPaths) == 0 {
+		return compareResponse, http.StatusBadRequest, fmt.Errorf("no bills to compare")
+	}
+	nGramMaps := make(docMaps)
+	for _, docPath := range docPaths {
+		docMapItem, err := s.getDocMap(docPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return compareResponse, http.StatusNotFound, fmt.Errorf("no bill document for %s", BillNumberFromPath(docPath))
+			}
+			return compareResponse, http.StatusInternalServerError, err
+		}
+		nGramMaps[docPath] = docMapItem
+	}
+	compareMatrix, err := compareFiles(nGramMaps, docPaths)
+	if err != nil {
+		return compareResponse, http.StatusInternalServerError, err
+	}
+	for _, docPath := range docPaths {
+		compareResponse.Bills = append(compareResponse.Bills, BillNumberFromPath(docPath))
+	}
+	compareResponse.CompareMatrix = compareMatrix
+	return compareResponse, http.StatusOK, nil
+}
+
+func writeJSONResponse(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Error().Msgf("Error writing response: %s", err)
+	}
+}
+
+func splitQueryList(value string) (list []string) {
+	for _, item := range strings.Split(value, ",") {
+		if strings.TrimSpace(item) != "" {
+			list = append(list, strings.TrimSpace(item))
+		}
+	}
+	return list
+}
+
+func (s *CompareServer) handleCompare(w http.ResponseWriter, r *http.Request) {
+	var compareRequest CompareRequest
+	switch r.Method {
+	case http.MethodGet:
+		compareRequest.Bills = splitQueryList(r.URL.Query().Get("bills"))
+		compareRequest.Paths = splitQueryList(r.URL.Query().Get("paths"))
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&compareRequest); err != nil {
+			writeJSONResponse(w, http.StatusBadRequest, compareErrorResponse{fmt.Sprintf("could not parse request: %s", err)})
+			return
+		}
+	default:
+		writeJSONResponse(w, http.StatusMethodNotAllowed, compareErrorResponse{"use GET or POST"})
+		return
+	}
+	log.Info().Msgf("Compare request for bills: %v, paths: %v", compareRequest.Bills, compareRequest.Paths)
+	compareResponse, status, err := s.Compare(compareRequest)
+	if err != nil {
+		log.Error().Msgf("Error comparing bills: %s", err)
+		writeJSONResponse(w, status, compareErrorResponse{err.Error()})
+		return
+	}
+	writeJSONResponse(w, status, compareResponse)
+}
+
+func (s *CompareServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(w, http.StatusOK, map[string]interface{}{"status": "ok", "cached_docs": s.CachedDocs()})
+}
+
+// Returns the http.Handler for the compare service
+func (s *CompareServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/compare", s.handleCompare)
+	mux.HandleFunc("/health", s.handleHealth)
+	return mux
+}
@@ -0,0 +1,59 @@
+package bills
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func postCompareRequest(t *testing.T, url string, compareRequest CompareRequest) (*http.Response, CompareResponse) {
+	body, _ := json.Marshal(compareRequest)
+	resp, err := http.Post(url+"/compare", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Error posting compare request: %v", err)
+	}
+	defer resp.Body.Close()
+	var compareResponse CompareResponse
+	json.NewDecoder(resp.Body).Decode(&compareResponse)
+	return resp, compareResponse
+}
+
+func TestCompareServer(t *testing.T) {
+	log.Info().Msg("Test the compare service")
+	testutils.SetLogLevel()
+	UseNgramCache = false
+	defer func() { UseNgramCache = true }()
+	compareServer := NewCompareServer("samples/congress/data", 0)
+	ts := httptest.NewServer(compareServer.Handler())
+	defer ts.Close()
+
+	resp, compareResponse := postCompareRequest(t, ts.URL, CompareRequest{Bills: []string{"116hr1500ih", "116hr1500eh"}})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"116hr1500ih", "116hr1500eh"}, compareResponse.Bills)
+	assert.Equal(t, 2, len(compareResponse.CompareMatrix))
+	assert.Equal(t, "116hr1500eh", compareResponse.CompareMatrix[0][1].TargetBill)
+	assert.Equal(t, getSampleCompareMatrix(t), compareResponse.CompareMatrix)
+	assert.Equal(t, 2, compareServer.CachedDocs())
+
+	// Paths and bill numbers can be combined; the documents already in memory are reused
+	getResp, err := http.Get(ts.URL + "/compare?bills=116hr1500ih&paths=" + sampleFilePathIH)
+	if err != nil {
+		t.Fatalf("Error getting compare request: %v", err)
+	}
+	getResp.Body.Close()
+	assert.Equal(t, http.StatusOK, getResp.StatusCode)
+	assert.Equal(t, 2, compareServer.CachedDocs())
+
+	resp, _ = postCompareRequest(t, ts.URL, CompareRequest{Bills: []string{"116hr9999ih"}})
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = postCompareRequest(t, ts.URL, CompareRequest{Bills: []string{"116hr1500"}})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = postCompareRequest(t, ts.URL, CompareRequest{Paths: []string{"billdiff.go"}})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -3,7 +3,6 @@ package bills
 import (
 	"path"
 	"regexp"
-	"sync"
 
 	"github.com/aih/bills/internal/projectpath"
 	"github.com/joho/godotenv"
@@ -36,21 +35,27 @@ var (
 	TitleNoYearIndex         = "titleNoYearIndexGo.json"
 	MainTitleNoYearIndex     = "mainTitleNoYearIndexGo.json"
 	BillsFile                = "billsGo.json"
+	BoilerplateNgramsFile    = "boilerplateNgramsGo.json"
+	BillMetaJsonFile         = "billMeta.json" // Saved in each bill directory, like the files below
+	RelatedDictFile          = "relatedDict.json"
+	EsSimilarityFile         = "esSimilarity.json"
+	EsSimilarBillsDictFile   = "esSimilarBillsDict.json"
+	EsSimilarCategoryFile    = "esSimilarCategory.json"
 	PathToCongressDataDir    = path.Join(ParentPathDefault, CongressDir)
 	BillMetaPath             = path.Join(ParentPathDefault, BillMetaFile)
 	BillSimilarityPath       = path.Join(ParentPathDefault, BillSimilarityFile)
 	TitleNoYearIndexPath     = path.Join(ParentPathDefault, TitleNoYearIndex)
 	MainTitleNoYearIndexPath = path.Join(ParentPathDefault, MainTitleNoYearIndex)
 	BillsPath                = path.Join(ParentPathDefault, BillsFile)
-	BillMetaSyncMap          = new(sync.Map)
-	// titleSyncMap                = new(sync.Map)
-	MainTitleNoYearSyncMap = new(sync.Map)
-	TitleNoYearSyncMap     = new(sync.Map)
-	MainTitleMatchReason   = "bills-title_match_main"
-	TitleMatchReason       = "bills-title_match"
-	IdentifiedByBillMap    = "BillMap"
-	BillVersionsOrdered    = billVersions{"ih": 0, "rh": 1, "rfs": 2, "eh": 3, "es": 4, "enr": 5}
-	ZLogLevels             = LogLevels{"Debug": zerolog.DebugLevel, "Info": zerolog.InfoLevel, "Error": zerolog.ErrorLevel}
+	BoilerplateNgramsPath    = path.Join(ParentPathDefault, BoilerplateNgramsFile)
+	MainTitleMatchReason     = "bills-title_match_main"
+	TitleMatchReason         = "bills-title_match"
+	TitleSimilarReason       = "bills-title_similar"
+	ReintroductionOfReason   = "bills-reintroduction_of"
+	ReintroducedAsReason     = "bills-reintroduced_as"
+	IdentifiedByBillMap      = "BillMap"
+	BillVersionsOrdered      = billVersions{"ih": 0, "rh": 1, "rfs": 2, "eh": 3, "es": 4, "enr": 5}
+	ZLogLevels               = LogLevels{"Debug": zerolog.DebugLevel, "Info": zerolog.InfoLevel, "Error": zerolog.ErrorLevel}
 )
 
 func LoadEnv() (err error) {
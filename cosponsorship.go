@@ -0,0 +1,344 @@
+package bills
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Output formats for WriteCosponsorshipNetwork
+const (
+	// The edge list, one line for each pair of cosponsor and sponsor
+	CosponsorshipFormatCSV = "csv"
+	// The summary, with the bipartisanship and top collaborators of each member
+	CosponsorshipFormatJSON = "json"
+)
+
+var CosponsorshipFormats = []string{CosponsorshipFormatCSV, CosponsorshipFormatJSON}
+
+// The bills that a member cosponsored and that another member sponsored
+type CosponsorshipEdge struct {
+	Cosponsor string `json:"cosponsor"`
+	Sponsor   string `json:"sponsor"`
+	Bills     int    `json:"bills"`
+	// The bills that the cosponsor cosponsored when they were introduced
+	OriginalBills int `json:"original_bills"`
+	// The bills for which the members were of different parties (not counting bills where the party of either is not known)
+	CrossPartyBills int `json:"cross_party_bills"`
+	knownPartyBills int
+}
+
+// A member with whom another member sponsors or cosponsors bills
+type Collaborator struct {
+	BioguideId string `json:"bioguide_id"`
+	Name       string `json:"name"`
+	Party      string `json:"party,omitempty"`
+	// Bills sponsored by one of the two members and cosponsored by the other
+	Bills int `json:"bills"`
+}
+
+type CosponsorshipMember struct {
+	BioguideId  string `json:"bioguide_id"`
+	Name        string `json:"name"`
+	Party       string `json:"party,omitempty"`
+	State       string `json:"state,omitempty"`
+	Chamber     string `json:"chamber,omitempty"`
+	Sponsored   int    `json:"sponsored"`
+	Cosponsored int    `json:"cosponsored"`
+	// Bills cosponsored that were sponsored by a member of another party
+	CrossPartyCosponsored int `json:"cross_party_cosponsored"`
+	// Cosponsors of the member's bills, and those of another party
+	CosponsorsReceived           int `json:"cosponsors_received"`
+	CrossPartyCosponsorsReceived int `json:"cross_party_cosponsors_received"`
+	// Share of the member's cosponsorships, given and received, that cross party lines
+	// (counting only those where the parties of both members are known)
+	Bipartisanship   float64        `json:"bipartisanship"`
+	TopCollaborators []Collaborator `json:"top_collaborators"`
+	// Date of the party: a member who changed party gets the party of their latest (co)sponsorship
+	partyAt string
+}
+
+// The cosponsorships given and received by the members of a party, counting only those where the parties of both members
+// are known. Members are counted in their latest party; cosponsorships in the party of the members when the bill was cosponsored.
+type CosponsorshipParty struct {
+	Party   string `json:"party"`
+	Members int    `json:"members"`
+	// Bills cosponsored by members of the party, and those sponsored by a member of another party
+	Given           int `json:"given"`
+	CrossPartyGiven int `json:"cross_party_given"`
+	// Cosponsors of the bills of members of the party, and those of another party
+	Received           int `json:"received"`
+	CrossPartyReceived int `json:"cross_party_received"`
+	// Share of the cosponsorships given, and received, that cross party lines
+	CrossPartyGivenShare    float64 `json:"cross_party_given_share"`
+	CrossPartyReceivedShare float64 `json:"cross_party_received_share"`
+}
+
+type CosponsorshipSummary struct {
+	Congress string `json:"congress"`
+	Bills    int    `json:"bills"`
+	Edges    int    `json:"edges"`
+	// Share of the cosponsorships between members of known parties that cross party lines
+	CrossPartyShare float64 `json:"cross_party_share"`
+	// The cosponsorships of each party, sorted by party
+	Parties []CosponsorshipParty  `json:"parties"`
+	Members []CosponsorshipMember `json:"members"`
+}
+
+// The member-by-member cosponsorship network of the bills of a congress. Each edge goes from a cosponsor to the sponsor,
+// weighted by the number of bills. The party of each member is taken from the sponsor and cosponsors in billMeta.json
+// (see LegislatorIndex.EnrichBillMeta) or, if not set there, from the legislators index.
+type CosponsorshipNetwork struct {
+	Congress    string
+	Bills       int
+	members     map[string]*CosponsorshipMember
+	edges       map[[2]string]*CosponsorshipEdge
+	parties     map[string]*CosponsorshipParty
+	legislators *LegislatorIndex
+}
+
+// Makes an empty network. The legislators index may be nil.
+func NewCosponsorshipNetwork(congress string, legislators *LegislatorIndex) *CosponsorshipNetwork {
+	return &CosponsorshipNetwork{
+		Congress:    congress,
+		members:     make(map[string]*CosponsorshipMember),
+		edges:       make(map[[2]string]*CosponsorshipEdge),
+		parties:     make(map[string]*CosponsorshipParty),
+		legislators: legislators,
+	}
+}
+
+// Adds the sponsor or cosponsor to the members, and gets their party when the bill was (co)sponsored
+func (network *CosponsorshipNetwork) addMember(cosponsor CosponsorItem, date string) (member *CosponsorshipMember, party string) {
+	cosponsor.SponsoredAt = date
+	if cosponsor.Party == "" {
+		cosponsor = network.legislators.EnrichCosponsor(cosponsor)
+	}
+	member, ok := network.members[cosponsor.BioguideId]
+	if !ok {
+		member = &CosponsorshipMember{BioguideId: cosponsor.BioguideId, Name: cosponsor.Name, State: cosponsor.State, Chamber: cosponsor.Chamber}
+		network.members[cosponsor.BioguideId] = member
+	}
+	if cosponsor.Party != "" && (member.Party == "" || date >= member.partyAt) {
+		member.Party = cosponsor.Party
+		member.partyAt = date
+	}
+	return member, cosponsor.Party
+}
+
+func (network *CosponsorshipNetwork) party(party string) *CosponsorshipParty {
+	cosponsorshipParty, ok := network.parties[party]
+	if !ok {
+		cosponsorshipParty = &CosponsorshipParty{Party: party}
+		network.parties[party] = cosponsorshipParty
+	}
+	return cosponsorshipParty
+}
+
+// Adds the sponsor and cosponsors of the bill to the network. Bills without a sponsor bioguide id are skipped.
+func (network *CosponsorshipNetwork) AddBill(billMeta BillMeta) {
+	if billMeta.Sponsor.BioguideId == "" {
+		return
+	}
+	network.Bills++
+	sponsor, sponsorParty := network.addMember(billMeta.Sponsor, billMeta.IntroducedAt)
+	sponsor.Sponsored++
+	for _, cosponsor := range billMeta.Cosponsors {
+		if cosponsor.BioguideId == "" || cosponsor.BioguideId == sponsor.BioguideId {
+			continue
+		}
+		date := cosponsor.SponsoredAt
+		if date == "" {
+			date = billMeta.IntroducedAt
+		}
+		member, party := network.addMember(cosponsor, date)
+		member.Cosponsored++
+		sponsor.CosponsorsReceived++
+		knownParty := party != "" && sponsorParty != ""
+		crossParty := knownParty && party != sponsorParty
+		if crossParty {
+			member.CrossPartyCosponsored++
+			sponsor.CrossPartyCosponsorsReceived++
+		}
+		if knownParty {
+			givingParty, receivingParty := network.party(party), network.party(sponsorParty)
+			givingParty.Given++
+			receivingParty.Received++
+			if crossParty {
+				givingParty.CrossPartyGiven++
+				receivingParty.CrossPartyReceived++
+			}
+		}
+		key := [2]string{member.BioguideId, sponsor.BioguideId}
+		edge, ok := network.edges[key]
+		if !ok {
+			edge = &CosponsorshipEdge{Cosponsor: member.BioguideId, Sponsor: sponsor.BioguideId}
+			network.edges[key] = edge
+		}
+		edge.Bills++
+		if cosponsor.OriginalCosponsor {
+			edge.OriginalBills++
+		}
+		if knownParty {
+			edge.knownPartyBills++
+		}
+		if crossParty {
+			edge.CrossPartyBills++
+		}
+	}
+}
+
+// Gets the edges, with the most bills first
+func (network *CosponsorshipNetwork) Edges() []CosponsorshipEdge {
+	edges := make([]CosponsorshipEdge, 0, len(network.edges))
+	for _, edge := range network.edges {
+		edges = append(edges, *edge)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Bills != edges[j].Bills {
+			return edges[i].Bills > edges[j].Bills
+		}
+		if edges[i].Cosponsor != edges[j].Cosponsor {
+			return edges[i].Cosponsor < edges[j].Cosponsor
+		}
+		return edges[i].Sponsor < edges[j].Sponsor
+	})
+	return edges
+}
+
+// Gets the members that the member shares the most bills with (as sponsor or cosponsor), at most topN
+func (network *CosponsorshipNetwork) TopCollaborators(bioguideId string, topN int) []Collaborator {
+	bills := make(map[string]int)
+	for key, edge := range network.edges {
+		if key[0] == bioguideId {
+			bills[key[1]] += edge.Bills
+		} else if key[1] == bioguideId {
+			bills[key[0]] += edge.Bills
+		}
+	}
+	collaborators := []Collaborator{}
+	for collaboratorId, count := range bills {
+		collaborator := Collaborator{BioguideId: collaboratorId, Bills: count}
+		if member, ok := network.members[collaboratorId]; ok {
+			collaborator.Name = member.Name
+			collaborator.Party = member.Party
+		}
+		collaborators = append(collaborators, collaborator)
+	}
+	sort.Slice(collaborators, func(i, j int) bool {
+		if collaborators[i].Bills != collaborators[j].Bills {
+			return collaborators[i].Bills > collaborators[j].Bills
+		}
+		return collaborators[i].BioguideId < collaborators[j].BioguideId
+	})
+	if topN > 0 && len(collaborators) > topN {
+		collaborators = collaborators[:topN]
+	}
+	return collaborators
+}
+
+// Summarizes the network, with the topN collaborators of each member. Members are sorted by bioguide id.
+func (network *CosponsorshipNetwork) Summary(topN int) CosponsorshipSummary {
+	summary := CosponsorshipSummary{Congress: network.Congress, Bills: network.Bills, Edges: len(network.edges), Members: []CosponsorshipMember{}}
+	knownParty, crossParty := 0, 0
+	for _, member := range network.members {
+		summaryMember := *member
+		summaryMember.partyAt = ""
+		summaryMember.TopCollaborators = network.TopCollaborators(member.BioguideId, topN)
+		summary.Members = append(summary.Members, summaryMember)
+	}
+	sort.Slice(summary.Members, func(i, j int) bool {
+		return summary.Members[i].BioguideId < summary.Members[j].BioguideId
+	})
+	// Bipartisanship is counted per cosponsorship, with the parties of the members when the bill was cosponsored
+	memberKnown := make(map[string]int)
+	memberCross := make(map[string]int)
+	for _, edge := range network.edges {
+		knownParty += edge.knownPartyBills
+		crossParty += edge.CrossPartyBills
+		for _, bioguideId := range []string{edge.Cosponsor, edge.Sponsor} {
+			memberKnown[bioguideId] += edge.knownPartyBills
+			memberCross[bioguideId] += edge.CrossPartyBills
+		}
+	}
+	if knownParty > 0 {
+		summary.CrossPartyShare = roundShare(float64(crossParty) / float64(knownParty))
+	}
+	for i, member := range summary.Members {
+		if known := memberKnown[member.BioguideId]; known > 0 {
+			summary.Members[i].Bipartisanship = roundShare(float64(memberCross[member.BioguideId]) / float64(known))
+		}
+	}
+
+	parties := make(map[string]CosponsorshipParty)
+	for party, cosponsorshipParty := range network.parties {
+		parties[party] = *cosponsorshipParty
+	}
+	for _, member := range network.members {
+		if member.Party != "" {
+			cosponsorshipParty := parties[member.Party]
+			cosponsorshipParty.Party = member.Party
+			cosponsorshipParty.Members++
+			parties[member.Party] = cosponsorshipParty
+		}
+	}
+	summary.Parties = []CosponsorshipParty{}
+	for _, cosponsorshipParty := range parties {
+		if cosponsorshipParty.Given > 0 {
+			cosponsorshipParty.CrossPartyGivenShare = roundShare(float64(cosponsorshipParty.CrossPartyGiven) / float64(cosponsorshipParty.Given))
+		}
+		if cosponsorshipParty.Received > 0 {
+			cosponsorshipParty.CrossPartyReceivedShare = roundShare(float64(cosponsorshipParty.CrossPartyReceived) / float64(cosponsorshipParty.Received))
+		}
+		summary.Parties = append(summary.Parties, cosponsorshipParty)
+	}
+	sort.Slice(summary.Parties, func(i, j int) bool {
+		return summary.Parties[i].Party < summary.Parties[j].Party
+	})
+	return summary
+}
+
+func roundShare(share float64) float64 {
+	return float64(int(share*1000+0.5)) / 1000
+}
+
+// Makes the cosponsorship network from the metadata of the bills of the congress (e.g. "116") in the store
+func LoadCosponsorshipNetwork(billStore BillStore, congress string, legislators *LegislatorIndex) (*CosponsorshipNetwork, error) {
+	billMetas, err := billStore.QueryBillMeta(BillMetaQuery{Congress: congress})
+	if err != nil {
+		return nil, err
+	}
+	network := NewCosponsorshipNetwork(congress, legislators)
+	for _, billMeta := range billMetas {
+		network.AddBill(billMeta)
+	}
+	return network, nil
+}
+
+// Writes the network to w, in one of the formats:
+// CosponsorshipFormatCSV: the edge list, with the names and parties of the members
+// CosponsorshipFormatJSON: the summary, with the topN collaborators of each member
+func WriteCosponsorshipNetwork(w io.Writer, network *CosponsorshipNetwork, format string, topN int) error {
+	switch format {
+	case CosponsorshipFormatCSV:
+		csvWriter := csv.NewWriter(w)
+		csvWriter.Write([]string{"cosponsor", "cosponsor_name", "cosponsor_party", "sponsor", "sponsor_name", "sponsor_party", "bills", "original_bills", "cross_party_bills"})
+		for _, edge := range network.Edges() {
+			cosponsor, sponsor := network.members[edge.Cosponsor], network.members[edge.Sponsor]
+			csvWriter.Write([]string{edge.Cosponsor, cosponsor.Name, cosponsor.Party, edge.Sponsor, sponsor.Name, sponsor.Party,
+				strconv.Itoa(edge.Bills), strconv.Itoa(edge.OriginalBills), strconv.Itoa(edge.CrossPartyBills)})
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	case CosponsorshipFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", " ")
+		return encoder.Encode(network.Summary(topN))
+	default:
+		return fmt.Errorf("unknown cosponsorship format: %s (options: %s)", format, strings.Join(CosponsorshipFormats, ", "))
+	}
+}
@@ -0,0 +1,131 @@
+package bills
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"path"
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// Waters sponsors two bills, cosponsored by Adams and by Amash (a Republican until July 2019, then an Independent);
+// Amash sponsors a bill cosponsored by Waters. The parties are taken from the sample legislators, except where set.
+var sampleCosponsorshipBills = []BillMeta{
+	{BillCongressTypeNumber: "116hr1", Congress: "116", IntroducedAt: "2019-03-05",
+		Sponsor: CosponsorItem{BioguideId: "W000187", Name: "Waters, Maxine"},
+		Cosponsors: []CosponsorItem{
+			{BioguideId: "A000370", Name: "Adams, Alma S.", OriginalCosponsor: true, SponsoredAt: "2019-03-05"},
+			{BioguideId: "A000367", Name: "Amash, Justin", SponsoredAt: "2019-04-01"},
+		}},
+	{BillCongressTypeNumber: "116hr2", Congress: "116", IntroducedAt: "2019-09-01",
+		Sponsor: CosponsorItem{BioguideId: "W000187", Name: "Waters, Maxine", Party: "Democrat"},
+		Cosponsors: []CosponsorItem{
+			{BioguideId: "A000370", Name: "Adams, Alma S.", OriginalCosponsor: true},
+			{BioguideId: "A000367", Name: "Amash, Justin", OriginalCosponsor: true},
+		}},
+	{BillCongressTypeNumber: "116hr3", Congress: "116", IntroducedAt: "2019-05-01",
+		Sponsor: CosponsorItem{BioguideId: "A000367", Name: "Amash, Justin"},
+		Cosponsors: []CosponsorItem{
+			{BioguideId: "W000187", Name: "Waters, Maxine", SponsoredAt: "2019-05-02"},
+			// The sponsor is not counted as a cosponsor
+			{BioguideId: "A000367", Name: "Amash, Justin"},
+		}},
+	// No sponsor
+	{BillCongressTypeNumber: "116hr4", Congress: "116"},
+}
+
+func sampleCosponsorshipNetwork(t *testing.T) *CosponsorshipNetwork {
+	legislators, err := ReadLegislatorIndex(sampleLegislatorsPath, sampleLegislatorsHistoricalPath)
+	assert.Nil(t, err)
+	network := NewCosponsorshipNetwork("116", legislators)
+	for _, billMeta := range sampleCosponsorshipBills {
+		network.AddBill(billMeta)
+	}
+	return network
+}
+
+func TestCosponsorshipNetwork(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test building the cosponsorship network")
+	network := sampleCosponsorshipNetwork(t)
+	assert.Equal(t, 3, network.Bills)
+
+	edges := network.Edges()
+	assert.Equal(t, 3, len(edges))
+	assert.Equal(t, CosponsorshipEdge{Cosponsor: "A000367", Sponsor: "W000187", Bills: 2, OriginalBills: 1, CrossPartyBills: 2, knownPartyBills: 2}, edges[0])
+	assert.Equal(t, CosponsorshipEdge{Cosponsor: "A000370", Sponsor: "W000187", Bills: 2, OriginalBills: 2, knownPartyBills: 2}, edges[1])
+	assert.Equal(t, "A000367", edges[2].Sponsor)
+	assert.Equal(t, 1, edges[2].CrossPartyBills)
+
+	collaborators := network.TopCollaborators("W000187", 1)
+	assert.Equal(t, []Collaborator{{BioguideId: "A000367", Name: "Amash, Justin", Party: "Independent", Bills: 3}}, collaborators)
+
+	summary := network.Summary(5)
+	// 3 of the 5 cosponsorships are between members of different parties
+	assert.Equal(t, 0.6, summary.CrossPartyShare)
+	assert.Equal(t, []string{"A000367", "A000370", "W000187"}, []string{summary.Members[0].BioguideId, summary.Members[1].BioguideId, summary.Members[2].BioguideId})
+	amash := summary.Members[0]
+	assert.Equal(t, 1, amash.Sponsored)
+	assert.Equal(t, 2, amash.Cosponsored)
+	assert.Equal(t, 2, amash.CrossPartyCosponsored)
+	assert.Equal(t, 1.0, amash.Bipartisanship)
+	waters := summary.Members[2]
+	assert.Equal(t, 4, waters.CosponsorsReceived)
+	assert.Equal(t, 2, waters.CrossPartyCosponsorsReceived)
+	assert.Equal(t, 0.6, waters.Bipartisanship)
+	assert.Equal(t, 2, len(waters.TopCollaborators))
+	assert.Equal(t, 0.0, summary.Members[1].Bipartisanship)
+
+	// Amash cosponsored as a Republican and as an Independent, and sponsored as a Republican
+	assert.Equal(t, []CosponsorshipParty{
+		{Party: "Democrat", Members: 2, Given: 3, CrossPartyGiven: 1, Received: 4, CrossPartyReceived: 2, CrossPartyGivenShare: 0.333, CrossPartyReceivedShare: 0.5},
+		{Party: "Independent", Members: 1, Given: 1, CrossPartyGiven: 1, CrossPartyGivenShare: 1},
+		{Party: "Republican", Given: 1, CrossPartyGiven: 1, Received: 1, CrossPartyReceived: 1, CrossPartyGivenShare: 1, CrossPartyReceivedShare: 1},
+	}, summary.Parties)
+}
+
+func TestWriteCosponsorshipNetwork(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test writing the cosponsorship network as CSV and JSON")
+	network := sampleCosponsorshipNetwork(t)
+
+	var buf bytes.Buffer
+	assert.Nil(t, WriteCosponsorshipNetwork(&buf, network, CosponsorshipFormatCSV, 0))
+	records, err := csv.NewReader(&buf).ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(records))
+	assert.Equal(t, []string{"A000370", "Adams, Alma S.", "Democrat", "W000187", "Waters, Maxine", "Democrat", "2", "2", "0"}, records[2])
+
+	buf.Reset()
+	assert.Nil(t, WriteCosponsorshipNetwork(&buf, network, CosponsorshipFormatJSON, 1))
+	var summary CosponsorshipSummary
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &summary))
+	assert.Equal(t, 3, len(summary.Members))
+	assert.Equal(t, 1, len(summary.Members[2].TopCollaborators))
+
+	assert.NotNil(t, WriteCosponsorshipNetwork(&buf, network, "graphml", 0))
+}
+
+func TestLoadCosponsorshipNetwork(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test loading the cosponsorship network from a store")
+	billStore, err := OpenBadgerBillStore(path.Join(t.TempDir(), "badger"))
+	assert.Nil(t, err)
+	defer billStore.Close()
+	for _, billMeta := range sampleCosponsorshipBills {
+		assert.Nil(t, billStore.PutBillMeta(billMeta))
+	}
+	assert.Nil(t, billStore.PutBillMeta(BillMeta{BillCongressTypeNumber: "117hr1", Congress: "117", Sponsor: CosponsorItem{BioguideId: "W000187"}}))
+
+	network, err := LoadCosponsorshipNetwork(billStore, "116", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, network.Bills)
+	// Without the legislators index, only the party set in the metadata is known
+	assert.Equal(t, 0.0, network.Summary(0).CrossPartyShare)
+	assert.Equal(t, []CosponsorshipParty{{Party: "Democrat", Members: 1}}, network.Summary(0).Parties)
+	assert.Equal(t, "Democrat", network.Summary(0).Members[2].Party)
+}
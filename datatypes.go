@@ -10,19 +10,29 @@ type TitlesJson struct {
 }
 
 type BillMeta struct {
-	Actions                  []ActionItem      `json:"actions"`
-	Congress                 string            `json:"congress"`
-	BillType                 string            `json:"bill_type"`
-	Number                   string            `json:"number"`
-	BillCongressTypeNumber   string            `json:"bill_congress_type_number"`
-	History                  interface{}       `json:"history"`
-	OfficialTitle            string            `json:"official_title"`
-	PopularTitle             string            `json:"popular_title"`
-	ShortTitle               string            `json:"short_title"`
-	Titles                   []string          `json:"titles"`
-	TitlesWholeBill          []string          `json:"titles_whole_bill"`
-	Cosponsors               []CosponsorItem   `json:"cosponsors"`
-	Committees               []CommitteeItem   `json:"committees"`
+	Actions                []ActionItem    `json:"actions"`
+	Congress               string          `json:"congress"`
+	BillType               string          `json:"bill_type"`
+	Number                 string          `json:"number"`
+	BillCongressTypeNumber string          `json:"bill_congress_type_number"`
+	History                interface{}     `json:"history"`
+	OfficialTitle          string          `json:"official_title"`
+	PopularTitle           string          `json:"popular_title"`
+	ShortTitle             string          `json:"short_title"`
+	Titles                 []string        `json:"titles"`
+	TitlesWholeBill        []string        `json:"titles_whole_bill"`
+	IntroducedAt           string          `json:"introduced_at"`
+	Sponsor                CosponsorItem   `json:"sponsor"`
+	Cosponsors             []CosponsorItem `json:"cosponsors"`
+	Committees             []CommitteeItem `json:"committees"`
+	Subjects               []string        `json:"subjects,omitempty"`
+	SubjectsTopTerm        string          `json:"subjects_top_term,omitempty"`
+	// Added from the committee membership (see CommitteeMembership.EnrichBillMeta)
+	ReferralCommitteeCosponsors []CommitteeCosponsor `json:"referral_committee_cosponsors,omitempty"`
+	// The most likely earlier version of the bill in a previous congress, and the later bills that reintroduce it
+	// (see ReintroductionDetector)
+	ReintroductionOf         *Reintroduction   `json:"reintroduction_of,omitempty"`
+	ReintroducedAs           []Reintroduction  `json:"reintroduced_as,omitempty"`
 	RelatedBills             []RelatedBillItem `json:"related_bills"`
 	RelatedBillsByBillnumber RelatedBillMap    `json:"related_dict"`
 }
@@ -73,6 +83,9 @@ type CosponsorItem struct {
 	State             string `json:"state"`
 	Title             string `json:"title"`
 	// WithdrawnAt       string `json:"withdrawn_at"`
+	// Added from the legislators index (see LegislatorIndex.EnrichCosponsor)
+	Party   string `json:"party,omitempty"`
+	Chamber string `json:"chamber,omitempty"`
 }
 
 type CommitteeItem struct {
@@ -80,19 +93,29 @@ type CommitteeItem struct {
 	Committee      string   `json:"committee"`
 	CommitteeId    string   `json:"committee_id"`
 	Subcommittee   string   `json:"subcommittee"`
-	SubcommitteeId string   `json:"subcomittee_id"`
+	SubcommitteeId string   `json:"subcommittee_id"`
+	// Added from the committee registry (see CommitteeRegistry.EnrichCommittee)
+	CommitteeName    string `json:"committee_name,omitempty"`
+	SubcommitteeName string `json:"subcommittee_name,omitempty"`
+	Jurisdiction     string `json:"jurisdiction,omitempty"`
 }
 
 type RelatedBillItem struct {
-	BillId                 string `json:"bill_id"`
-	IdentifiedBy           string `json:"identified_by"`
-	Reason                 string `json:"reason"`
-	Type                   string `json:"type"`
-	BillCongressTypeNumber string `json:"bill_congress_type_number"`
+	BillId string `json:"bill_id"`
+	// The sources and reasons, as strings of items joined by ", " in JSON (see SourceSet and ReasonSet)
+	IdentifiedBy           SourceSet `json:"identified_by"`
+	Reason                 ReasonSet `json:"reason"`
+	Type                   string    `json:"type"`
+	BillCongressTypeNumber string    `json:"bill_congress_type_number"`
 	//Sponsor                CosponsorItem   `json:"sponsor"`
 	//Cosponsors             []CosponsorItem `json:"cosponsors"`
 	Titles          []string `json:"titles"`
 	TitlesWholeBill []string `json:"titles_whole_bill"`
+	// For bills-title_similar: the most similar titles of the two bills and their similarity (0 to 1; see TitleMatcher)
+	SimilarTitles   []string `json:"similar_titles,omitempty"`
+	TitleSimilarity float64  `json:"title_similarity,omitempty"`
+	// Where each reason came from (see RelatedBillItem.AddReason)
+	Provenance map[string]ReasonProvenance `json:"provenance,omitempty"`
 }
 
 type RelatedBillMap map[string]RelatedBillItem
@@ -163,7 +186,7 @@ type DataJson struct {
 	PopularTitle     string            `json:"popular_title"`
 	RelatedBills     []RelatedBillItem `json:"related_bills"`
 	ShortTitle       string            `json:"short_title"`
-	Sponsor          string            `json:"sponsor"`
+	Sponsor          CosponsorItem     `json:"sponsor"`
 	Status           string            `json:"status"`
 	StatusAt         string            `json:"status_at"`
 	Subjects         []interface{}     `json:"subjects"`
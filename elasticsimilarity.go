@@ -23,8 +23,34 @@ func getRandomSliceSectionItems(slice []SectionItem, num_items int) []SectionIte
 	return random_slice
 }
 
+// Finds the sections of other bills that are similar to the sections of a bill: Elasticsearch (ElasticSectionSearcher)
+// or an in-memory index of the bill sections (SectionIndex)
+type SectionSearcher interface {
+	// Gets the latest version of the bill, with its sections
+	LatestBillItem(billNumber string) (BillItemES, error)
+	SectionItemQuery(sectionItem SectionItem) SimilarSectionsItem
+}
+
+// Searches the 'billsections' index of the Elasticsearch cluster
+type ElasticSectionSearcher struct{}
+
+func (ElasticSectionSearcher) LatestBillItem(billNumber string) (BillItemES, error) {
+	r := GetBill_ES(billNumber)
+	log.Info().Msgf("Number of versions of %s:, %d", billNumber, len(r["hits"].(map[string]interface{})["hits"].([]interface{})))
+	return GetLatestBill(r)
+}
+
+func (ElasticSectionSearcher) SectionItemQuery(sectionItem SectionItem) SimilarSectionsItem {
+	return SectionItemQuery(sectionItem)
+}
+
 // Set sample size to <= 0 to use all sections
 func GetSimilaritySectionsByBillNumber(billItem BillItemES, samplesize int) (similarSectionsItems SimilarSectionsItems) {
+	return GetSimilaritySections(ElasticSectionSearcher{}, billItem, samplesize)
+}
+
+// Gets the similar sections for each section of the bill from the searcher. Set sample size to <= 0 to use all sections
+func GetSimilaritySections(searcher SectionSearcher, billItem BillItemES, samplesize int) (similarSectionsItems SimilarSectionsItems) {
 	billversion := billItem.BillVersion
 	billNumber := billItem.BillNumber
 	billnumberversion := billNumber + billversion
@@ -45,7 +71,7 @@ func GetSimilaritySectionsByBillNumber(billItem BillItemES, samplesize int) (sim
 
 		// TODO this can be made concurrent
 		// Send the query out, collect the results put them in order by sectionIndex
-		similarSectionsItem := SectionItemQuery(sectionItem)
+		similarSectionsItem := searcher.SectionItemQuery(sectionItem)
 		similarSectionsItems = append(similarSectionsItems, similarSectionsItem)
 	}
 	log.Debug().Msgf("number of similarSectionsItems: %d\n", len(similarSectionsItems))
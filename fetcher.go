@@ -0,0 +1,162 @@
+package bills
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Suffix of the file that holds the cache headers of a downloaded file (e.g. tmp/committees.yaml.cache.json)
+const FetchCacheSuffix = ".cache.json"
+
+// Directory for the reference data downloaded from the unitedstates congress-legislators project (committees.yaml, legislators.yaml)
+var ReferenceCacheDir = "tmp"
+
+// Downloads files to a cache directory. A file that is already in the cache is requested with
+// If-None-Match and If-Modified-Since, so it is only downloaded again if it changed upstream.
+// If the server cannot be reached, the cached file is used.
+type Fetcher struct {
+	CacheDir string
+	Client   *http.Client
+}
+
+// The outcome of a fetch, with the checksum of the file in the cache
+type FetchResult struct {
+	Url          string    `json:"url"`
+	Path         string    `json:"path"`
+	Sha256       string    `json:"sha256"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	// The server reported that the cached file is current
+	NotModified bool `json:"not_modified"`
+	// The server could not be reached and the cached file was used
+	Offline bool `json:"offline"`
+}
+
+func NewFetcher(cacheDir string) *Fetcher {
+	return &Fetcher{CacheDir: cacheDir, Client: &http.Client{Timeout: 5 * time.Minute}}
+}
+
+func readFetchCache(cachePath string) (cached FetchResult, ok bool) {
+	data, err := ioutil.ReadFile(cachePath + FetchCacheSuffix)
+	if err != nil {
+		return cached, false
+	}
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return cached, false
+	}
+	if _, err := os.Stat(cachePath); err != nil {
+		return cached, false
+	}
+	return cached, true
+}
+
+func fileSha256(filePath string) (sum string, size int64, err error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return "", 0, err
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), int64(len(data)), nil
+}
+
+// Fetches the url to fileName in the cache directory
+func (f *Fetcher) Fetch(url string, fileName string) (result FetchResult, err error) {
+	if err := os.MkdirAll(f.CacheDir, os.ModePerm); err != nil {
+		return result, err
+	}
+	cachePath := path.Join(f.CacheDir, fileName)
+	cached, isCached := readFetchCache(cachePath)
+	if isCached && cached.Url != url {
+		isCached = false
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return result, err
+	}
+	if isCached {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if isCached {
+			log.Warn().Msgf("Could not fetch %s (%s); using the cached %s", url, err, cachePath)
+			return f.cachedResult(cached, cachePath, false)
+		}
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && isCached:
+		log.Debug().Msgf("Not modified: %s", url)
+		return f.cachedResult(cached, cachePath, true)
+	case resp.StatusCode != http.StatusOK:
+		if isCached && resp.StatusCode >= http.StatusInternalServerError {
+			log.Warn().Msgf("Could not fetch %s (%s); using the cached %s", url, resp.Status, cachePath)
+			return f.cachedResult(cached, cachePath, false)
+		}
+		return result, fmt.Errorf("error fetching %s: %s", url, resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return result, fmt.Errorf("error reading %s: %w", url, err)
+	}
+	if err := WriteFileAtomic(cachePath, data, 0644); err != nil {
+		return result, err
+	}
+	hash := sha256.Sum256(data)
+	result = FetchResult{
+		Url:          url,
+		Path:         cachePath,
+		Sha256:       hex.EncodeToString(hash[:]),
+		Size:         int64(len(data)),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now().UTC(),
+	}
+	cacheData, _ := json.MarshalIndent(result, "", " ")
+	if err := WriteFileAtomic(cachePath+FetchCacheSuffix, cacheData, 0644); err != nil {
+		return result, err
+	}
+	log.Info().Msgf("Downloaded %s to %s (%d bytes, sha256 %s)", url, cachePath, result.Size, result.Sha256)
+	return result, nil
+}
+
+// The result for the cached file, with its checksum computed from the file on disk
+func (f *Fetcher) cachedResult(cached FetchResult, cachePath string, notModified bool) (FetchResult, error) {
+	sum, size, err := fileSha256(cachePath)
+	if err != nil {
+		return cached, err
+	}
+	if cached.Sha256 != "" && cached.Sha256 != sum {
+		log.Warn().Msgf("Checksum of %s changed since it was downloaded (%s, now %s)", cachePath, cached.Sha256, sum)
+	}
+	cached.Path = cachePath
+	cached.Sha256 = sum
+	cached.Size = size
+	cached.NotModified = notModified
+	cached.Offline = !notModified
+	return cached, nil
+}
@@ -0,0 +1,75 @@
+package bills
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// Serves the file with an ETag, and counts the requests that download it
+func newReferenceDataServer(t *testing.T, filePath string) (server *httptest.Server, downloads *int) {
+	data, err := ioutil.ReadFile(filePath)
+	assert.Nil(t, err)
+	downloads = new(int)
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"v1"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		*downloads++
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", "Mon, 04 Jan 2021 00:00:00 GMT")
+		w.Write(data)
+	}))
+	return server, downloads
+}
+
+func TestFetcher(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test fetching reference data with caching")
+	server, downloads := newReferenceDataServer(t, sampleCommitteesPath)
+	fetcher := NewFetcher(path.Join(t.TempDir(), "cache"))
+
+	result, err := fetcher.Fetch(server.URL+"/committees-current.yaml", CommitteesYamlFile)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, *downloads)
+	assert.False(t, result.NotModified)
+	assert.Equal(t, `"v1"`, result.ETag)
+	assert.Equal(t, 64, len(result.Sha256))
+	sum, size, err := fileSha256(result.Path)
+	assert.Nil(t, err)
+	assert.Equal(t, result.Sha256, sum)
+	assert.Equal(t, result.Size, size)
+
+	// The cached file is current
+	cachedResult, err := fetcher.Fetch(server.URL+"/committees-current.yaml", CommitteesYamlFile)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, *downloads)
+	assert.True(t, cachedResult.NotModified)
+	assert.Equal(t, result.Sha256, cachedResult.Sha256)
+
+	// Offline, the cached file is used; without a cached file, it is an error
+	server.Close()
+	cachedResult, err = fetcher.Fetch(server.URL+"/committees-current.yaml", CommitteesYamlFile)
+	assert.Nil(t, err)
+	assert.True(t, cachedResult.Offline)
+	assert.Equal(t, result.Path, cachedResult.Path)
+	_, err = fetcher.Fetch(server.URL+"/legislators-current.yaml", LegislatorsYamlFile)
+	assert.NotNil(t, err)
+}
+
+func TestFetcherNotFound(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test fetching reference data that is not on the server")
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	_, err := NewFetcher(t.TempDir()).Fetch(server.URL+"/committees-current.yaml", CommitteesYamlFile)
+	assert.NotNil(t, err)
+}
@@ -171,3 +171,35 @@ func Prepend(filepath string, text string) error {
 
 	return nil
 }
+
+// Writes the data to a temporary file in the same directory and renames it to filePath,
+// so that readers (and a crash mid-write) never see a partly written file
+func WriteFileAtomic(filePath string, data []byte, perm os.FileMode) (err error) {
+	dir, fileName := filepath.Split(filePath)
+	if dir == "" {
+		dir = "."
+	}
+	tmpFile, err := os.CreateTemp(dir, fileName+TmpFileSuffix)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmpFile.Close()
+			os.Remove(tmpFile.Name())
+		}
+	}()
+	if _, err = tmpFile.Write(data); err != nil {
+		return err
+	}
+	if err = tmpFile.Sync(); err != nil {
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpFile.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), filePath)
+}
@@ -0,0 +1,278 @@
+package bills
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Output formats for WriteIncorporationReport
+const (
+	IncorporationFormatJSON = "json"
+	IncorporationFormatCSV  = "csv"
+)
+
+// How the coverage of a source bill was measured
+const (
+	// The share of the sections of one version of the source bill (the version in its esSimilarity.json) that match a section of the enacted bill
+	CoverageBySections = "sections"
+	// The share of the text (n-grams) of the source bill found in the enacted bill, from esSimilarCategory.json of the enacted bill
+	CoverageByText = "text"
+)
+
+var IncorporationFormats = []string{IncorporationFormatJSON, IncorporationFormatCSV}
+
+// A section of the enacted bill that matches a section of a source bill
+type IncorporatedSection struct {
+	EnactedSectionNum       string  `json:"enacted_section_num"`
+	EnactedSectionHeader    string  `json:"enacted_section_header"`
+	EnactedSectionIndex     string  `json:"enacted_section_index"`
+	SourceBillNumberVersion string  `json:"source_bill_number_version"`
+	SourceSectionNum        string  `json:"source_section_num"`
+	SourceSectionHeader     string  `json:"source_section_header"`
+	Score                   float32 `json:"score"`
+}
+
+// The sections of a source bill that were compared to other bills (in its esSimilarity.json)
+type SourceSectionCount struct {
+	BillNumberVersion string
+	Sections          int
+	// The sections are a sample of the sections of the version (esquery -samplesize)
+	Sampled bool
+}
+
+// A bill with sections in the enacted bill
+type IncorporationSource struct {
+	BillNumber string `json:"bill_number"`
+	Title      string `json:"title,omitempty"`
+	// Similarity category of the enacted bill to this bill (e.g. bills-incorporates), if the bills were compared
+	Category string                `json:"category,omitempty"`
+	Sections []IncorporatedSection `json:"sections"`
+	// Number of distinct sections of this bill that match the enacted bill (in the version of SourceSectionCount, if known),
+	// and the number of sections of this bill (0 if unknown or only a sample of the sections was compared)
+	MatchedSections int `json:"matched_sections"`
+	SourceSections  int `json:"source_sections"`
+	// Percentage of this bill found in the enacted bill, measured as in CoverageBasis
+	Coverage      float64 `json:"coverage"`
+	CoverageBasis string  `json:"coverage_basis"`
+}
+
+// The bills incorporated into an enacted bill, from highest to lowest coverage
+type IncorporationReport struct {
+	BillNumber        string                `json:"bill_number"`
+	BillNumberVersion string                `json:"bill_number_version"`
+	EnactedSections   int                   `json:"enacted_sections"`
+	Sources           []IncorporationSource `json:"sources"`
+}
+
+func roundPercent(fraction float64) float64 {
+	if fraction > 1 {
+		fraction = 1
+	}
+	return float64(int(fraction*1000+0.5)) / 10
+}
+
+// Makes the incorporation report for the enacted bill from its esSimilarity.json (similarSectionsItems) and
+// esSimilarCategory.json (similarCategory). sourceSectionCounts has the number of sections of each source bill, where known.
+// Coverage by sections counts the matching sections of the same version of the source bill; if its sections are a sample,
+// coverage is by text, or unknown (empty CoverageBasis) if the bills were not compared.
+// Section matches with a score below minScore are ignored. Bills compared as incorporated (see IncorporatesReason) are listed
+// even if none of their sections match.
+func MakeIncorporationReport(billNumber string, similarSectionsItems SimilarSectionsItems, similarCategory map[string]CompareItem, sourceSectionCounts map[string]SourceSectionCount, minScore float32) (report IncorporationReport) {
+	report.BillNumber = billNumber
+	report.EnactedSections = len(similarSectionsItems)
+	report.Sources = []IncorporationSource{}
+	sources := make(map[string]*IncorporationSource)
+	// The matching sections of each version of each source bill
+	matchedSections := make(map[string]map[string]map[string]bool)
+	for _, similarSectionsItem := range similarSectionsItems {
+		if report.BillNumberVersion == "" {
+			report.BillNumberVersion = similarSectionsItem.BillNumberVersion
+		}
+		// The best match in each source bill for this section of the enacted bill
+		bestSections := make(map[string]SimilarSection)
+		for _, similarSection := range similarSectionsItem.SimilarSections {
+			if similarSection.Billnumber == billNumber || similarSection.Billnumber == "" || similarSection.Score < minScore {
+				continue
+			}
+			if best, ok := bestSections[similarSection.Billnumber]; !ok || similarSection.Score > best.Score {
+				bestSections[similarSection.Billnumber] = similarSection
+			}
+			versionSections := matchedSections[similarSection.Billnumber]
+			if versionSections == nil {
+				versionSections = make(map[string]map[string]bool)
+				matchedSections[similarSection.Billnumber] = versionSections
+			}
+			if versionSections[similarSection.BillCongressTypeNumberVersion] == nil {
+				versionSections[similarSection.BillCongressTypeNumberVersion] = make(map[string]bool)
+			}
+			versionSections[similarSection.BillCongressTypeNumberVersion][similarSection.SectionNum+"|"+similarSection.SectionHeader] = true
+		}
+		for sourceBillNumber, similarSection := range bestSections {
+			source, ok := sources[sourceBillNumber]
+			if !ok {
+				source = &IncorporationSource{BillNumber: sourceBillNumber}
+				sources[sourceBillNumber] = source
+			}
+			source.Sections = append(source.Sections, IncorporatedSection{
+				EnactedSectionNum:       similarSectionsItem.SectionNum,
+				EnactedSectionHeader:    similarSectionsItem.SectionHeader,
+				EnactedSectionIndex:     similarSectionsItem.SectionIndex,
+				SourceBillNumberVersion: similarSection.BillCongressTypeNumberVersion,
+				SourceSectionNum:        similarSection.SectionNum,
+				SourceSectionHeader:     similarSection.SectionHeader,
+				Score:                   similarSection.Score,
+			})
+		}
+	}
+
+	compareItems := make(map[string]CompareItem)
+	for sourceBillNumber, billNumberVersion := range BestCompareVersions(billNumber, similarCategory) {
+		compareItems[sourceBillNumber] = similarCategory[billNumberVersion]
+	}
+	for sourceBillNumber, compareItem := range compareItems {
+		if _, ok := sources[sourceBillNumber]; !ok && compareItem.Explanation == IncorporatesReason {
+			sources[sourceBillNumber] = &IncorporationSource{BillNumber: sourceBillNumber, Sections: []IncorporatedSection{}}
+		}
+	}
+
+	for sourceBillNumber, source := range sources {
+		compareItem, compared := compareItems[sourceBillNumber]
+		if compared {
+			source.Category = compareItem.Explanation
+		}
+		sectionCount, counted := sourceSectionCounts[sourceBillNumber]
+		if counted {
+			source.MatchedSections = len(matchedSections[sourceBillNumber][sectionCount.BillNumberVersion])
+			// More matching sections than sections compared also means the sections are a sample
+			if !sectionCount.Sampled && source.MatchedSections <= sectionCount.Sections {
+				source.SourceSections = sectionCount.Sections
+			}
+		} else {
+			// The same section of a source bill in different versions is counted once
+			distinctSections := make(map[string]bool)
+			for _, versionSections := range matchedSections[sourceBillNumber] {
+				for section := range versionSections {
+					distinctSections[section] = true
+				}
+			}
+			source.MatchedSections = len(distinctSections)
+		}
+		switch {
+		case source.SourceSections > 0:
+			source.Coverage = roundPercent(float64(source.MatchedSections) / float64(source.SourceSections))
+			source.CoverageBasis = CoverageBySections
+		case compared:
+			// In the row of the enacted bill, Score is the share of the other bill's n-grams that are in the enacted bill
+			source.Coverage = roundPercent(compareItem.Score)
+			source.CoverageBasis = CoverageByText
+		}
+		sort.SliceStable(source.Sections, func(i, j int) bool {
+			indexI, _ := strconv.Atoi(source.Sections[i].EnactedSectionIndex)
+			indexJ, _ := strconv.Atoi(source.Sections[j].EnactedSectionIndex)
+			return indexI < indexJ
+		})
+		report.Sources = append(report.Sources, *source)
+	}
+	sort.SliceStable(report.Sources, func(i, j int) bool {
+		if report.Sources[i].Coverage != report.Sources[j].Coverage {
+			return report.Sources[i].Coverage > report.Sources[j].Coverage
+		}
+		return report.Sources[i].BillNumber < report.Sources[j].BillNumber
+	})
+	return report
+}
+
+// Makes the incorporation report for the enacted bill from the files saved by esquery in the store.
+// The number of sections of each source bill is taken from its esSimilarity.json, if it was saved.
+// The sections are a sample (esquery -samplesize) if the document of the version in dataPath has more sections.
+func ReadIncorporationReport(billStore BillStore, dataPath string, billNumber string, minScore float32) (report IncorporationReport, err error) {
+	data, err := billStore.GetBillData(billNumber, EsSimilarityFile)
+	if err != nil {
+		return report, fmt.Errorf("error reading %s for %s: %w", EsSimilarityFile, billNumber, err)
+	}
+	var similarSectionsItems SimilarSectionsItems
+	if err := json.Unmarshal(data, &similarSectionsItems); err != nil {
+		return report, fmt.Errorf("error parsing %s for %s: %w", EsSimilarityFile, billNumber, err)
+	}
+	var similarCategory map[string]CompareItem
+	if data, err := billStore.GetBillData(billNumber, EsSimilarCategoryFile); err == nil {
+		if err := json.Unmarshal(data, &similarCategory); err != nil {
+			log.Error().Msgf("Error parsing %s for %s: %s", EsSimilarCategoryFile, billNumber, err)
+		}
+	}
+	sourceSectionCounts := make(map[string]SourceSectionCount)
+	checked := make(map[string]bool)
+	for _, similarSectionsItem := range similarSectionsItems {
+		for _, similarSection := range similarSectionsItem.SimilarSections {
+			sourceBillNumber := similarSection.Billnumber
+			if checked[sourceBillNumber] || sourceBillNumber == billNumber || sourceBillNumber == "" {
+				continue
+			}
+			checked[sourceBillNumber] = true
+			data, err := billStore.GetBillData(sourceBillNumber, EsSimilarityFile)
+			if err != nil {
+				continue
+			}
+			var sourceItems SimilarSectionsItems
+			if err := json.Unmarshal(data, &sourceItems); err != nil || len(sourceItems) == 0 {
+				continue
+			}
+			sectionCount := SourceSectionCount{BillNumberVersion: sourceItems[0].BillNumberVersion, Sections: len(sourceItems)}
+			if billPath, err := PathFromBillNumber(sectionCount.BillNumberVersion); err == nil && dataPath != "" {
+				if billItem, err := ReadBillItem(filepath.Join(dataPath, billPath, "document.xml")); err == nil {
+					sectionCount.Sampled = len(billItem.Sections) > sectionCount.Sections
+				}
+			}
+			sourceSectionCounts[sourceBillNumber] = sectionCount
+		}
+	}
+	report = MakeIncorporationReport(billNumber, similarSectionsItems, similarCategory, sourceSectionCounts, minScore)
+	for i, source := range report.Sources {
+		if billMeta, err := billStore.GetBillMeta(source.BillNumber); err == nil {
+			report.Sources[i].Title = billMeta.ShortTitle
+			if report.Sources[i].Title == "" {
+				report.Sources[i].Title = billMeta.OfficialTitle
+			}
+		}
+	}
+	return report, nil
+}
+
+// Writes the report to w, in one of the formats:
+// IncorporationFormatJSON: the report as JSON
+// IncorporationFormatCSV: one line for each matching section, with the coverage of its source bill
+func WriteIncorporationReport(w io.Writer, report IncorporationReport, format string) error {
+	switch format {
+	case IncorporationFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", " ")
+		return encoder.Encode(report)
+	case IncorporationFormatCSV:
+		csvWriter := csv.NewWriter(w)
+		csvWriter.Write([]string{"enacted_bill", "source_bill", "source_title", "category", "coverage", "coverage_basis", "matched_sections", "source_sections",
+			"enacted_section_num", "enacted_section_header", "source_bill_number_version", "source_section_num", "source_section_header", "score"})
+		for _, source := range report.Sources {
+			sourceColumns := []string{report.BillNumber, source.BillNumber, source.Title, source.Category, strconv.FormatFloat(source.Coverage, 'f', -1, 64), source.CoverageBasis,
+				strconv.Itoa(source.MatchedSections), strconv.Itoa(source.SourceSections)}
+			if len(source.Sections) == 0 {
+				csvWriter.Write(append(sourceColumns, "", "", "", "", "", ""))
+			}
+			for _, section := range source.Sections {
+				csvWriter.Write(append(append([]string{}, sourceColumns...), strings.TrimSpace(section.EnactedSectionNum), section.EnactedSectionHeader,
+					section.SourceBillNumberVersion, strings.TrimSpace(section.SourceSectionNum), section.SourceSectionHeader, strconv.FormatFloat(float64(section.Score), 'f', -1, 32)))
+			}
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	default:
+		return fmt.Errorf("unknown incorporation report format: %s (options: %s)", format, strings.Join(IncorporationFormats, ", "))
+	}
+}
@@ -0,0 +1,147 @@
+package bills
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"path"
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// Three sections of 116hr133enr: two match sections of 116hr7617, one matches 116s1000 (and another version of 116hr133)
+var sampleEnactedSections = SimilarSectionsItems{
+	{BillNumber: "116hr133", BillNumberVersion: "116hr133enr", SectionNum: "1. ", SectionHeader: "Short title", SectionIndex: "0", SimilarSections: SimilarSections{
+		{Billnumber: "116hr133", BillCongressTypeNumberVersion: "116hr133eas", SectionNum: "1. ", SectionHeader: "Short title", Score: 90},
+	}},
+	{BillNumber: "116hr133", BillNumberVersion: "116hr133enr", SectionNum: "10. ", SectionHeader: "Grants", SectionIndex: "10", SimilarSections: SimilarSections{
+		{Billnumber: "116hr7617", BillCongressTypeNumberVersion: "116hr7617rh", SectionNum: "3. ", SectionHeader: "Grants", Score: 40},
+		{Billnumber: "116hr7617", BillCongressTypeNumberVersion: "116hr7617ih", SectionNum: "3. ", SectionHeader: "Grants", Score: 35},
+		{Billnumber: "116s1000", BillCongressTypeNumberVersion: "116s1000is", SectionNum: "2. ", SectionHeader: "Grant program", Score: 5},
+	}},
+	{BillNumber: "116hr133", BillNumberVersion: "116hr133enr", SectionNum: "2. ", SectionHeader: "Reports", SectionIndex: "2", SimilarSections: SimilarSections{
+		{Billnumber: "116hr7617", BillCongressTypeNumberVersion: "116hr7617rh", SectionNum: "4. ", SectionHeader: "Reports", Score: 30},
+	}},
+}
+
+var sampleEnactedCategory = map[string]CompareItem{
+	"116hr7617rh": {Score: 0.9, ScoreOther: 0.05, Explanation: IncorporatesReason},
+	"116s1000is":  {Score: 0.2, ScoreOther: 0.01, Explanation: "bills-some_similarity"},
+	"116s2000is":  {Score: 0.85, ScoreOther: 0.01, Explanation: IncorporatesReason},
+	"116s3000is":  {Score: 0.01, ScoreOther: 0.01, Explanation: UnrelatedReason},
+}
+
+func TestMakeIncorporationReport(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test making the report of bills incorporated into an enacted bill")
+	report := MakeIncorporationReport("116hr133", sampleEnactedSections, sampleEnactedCategory, map[string]SourceSectionCount{"116hr7617": {BillNumberVersion: "116hr7617rh", Sections: 4}}, 10)
+	assert.Equal(t, "116hr133enr", report.BillNumberVersion)
+	assert.Equal(t, 3, report.EnactedSections)
+	// 116s1000 only matches below the minimum score
+	assert.Equal(t, 2, len(report.Sources))
+
+	source := report.Sources[0]
+	assert.Equal(t, "116s2000", source.BillNumber)
+	assert.Equal(t, 85.0, source.Coverage)
+	assert.Equal(t, CoverageByText, source.CoverageBasis)
+	assert.Equal(t, 0, len(source.Sections))
+
+	source = report.Sources[1]
+	assert.Equal(t, "116hr7617", source.BillNumber)
+	assert.Equal(t, IncorporatesReason, source.Category)
+	assert.Equal(t, 2, source.MatchedSections)
+	assert.Equal(t, 50.0, source.Coverage)
+	assert.Equal(t, CoverageBySections, source.CoverageBasis)
+	// Sections are in the order of the enacted bill, with the best matching version
+	assert.Equal(t, []string{"2. ", "10. "}, []string{source.Sections[0].EnactedSectionNum, source.Sections[1].EnactedSectionNum})
+	assert.Equal(t, "116hr7617rh", source.Sections[1].SourceBillNumberVersion)
+	assert.Equal(t, float32(40), source.Sections[1].Score)
+
+	// Only the sections of the counted version match: 116hr7617ih has one
+	report = MakeIncorporationReport("116hr133", sampleEnactedSections, sampleEnactedCategory, map[string]SourceSectionCount{"116hr7617": {BillNumberVersion: "116hr7617ih", Sections: 4}}, 10)
+	assert.Equal(t, 1, report.Sources[1].MatchedSections)
+	assert.Equal(t, 25.0, report.Sources[1].Coverage)
+	// With a sample of the sections, coverage is by text
+	report = MakeIncorporationReport("116hr133", sampleEnactedSections, sampleEnactedCategory, map[string]SourceSectionCount{"116hr7617": {BillNumberVersion: "116hr7617rh", Sections: 4, Sampled: true}}, 10)
+	assert.Equal(t, "116hr7617", report.Sources[0].BillNumber)
+	assert.Equal(t, 0, report.Sources[0].SourceSections)
+	assert.Equal(t, 90.0, report.Sources[0].Coverage)
+	assert.Equal(t, CoverageByText, report.Sources[0].CoverageBasis)
+	// Fewer sections than match is also a sample; without a comparison, coverage is unknown
+	report = MakeIncorporationReport("116hr133", sampleEnactedSections, nil, map[string]SourceSectionCount{"116hr7617": {BillNumberVersion: "116hr7617rh", Sections: 1}}, 10)
+	assert.Equal(t, 2, report.Sources[0].MatchedSections)
+	assert.Equal(t, 0, report.Sources[0].SourceSections)
+	assert.Equal(t, "", report.Sources[0].CoverageBasis)
+
+	report = MakeIncorporationReport("116hr133", sampleEnactedSections, nil, nil, 0)
+	assert.Equal(t, []string{"116hr7617", "116s1000"}, []string{report.Sources[0].BillNumber, report.Sources[1].BillNumber})
+	assert.Equal(t, "", report.Sources[0].CoverageBasis)
+}
+
+func TestWriteIncorporationReport(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test writing the incorporation report as JSON and CSV")
+	report := MakeIncorporationReport("116hr133", sampleEnactedSections, sampleEnactedCategory, map[string]SourceSectionCount{"116hr7617": {BillNumberVersion: "116hr7617rh", Sections: 4}}, 10)
+
+	var buf bytes.Buffer
+	assert.Nil(t, WriteIncorporationReport(&buf, report, IncorporationFormatJSON))
+	var reportJson IncorporationReport
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &reportJson))
+	assert.Equal(t, report, reportJson)
+
+	buf.Reset()
+	assert.Nil(t, WriteIncorporationReport(&buf, report, IncorporationFormatCSV))
+	records, err := csv.NewReader(&buf).ReadAll()
+	assert.Nil(t, err)
+	// Header, one line for 116s2000 (no sections) and one for each section of 116hr7617
+	assert.Equal(t, 4, len(records))
+	assert.Equal(t, []string{"116hr133", "116hr7617", "", IncorporatesReason, "50", CoverageBySections, "2", "4", "10.", "Grants", "116hr7617rh", "3.", "Grants", "40"}, records[3])
+
+	assert.NotNil(t, WriteIncorporationReport(&buf, report, "xml"))
+}
+
+func TestReadIncorporationReport(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test reading the incorporation report from a store")
+	billStore, err := OpenBadgerBillStore(path.Join(t.TempDir(), "badger"))
+	assert.Nil(t, err)
+	defer billStore.Close()
+
+	_, err = ReadIncorporationReport(billStore, "samples/congress/data", "116hr133", 0)
+	assert.NotNil(t, err)
+
+	enactedSections, _ := json.Marshal(sampleEnactedSections)
+	assert.Nil(t, billStore.PutBillData("116hr133", EsSimilarityFile, enactedSections))
+	category, _ := json.Marshal(sampleEnactedCategory)
+	assert.Nil(t, billStore.PutBillData("116hr133", EsSimilarCategoryFile, category))
+	// 116hr7617rh has 8 sections
+	assert.Nil(t, billStore.PutBillData("116hr7617", EsSimilarityFile, []byte(`[{"bill_number_version": "116hr7617rh"}, {}, {}, {}, {}, {}, {}, {}]`)))
+	assert.Nil(t, billStore.PutBillMeta(BillMeta{BillCongressTypeNumber: "116hr7617", ShortTitle: "Grants Act"}))
+
+	report, err := ReadIncorporationReport(billStore, "samples/congress/data", "116hr133", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, "116hr7617", report.Sources[1].BillNumber)
+	assert.Equal(t, "Grants Act", report.Sources[1].Title)
+	assert.Equal(t, 8, report.Sources[1].SourceSections)
+	assert.Equal(t, 25.0, report.Sources[1].Coverage)
+
+	// The esSimilarity.json of 116hr1500 has 2 of the 8 sections of 116hr1500ih
+	matchingSections, _ := json.Marshal(SimilarSectionsItems{{BillNumber: "116hr133", BillNumberVersion: "116hr133enr", SectionIndex: "0", SimilarSections: SimilarSections{
+		{Billnumber: "116hr1500", BillCongressTypeNumberVersion: "116hr1500ih", SectionNum: "3. ", SectionHeader: "Consumer Financial Protection Bureau", Score: 50},
+	}}})
+	assert.Nil(t, billStore.PutBillData("116hr133", EsSimilarityFile, matchingSections))
+	assert.Nil(t, billStore.PutBillData("116hr1500", EsSimilarityFile, []byte(`[{"bill_number_version": "116hr1500ih"}, {}]`)))
+	report, err = ReadIncorporationReport(billStore, "samples/congress/data", "116hr133", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, "116hr1500", report.Sources[2].BillNumber)
+	assert.Equal(t, 0, report.Sources[2].SourceSections)
+	assert.Equal(t, "", report.Sources[2].CoverageBasis)
+	// Without the documents, the sample is not known
+	report, err = ReadIncorporationReport(billStore, "", "116hr133", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Sources[2].SourceSections)
+	assert.Equal(t, 50.0, report.Sources[2].Coverage)
+}
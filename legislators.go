@@ -1,15 +1,35 @@
 package bills
 
 import (
+	"fmt"
 	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	ChamberHouse  = "house"
+	ChamberSenate = "senate"
+	// Layout of the dates in legislators.yaml
+	legislatorDateLayout = "2006-01-02"
+)
+
+// Names of the legislators files in the ReferenceCacheDir
+const (
+	LegislatorsYamlFile           = "legislators.yaml"
+	LegislatorsHistoricalYamlFile = "legislators-historical.yaml"
+)
+
 var (
 	legislatorYamlUrl = "https://raw.githubusercontent.com/unitedstates/congress-legislators/master/legislators-current.yaml"
+	// Legislators no longer in office, e.g. cosponsors of bills in earlier congresses
+	legislatorHistoricalYamlUrl = "https://raw.githubusercontent.com/unitedstates/congress-legislators/master/legislators-historical.yaml"
 )
 
 type Legislators struct {
@@ -20,7 +40,8 @@ type Id struct {
 	Id []Legislator `yaml:"id,omitempty,flow"`
 }
 
-type Legislator struct {
+// The ids of a legislator, under 'id' in legislators.yaml
+type LegislatorIds struct {
 	Bioguide       string   `yaml:"bioguide,omitempty,flow"`
 	Thomas         string   `yaml:"thomas,omitempty,flow"`
 	Lis            string   `yaml:"lis,omitempty,flow"`
@@ -36,66 +57,361 @@ type Legislator struct {
 	Icpsr          string   `yaml:"icpsr,omitempty,flow"`
 	Wikidata       string   `yaml:"wikidata,omitempty,flow"`
 	GoogleEntityId string   `yaml:"google_entity_id,omitempty,flow"`
-	Name           struct {
+}
+
+type LegislatorTerm struct {
+	Type        string `yaml:"type,omitempty,flow"` // rep or sen
+	Start       string `yaml:"start,omitempty,flow"`
+	End         string `yaml:"end,omitempty,flow"`
+	State       string `yaml:"state,omitempty,flow"`
+	District    string `yaml:"district,omitempty,flow"`
+	Class       string `yaml:"class,omitempty,flow"`
+	Party       string `yaml:"party,omitempty,flow"`
+	StateRank   string `yaml:"state_rank,omitempty,flow"`
+	Url         string `yaml:"url,omitempty,flow"`
+	RssUrl      string `yaml:"rss_url,omitempty,flow"`
+	ContactForm string `yaml:"contact_form,omitempty,flow"`
+	Address     string `yaml:"address,omitempty,flow"`
+	Office      string `yaml:"office,omitempty,flow"`
+	Phone       string `yaml:"phone,omitempty,flow"`
+	// Set when the legislator changed party during the term
+	PartyAffiliations []struct {
+		Start string `yaml:"start,omitempty,flow"`
+		End   string `yaml:"end,omitempty,flow"`
+		Party string `yaml:"party,omitempty,flow"`
+	} `yaml:"party_affiliations,omitempty,flow"`
+}
+
+type Legislator struct {
+	LegislatorIds `yaml:"id,omitempty,flow"`
+	Name          struct {
 		First        string `yaml:"first,omitempty,flow"`
+		Middle       string `yaml:"middle,omitempty,flow"`
 		Last         string `yaml:"last,omitempty,flow"`
+		Suffix       string `yaml:"suffix,omitempty,flow"`
 		OfficialFull string `yaml:"official_full,omitempty,flow"`
 	} `yaml:"name,omitempty,flow"`
 	Bio struct {
 		Birthday string `yaml:"birthday,omitempty,flow"`
 		Gender   string `yaml:"gender,omitempty,flow"`
 	}
-	Terms []struct {
-		Type        string `yaml:"type,omitempty,flow"`
-		Start       string `yaml:"Start,omitempty,flow"`
-		End         string `yaml:"End,omitempty,flow"`
-		State       string `yaml:"State,omitempty,flow"`
-		District    string `yaml:"District,omitempty,flow"`
-		Party       string `yaml:"Party,omitempty,flow"`
-		StateRank   string `yaml:"state_rank,omitempty,flow"`
-		Url         string `yaml:"url,omitempty,flow"`
-		RssUrl      string `yaml:"rss_url,omitempty,flow"`
-		ContactForm string `yaml:"contact_form,omitempty,flow"`
-		Address     string `yaml:"address,omitempty,flow"`
-		Office      string `yaml:"office,omitempty,flow"`
-		Phone       string `yaml:"phone,omitempty,flow"`
-	}
-}
-
-func DownloadLegislatorsYaml() (downloadpath string, err error) {
-	MakeTempDir()
-	downloadpath = "tmp/legislators.yaml"
-	err = DownloadFile(downloadpath, legislatorYamlUrl)
-	if err != nil {
-		panic(err)
-	}
-	log.Info().Msgf("Downloaded: %s", legislatorYamlUrl)
-	if err != nil {
-		panic(err)
-	}
-	Prepend(downloadpath, "legislators:")
-	return
+	Terms []LegislatorTerm
+}
+
+// Downloads legislators-current.yaml to legislators.yaml in the ReferenceCacheDir, if it changed upstream
+func DownloadLegislatorsYaml() (FetchResult, error) {
+	return NewFetcher(ReferenceCacheDir).Fetch(legislatorYamlUrl, LegislatorsYamlFile)
+}
+
+// Downloads legislators-historical.yaml to the ReferenceCacheDir, if it changed upstream
+func DownloadLegislatorsHistoricalYaml() (FetchResult, error) {
+	return NewFetcher(ReferenceCacheDir).Fetch(legislatorHistoricalYamlUrl, LegislatorsHistoricalYamlFile)
 }
 
+// Parses legislators.yaml, which is a list of legislators. Files with a 'legislators:' key
+// (prepended to the list by earlier versions of DownloadLegislatorsYaml) are also accepted.
 func (c *Legislators) ParseLegislatorsYaml(data []byte) error {
-	if err := yaml.Unmarshal(data, c); err != nil {
-		return err
+	if err := yaml.Unmarshal(data, &c.Legislators); err != nil {
+		if mapErr := yaml.Unmarshal(data, c); mapErr != nil {
+			return err
+		}
 	}
 	// ... Check for elements of the YAML
 	return nil
 }
 
-func ReadLegislatorsYaml() {
-	pathToYaml := "tmp/legislators.yaml"
+func ReadLegislatorsYaml() (legislators Legislators, err error) {
+	pathToYaml := path.Join(ReferenceCacheDir, LegislatorsYamlFile)
 	yamlFile, err := ioutil.ReadFile(pathToYaml)
 	if err != nil {
 		log.Error().Msgf("Error reading %s   #%v ", pathToYaml, err)
+		return legislators, err
 	}
-	var legislators Legislators
 	if err := legislators.ParseLegislatorsYaml(yamlFile); err != nil {
-		log.Fatal().Err(err)
+		log.Error().Msgf("Error parsing %s: %s", pathToYaml, err)
+		return legislators, err
+	}
+
+	log.Debug().Msgf("Read %d legislators", len(legislators.Legislators))
+	return legislators, nil
+}
+
+// Parses a date in legislators.yaml or data.json (e.g. 2019-03-05)
+func parseLegislatorDate(date string) (time.Time, bool) {
+	if len(date) > len(legislatorDateLayout) {
+		date = date[:len(legislatorDateLayout)]
+	}
+	parsed, err := time.Parse(legislatorDateLayout, date)
+	return parsed, err == nil
+}
+
+// Whether the date is in the term. The term ends at noon on its end date, when the next term starts, so the end date is in the next term.
+func (term LegislatorTerm) Contains(date time.Time) bool {
+	start, okStart := parseLegislatorDate(term.Start)
+	end, okEnd := parseLegislatorDate(term.End)
+	return okStart && okEnd && !date.Before(start) && date.Before(end)
+}
+
+// Gets the chamber of the term: house or senate
+func (term LegislatorTerm) Chamber() string {
+	switch term.Type {
+	case "rep":
+		return ChamberHouse
+	case "sen":
+		return ChamberSenate
+	default:
+		return ""
+	}
+}
+
+// Gets the party of the term on the date, from its party affiliations if the legislator changed party during the term
+func (term LegislatorTerm) PartyAt(date time.Time) string {
+	for _, affiliation := range term.PartyAffiliations {
+		start, okStart := parseLegislatorDate(affiliation.Start)
+		end, okEnd := parseLegislatorDate(affiliation.End)
+		if okStart && okEnd && !date.Before(start) && !date.After(end) {
+			return affiliation.Party
+		}
 	}
+	return term.Party
+}
 
-	//fmt.Printf("%+v\n", legislators)
+// Gets the term of the legislator on the date. A zero date gets the latest term.
+func (legislator Legislator) TermAt(date time.Time) (term LegislatorTerm, ok bool) {
+	if len(legislator.Terms) == 0 {
+		return term, false
+	}
+	if date.IsZero() {
+		return legislator.Terms[len(legislator.Terms)-1], true
+	}
+	for _, term := range legislator.Terms {
+		if term.Contains(date) {
+			return term, true
+		}
+	}
+	return term, false
+}
+
+// Gets the term on the date or, if the legislator was not in office on the date, the last term before it
+// (or the first term, if there is none before it). A zero date gets the latest term.
+func (legislator Legislator) TermNear(date time.Time) (term LegislatorTerm, ok bool) {
+	if term, ok := legislator.TermAt(date); ok || len(legislator.Terms) == 0 {
+		return term, ok
+	}
+	term = legislator.Terms[0]
+	for _, nextTerm := range legislator.Terms {
+		if start, ok := parseLegislatorDate(nextTerm.Start); ok && start.After(date) {
+			break
+		}
+		term = nextTerm
+	}
+	return term, true
+}
+
+// Gets the party of the legislator on the date (or in the latest term, for a zero date)
+func (legislator Legislator) Party(date time.Time) string {
+	term, _ := legislator.TermAt(date)
+	if date.IsZero() {
+		return term.Party
+	}
+	return term.PartyAt(date)
+}
+
+// Gets the state of the legislator on the date (or in the latest term, for a zero date)
+func (legislator Legislator) State(date time.Time) string {
+	term, _ := legislator.TermAt(date)
+	return term.State
+}
+
+// Gets the district of the legislator on the date (or in the latest term, for a zero date); senators have no district
+func (legislator Legislator) District(date time.Time) string {
+	term, _ := legislator.TermAt(date)
+	return term.District
+}
 
+// Gets the chamber of the legislator on the date (or in the latest term, for a zero date)
+func (legislator Legislator) Chamber(date time.Time) string {
+	term, _ := legislator.TermAt(date)
+	return term.Chamber()
+}
+
+// Legislators by bioguide, thomas and lis id
+type LegislatorIndex struct {
+	byBioguide map[string]*Legislator
+	byThomas   map[string]*Legislator
+	byLis      map[string]*Legislator
+}
+
+func NewLegislatorIndex(legislators []Legislator) *LegislatorIndex {
+	index := &LegislatorIndex{
+		byBioguide: make(map[string]*Legislator),
+		byThomas:   make(map[string]*Legislator),
+		byLis:      make(map[string]*Legislator),
+	}
+	index.Add(legislators...)
+	return index
+}
+
+// Reads the legislators yaml files at the paths (e.g. tmp/legislators.yaml and tmp/legislators-historical.yaml) into one index
+func ReadLegislatorIndex(pathsToYaml ...string) (*LegislatorIndex, error) {
+	index := NewLegislatorIndex(nil)
+	for _, pathToYaml := range pathsToYaml {
+		yamlFile, err := ioutil.ReadFile(pathToYaml)
+		if err != nil {
+			return nil, err
+		}
+		var legislators Legislators
+		if err := legislators.ParseLegislatorsYaml(yamlFile); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", pathToYaml, err)
+		}
+		index.Add(legislators.Legislators...)
+	}
+	return index, nil
+}
+
+// Adds the legislators to the index. A legislator already in the index (by bioguide id), e.g. from both the
+// current and historical files, is merged: terms that are not in the index are added, in order of their start dates.
+func (index *LegislatorIndex) Add(legislators ...Legislator) {
+	for i := range legislators {
+		legislator := &legislators[i]
+		if existing, ok := index.byBioguide[legislator.Bioguide]; ok && legislator.Bioguide != "" {
+			legislator = mergeLegislators(existing, legislator)
+		}
+		if legislator.Bioguide != "" {
+			index.byBioguide[legislator.Bioguide] = legislator
+		}
+		if legislator.Thomas != "" {
+			index.byThomas[legislator.Thomas] = legislator
+		}
+		if legislator.Lis != "" {
+			index.byLis[legislator.Lis] = legislator
+		}
+	}
+}
+
+// Merges the terms and ids of two records of the same legislator. The names and other ids are taken from
+// the record with the latest term.
+func mergeLegislators(legislator1, legislator2 *Legislator) *Legislator {
+	if termsEnd(legislator1.Terms) > termsEnd(legislator2.Terms) {
+		legislator1, legislator2 = legislator2, legislator1
+	}
+	merged := *legislator2
+	if merged.Thomas == "" {
+		merged.Thomas = legislator1.Thomas
+	}
+	if merged.Lis == "" {
+		merged.Lis = legislator1.Lis
+	}
+	merged.Terms = append([]LegislatorTerm{}, legislator2.Terms...)
+	for _, term := range legislator1.Terms {
+		found := false
+		for _, mergedTerm := range merged.Terms {
+			if mergedTerm.Type == term.Type && mergedTerm.Start == term.Start {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged.Terms = append(merged.Terms, term)
+		}
+	}
+	sort.SliceStable(merged.Terms, func(i, j int) bool {
+		return merged.Terms[i].Start < merged.Terms[j].Start
+	})
+	return &merged
+}
+
+// The end date of the last term (dates in legislators.yaml sort as strings)
+func termsEnd(terms []LegislatorTerm) string {
+	end := ""
+	for _, term := range terms {
+		if term.End > end {
+			end = term.End
+		}
+	}
+	return end
+}
+
+// Number of legislators in the index
+func (index *LegislatorIndex) Len() int {
+	return len(index.byBioguide)
+}
+
+func (index *LegislatorIndex) ByBioguide(bioguideId string) (Legislator, bool) {
+	legislator, ok := index.byBioguide[bioguideId]
+	if !ok {
+		return Legislator{}, false
+	}
+	return *legislator, true
+}
+
+// Gets the legislator by thomas id; ids with fewer than 5 digits are padded with zeros (e.g. 136 -> 00136)
+func (index *LegislatorIndex) ByThomas(thomasId string) (Legislator, bool) {
+	if len(thomasId) < 5 {
+		thomasId = strings.Repeat("0", 5-len(thomasId)) + thomasId
+	}
+	legislator, ok := index.byThomas[thomasId]
+	if !ok {
+		return Legislator{}, false
+	}
+	return *legislator, true
+}
+
+func (index *LegislatorIndex) ByLis(lisId string) (Legislator, bool) {
+	legislator, ok := index.byLis[lisId]
+	if !ok {
+		return Legislator{}, false
+	}
+	return *legislator, true
+}
+
+// Adds the party and chamber to a sponsor or cosponsor, for the term that includes the date they sponsored the bill
+// (the latest term, if the date is not known), and the district, if it is not set. The sponsor is found by bioguide id,
+// or else by thomas id.
+func (index *LegislatorIndex) EnrichCosponsor(cosponsor CosponsorItem) CosponsorItem {
+	if index == nil {
+		return cosponsor
+	}
+	legislator, ok := index.ByBioguide(cosponsor.BioguideId)
+	if !ok && cosponsor.ThomasId != "" {
+		legislator, ok = index.ByThomas(cosponsor.ThomasId)
+	}
+	if !ok {
+		log.Debug().Msgf("No legislator found for %s (%s)", cosponsor.Name, cosponsor.BioguideId)
+		return cosponsor
+	}
+	date, _ := parseLegislatorDate(cosponsor.SponsoredAt)
+	term, _ := legislator.TermNear(date)
+	cosponsor.Party = term.Party
+	if !date.IsZero() {
+		cosponsor.Party = term.PartyAt(date)
+	}
+	cosponsor.Chamber = term.Chamber()
+	if cosponsor.District == "" {
+		cosponsor.District = term.District
+	}
+	return cosponsor
+}
+
+// Adds the party and chamber to the sponsor and cosponsors of the bill, as of the date they sponsored it
+// or, if that is not known, the date the bill was introduced
+func (index *LegislatorIndex) EnrichBillMeta(billMeta *BillMeta) {
+	if index == nil {
+		return
+	}
+	enrich := func(cosponsor CosponsorItem) CosponsorItem {
+		sponsoredAt := cosponsor.SponsoredAt
+		if sponsoredAt == "" {
+			cosponsor.SponsoredAt = billMeta.IntroducedAt
+		}
+		cosponsor = index.EnrichCosponsor(cosponsor)
+		cosponsor.SponsoredAt = sponsoredAt
+		return cosponsor
+	}
+	if billMeta.Sponsor.BioguideId != "" || billMeta.Sponsor.ThomasId != "" {
+		billMeta.Sponsor = enrich(billMeta.Sponsor)
+	}
+	for i, cosponsor := range billMeta.Cosponsors {
+		billMeta.Cosponsors[i] = enrich(cosponsor)
+	}
 }
@@ -0,0 +1,139 @@
+package bills
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var sampleLegislatorsPath = path.Join("samples", "legislators", "legislators-current.yaml")
+var sampleLegislatorsHistoricalPath = path.Join("samples", "legislators", "legislators-historical.yaml")
+
+func TestLegislatorIndex(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test looking up legislators by id and term")
+	index, err := ReadLegislatorIndex(sampleLegislatorsPath)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, index.Len())
+
+	legislator, ok := index.ByBioguide("B000944")
+	assert.True(t, ok)
+	assert.Equal(t, "Sherrod", legislator.Name.First)
+	legislator, ok = index.ByThomas("136")
+	assert.True(t, ok)
+	assert.Equal(t, "B000944", legislator.Bioguide)
+	legislator, ok = index.ByLis("S307")
+	assert.True(t, ok)
+	assert.Equal(t, "B000944", legislator.Bioguide)
+	_, ok = index.ByBioguide("X000000")
+	assert.False(t, ok)
+
+	// Brown was in the House before the Senate
+	assert.Equal(t, ChamberHouse, legislator.Chamber(time.Date(2006, 5, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "13", legislator.District(time.Date(2006, 5, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, ChamberSenate, legislator.Chamber(time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "", legislator.District(time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "OH", legislator.State(time.Time{}))
+	assert.Equal(t, "Democrat", legislator.Party(time.Time{}))
+	_, ok = legislator.TermAt(time.Date(2010, 5, 1, 0, 0, 0, 0, time.UTC))
+	assert.False(t, ok)
+
+	// Waters moved from district 35 to 43; the end date of a term is in the next term
+	legislator, _ = index.ByBioguide("W000187")
+	assert.Equal(t, "35", legislator.District(time.Date(2012, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "43", legislator.District(time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "", legislator.District(time.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestEnrichBillMeta(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test adding party and chamber to sponsors and cosponsors")
+	index, err := ReadLegislatorIndex(sampleLegislatorsPath)
+	assert.Nil(t, err)
+
+	billStore, err := OpenBadgerBillStore(path.Join(t.TempDir(), "badger"))
+	assert.Nil(t, err)
+	defer billStore.Close()
+	metaIndex := NewMetaIndex()
+	metaIndex.Legislators = index
+	metaIndex.MakeBillsMeta("samples", billStore, "116")
+	billMeta, ok := metaIndex.GetBillMeta("116hr1500")
+	assert.True(t, ok)
+	assert.Equal(t, "W000187", billMeta.Sponsor.BioguideId)
+	assert.Equal(t, "2019-03-05", billMeta.Sponsor.SponsoredAt)
+	assert.Equal(t, "Democrat", billMeta.Sponsor.Party)
+	assert.Equal(t, ChamberHouse, billMeta.Sponsor.Chamber)
+	for _, cosponsor := range billMeta.Cosponsors {
+		if cosponsor.BioguideId == "A000370" {
+			assert.Equal(t, "Democrat", cosponsor.Party)
+			assert.Equal(t, ChamberHouse, cosponsor.Chamber)
+		} else {
+			// Not in the sample legislators file
+			assert.Equal(t, "", cosponsor.Party)
+		}
+	}
+
+	// A nil index leaves the bill unchanged
+	var noIndex *LegislatorIndex
+	billMeta = BillMeta{Sponsor: CosponsorItem{BioguideId: "W000187"}}
+	noIndex.EnrichBillMeta(&billMeta)
+	assert.Equal(t, "", billMeta.Sponsor.Party)
+}
+
+func TestHistoricalLegislators(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test merging current and historical legislators")
+	index, err := ReadLegislatorIndex(sampleLegislatorsPath, sampleLegislatorsHistoricalPath)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, index.Len())
+
+	// Amash changed party during his last term
+	legislator, ok := index.ByBioguide("A000367")
+	assert.True(t, ok)
+	assert.Equal(t, "Republican", legislator.Party(time.Date(2018, 5, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "Republican", legislator.Party(time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "Independent", legislator.Party(time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "Libertarian", legislator.Party(time.Time{}))
+
+	// Terms of a legislator in more than one file are merged in order
+	index.Add(Legislator{LegislatorIds: LegislatorIds{Bioguide: "B000944"}, Terms: []LegislatorTerm{
+		{Type: "rep", Start: "1993-01-05", End: "1995-01-03", State: "OH", District: "13", Party: "Democrat"},
+		{Type: "rep", Start: "2005-01-04", End: "2007-01-03", State: "OH", District: "13", Party: "Democrat"},
+	}})
+	assert.Equal(t, 5, index.Len())
+	legislator, _ = index.ByLis("S307")
+	assert.Equal(t, 3, len(legislator.Terms))
+	assert.Equal(t, "1993-01-05", legislator.Terms[0].Start)
+	assert.Equal(t, "Sherrod", legislator.Name.First)
+	assert.Equal(t, ChamberSenate, legislator.Chamber(time.Time{}))
+	// Between terms, the last term before the date is used
+	term, ok := legislator.TermNear(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.True(t, ok)
+	assert.Equal(t, "1993-01-05", term.Start)
+
+	billStore, err := OpenBadgerBillStore(path.Join(t.TempDir(), "badger"))
+	assert.Nil(t, err)
+	defer billStore.Close()
+	metaIndex := NewMetaIndex()
+	metaIndex.Legislators = index
+	metaIndex.MakeBillsMeta("samples", billStore, "115")
+	billMeta, ok := metaIndex.GetBillMeta("115hr6972")
+	assert.True(t, ok)
+	assert.Equal(t, "2018-09-28", billMeta.IntroducedAt)
+	assert.Equal(t, "43", billMeta.Sponsor.District)
+	assert.Equal(t, ChamberHouse, billMeta.Sponsor.Chamber)
+	assert.Equal(t, "C001049", billMeta.Cosponsors[0].BioguideId)
+	assert.Equal(t, "Democrat", billMeta.Cosponsors[0].Party)
+	assert.Equal(t, ChamberHouse, billMeta.Cosponsors[0].Chamber)
+
+	// Without sponsored_at, the cosponsor is resolved as of the introduced date
+	billMeta = BillMeta{IntroducedAt: "2019-10-01", Cosponsors: []CosponsorItem{{BioguideId: "A000367"}}}
+	index.EnrichBillMeta(&billMeta)
+	assert.Equal(t, "Independent", billMeta.Cosponsors[0].Party)
+	assert.Equal(t, "3", billMeta.Cosponsors[0].District)
+	assert.Equal(t, "", billMeta.Cosponsors[0].SponsoredAt)
+}
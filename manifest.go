@@ -0,0 +1,274 @@
+package bills
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// Directory in the parentPath for the run manifests
+	ManifestDir = "manifests"
+	// Added to the file name of the temporary files written by WriteFileAtomic
+	TmpFileSuffix = ".tmp"
+	// Version of the code that generated the output files, recorded in the manifest.
+	// Can be set at build time, e.g. -ldflags "-X github.com/aih/bills.GeneratorVersion=v0.2.0";
+	// otherwise the module version from the build info is used.
+	GeneratorVersion = ""
+	// The output files saved in each bill directory
+	BillOutputFiles = []string{BillMetaJsonFile, RelatedDictFile, EsSimilarityFile, EsSimilarBillsDictFile, EsSimilarCategoryFile}
+)
+
+// Gets GeneratorVersion, or the version of this module from the build info
+func generatorVersion() string {
+	if GeneratorVersion != "" {
+		return GeneratorVersion
+	}
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		if buildInfo.Main.Path == "github.com/aih/bills" {
+			return buildInfo.Main.Version
+		}
+		for _, dep := range buildInfo.Deps {
+			if dep.Path == "github.com/aih/bills" {
+				return dep.Version
+			}
+		}
+	}
+	return "(devel)"
+}
+
+// One output file written in a run. Path is relative to the parentPath.
+type ManifestEntry struct {
+	RunId            string    `json:"run_id"`
+	Path             string    `json:"path"`
+	Sha256           string    `json:"sha256"`
+	Size             int       `json:"size"`
+	GeneratorVersion string    `json:"generator_version"`
+	WrittenAt        time.Time `json:"written_at"`
+}
+
+// Records each output file of a run in [parentPath]/manifests/[runId].jsonl, one JSON line per file.
+// The entry is written (and synced) before the file is renamed into place, so that VerifyOutputs
+// can detect a file that was not completely written. A nil *Manifest writes files without recording them.
+type Manifest struct {
+	mu         sync.Mutex
+	RunId      string
+	ParentPath string
+	file       *os.File
+}
+
+// Makes the id of a new run. Run ids sort in the order of the runs.
+func NewRunId() string {
+	return fmt.Sprintf("%s-%d", time.Now().UTC().Format("20060102T150405.000000000Z"), os.Getpid())
+}
+
+// Starts the manifest for a new run
+func OpenManifest(parentPath string) (*Manifest, error) {
+	manifestDir := filepath.Join(parentPath, ManifestDir)
+	if err := os.MkdirAll(manifestDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	runId := NewRunId()
+	file, err := os.OpenFile(filepath.Join(manifestDir, runId+".jsonl"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Msgf("Recording output files in %s", file.Name())
+	return &Manifest{RunId: runId, ParentPath: parentPath, file: file}, nil
+}
+
+// Records the file in the manifest, then writes it with WriteFileAtomic
+func (m *Manifest) WriteFile(filePath string, data []byte, perm os.FileMode) error {
+	if m == nil {
+		return WriteFileAtomic(filePath, data, perm)
+	}
+	relPath, err := filepath.Rel(m.ParentPath, filePath)
+	if err != nil {
+		return err
+	}
+	entry := ManifestEntry{
+		RunId:            m.RunId,
+		Path:             filepath.ToSlash(relPath),
+		Sha256:           FileHash(data),
+		Size:             len(data),
+		GeneratorVersion: generatorVersion(),
+		WrittenAt:        time.Now().UTC(),
+	}
+	entryJson, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	_, err = m.file.Write(append(entryJson, '\n'))
+	if err == nil {
+		err = m.file.Sync()
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("error writing manifest entry for %s: %s", relPath, err)
+	}
+	return WriteFileAtomic(filePath, data, perm)
+}
+
+func (m *Manifest) Close() error {
+	if m == nil {
+		return nil
+	}
+	return m.file.Close()
+}
+
+// Reads the manifests in [parentPath]/manifests and returns the latest entry for each output file
+func ReadManifests(parentPath string) (map[string]ManifestEntry, error) {
+	fileEntries, err := readManifestHistory(parentPath)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]ManifestEntry)
+	for filePath, history := range fileEntries {
+		entries[filePath] = history[len(history)-1]
+	}
+	return entries, nil
+}
+
+// Reads the manifests in [parentPath]/manifests and returns the entries for each output file, from the earliest to the latest
+func readManifestHistory(parentPath string) (map[string][]ManifestEntry, error) {
+	manifestPaths, err := filepath.Glob(filepath.Join(parentPath, ManifestDir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(manifestPaths)
+	entries := make(map[string][]ManifestEntry)
+	for _, manifestPath := range manifestPaths {
+		file, err := os.Open(manifestPath)
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var entry ManifestEntry
+			// A run that crashed may leave a partial last line
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				log.Warn().Msgf("Skipping invalid entry in %s: %s", manifestPath, err)
+				continue
+			}
+			entries[entry.Path] = append(entries[entry.Path], entry)
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// Result of VerifyOutputs. Paths are relative to the parentPath.
+type VerifyReport struct {
+	// Output files in the manifests, or billMeta.json for bills with a data.json, that do not exist
+	Missing []string `json:"missing"`
+	// Output files that are not valid JSON, or do not match the hash in the manifest
+	Corrupt []string `json:"corrupt"`
+	// Temporary files left by writes that did not finish, and output files that match an earlier entry in the
+	// manifests instead of the latest (the run stopped after recording the file and before renaming it into place)
+	Stale []string `json:"stale"`
+	// Output files that are not in any manifest (e.g. written before manifests were kept)
+	Unrecorded []string `json:"unrecorded"`
+	Checked    int      `json:"checked"`
+}
+
+func (r VerifyReport) Ok() bool {
+	return len(r.Missing) == 0 && len(r.Corrupt) == 0 && len(r.Stale) == 0
+}
+
+func isBillOutputFile(fileName string) bool {
+	for _, outputFile := range BillOutputFiles {
+		if fileName == outputFile {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks the output files in the congress tree of the parentPath against the manifests
+func VerifyOutputs(parentPath string) (report VerifyReport, err error) {
+	history, err := readManifestHistory(parentPath)
+	if err != nil {
+		return report, err
+	}
+	relPath := func(filePath string) string {
+		rel, _ := filepath.Rel(parentPath, filePath)
+		return filepath.ToSlash(rel)
+	}
+	checked := make(map[string]bool)
+	verifyFile := func(filePath string) {
+		rel := relPath(filePath)
+		checked[rel] = true
+		report.Checked++
+		data, err := os.ReadFile(filePath)
+		if os.IsNotExist(err) {
+			report.Missing = append(report.Missing, rel)
+			return
+		}
+		entries, recorded := history[rel]
+		switch {
+		case err != nil || (strings.HasSuffix(rel, ".json") && !json.Valid(data)):
+			report.Corrupt = append(report.Corrupt, rel)
+		case !recorded:
+			report.Unrecorded = append(report.Unrecorded, rel)
+		case entries[len(entries)-1].Sha256 != FileHash(data):
+			// The entry is written before the file is renamed into place, so an intact earlier version is not corrupt
+			hash := FileHash(data)
+			for _, entry := range entries[:len(entries)-1] {
+				if entry.Sha256 == hash {
+					report.Stale = append(report.Stale, rel)
+					return
+				}
+			}
+			report.Corrupt = append(report.Corrupt, rel)
+		}
+	}
+
+	congressDir := filepath.Join(parentPath, CongressDir)
+	err = filepath.WalkDir(congressDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		dir, fileName := filepath.Split(filePath)
+		switch {
+		case entry.IsDir():
+		case isBillOutputFile(fileName):
+			verifyFile(filePath)
+		case strings.Contains(fileName, TmpFileSuffix) && isBillOutputFile(fileName[:strings.Index(fileName, TmpFileSuffix)]):
+			report.Stale = append(report.Stale, relPath(filePath))
+		case fileName == "data.json" && filepath.Base(filepath.Dir(filepath.Clean(dir))) != "text-versions":
+			// Each bill directory should have billMeta.json; if it exists, it was checked above
+			billMetaPath := filepath.Join(dir, BillMetaJsonFile)
+			if !checked[relPath(billMetaPath)] {
+				verifyFile(billMetaPath)
+			}
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return report, err
+	}
+	// Files in the manifests outside of the bill directories (e.g. the title indexes), or that were deleted
+	for rel := range history {
+		if !checked[rel] {
+			verifyFile(filepath.Join(parentPath, filepath.FromSlash(rel)))
+		}
+	}
+	for _, paths := range [][]string{report.Missing, report.Corrupt, report.Stale, report.Unrecorded} {
+		sort.Strings(paths)
+	}
+	return report, nil
+}
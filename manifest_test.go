@@ -0,0 +1,89 @@
+package bills
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test writing a file atomically")
+	filePath := path.Join(t.TempDir(), "test.json")
+	assert.Nil(t, WriteFileAtomic(filePath, []byte(`{"a": 1}`), 0644))
+	assert.Nil(t, WriteFileAtomic(filePath, []byte(`{"b": 2}`), 0644))
+	data, err := os.ReadFile(filePath)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"b": 2}`, string(data))
+	// No temporary files are left
+	entries, _ := os.ReadDir(path.Dir(filePath))
+	assert.Equal(t, 1, len(entries))
+}
+
+func TestVerifyOutputs(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test verifying output files against the run manifest")
+	parentPath := t.TempDir()
+	billDir := path.Join(parentPath, CongressDir, "data", "116", "bills", "hr", "hr1500")
+	assert.Nil(t, os.MkdirAll(billDir, os.ModePerm))
+	dataJson, err := os.ReadFile(path.Join(samplesPathHR1500, "data.json"))
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(path.Join(billDir, "data.json"), dataJson, 0644))
+
+	manifest, err := OpenManifest(parentPath)
+	assert.Nil(t, err)
+	billStore := NewFileBillStore(parentPath)
+	billStore.Manifest = manifest
+	assert.Nil(t, billStore.PutBillMeta(readSampleBillMeta(t, sampleBillMetaPaths[0])))
+	assert.Nil(t, billStore.PutBillData("116hr1500", RelatedDictFile, []byte(`{"116hr1500": {}}`)))
+	assert.Nil(t, billStore.PutTitleIndex(TitleNoYearIndex, map[string][]string{"Consumers First Act": {"116hr1500"}}))
+	assert.Nil(t, manifest.Close())
+
+	entries, err := ReadManifests(parentPath)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(entries))
+	entry := entries["congress/data/116/bills/hr/hr1500/relatedDict.json"]
+	assert.Equal(t, manifest.RunId, entry.RunId)
+	assert.Equal(t, FileHash([]byte(`{"116hr1500": {}}`)), entry.Sha256)
+
+	report, err := VerifyOutputs(parentPath)
+	assert.Nil(t, err)
+	assert.True(t, report.Ok())
+	assert.Equal(t, 3, report.Checked)
+
+	// A run that stops after recording a new version of a file, and before renaming it into place, leaves the earlier version
+	rerun, err := OpenManifest(parentPath)
+	assert.Nil(t, err)
+	assert.Nil(t, rerun.WriteFile(path.Join(billDir, RelatedDictFile), []byte(`{"116hr1500": {}, "115hr6972": {}}`), 0644))
+	assert.Nil(t, rerun.Close())
+	assert.Nil(t, os.WriteFile(path.Join(billDir, RelatedDictFile), []byte(`{"116hr1500": {}}`), 0644))
+	report, err = VerifyOutputs(parentPath)
+	assert.Nil(t, err)
+	assert.False(t, report.Ok())
+	assert.Equal(t, 0, len(report.Corrupt))
+	assert.Equal(t, []string{"congress/data/116/bills/hr/hr1500/relatedDict.json"}, report.Stale)
+
+	// Truncate one file, delete another and leave a temporary file, as a crash would
+	assert.Nil(t, os.WriteFile(path.Join(billDir, RelatedDictFile), []byte(`{"116hr15`), 0644))
+	assert.Nil(t, os.Remove(path.Join(parentPath, TitleNoYearIndex)))
+	assert.Nil(t, os.WriteFile(path.Join(billDir, EsSimilarityFile+TmpFileSuffix+"123"), []byte(`[`), 0644))
+	assert.Nil(t, os.WriteFile(path.Join(billDir, EsSimilarBillsDictFile), []byte(`{}`), 0644))
+
+	report, err = VerifyOutputs(parentPath)
+	assert.Nil(t, err)
+	assert.False(t, report.Ok())
+	assert.Equal(t, []string{TitleNoYearIndex}, report.Missing)
+	assert.Equal(t, []string{"congress/data/116/bills/hr/hr1500/relatedDict.json"}, report.Corrupt)
+	assert.Equal(t, []string{"congress/data/116/bills/hr/hr1500/esSimilarity.json.tmp123"}, report.Stale)
+	assert.Equal(t, []string{"congress/data/116/bills/hr/hr1500/esSimilarBillsDict.json"}, report.Unrecorded)
+
+	// A bill without billMeta.json is reported as missing
+	assert.Nil(t, os.Remove(path.Join(billDir, BillMetaJsonFile)))
+	report, err = VerifyOutputs(parentPath)
+	assert.Nil(t, err)
+	assert.Contains(t, report.Missing, "congress/data/116/bills/hr/hr1500/billMeta.json")
+}
@@ -0,0 +1,198 @@
+package bills
+
+import (
+	"sort"
+	"sync"
+)
+
+// Holds the bill metadata and the title indexes built by one run of the metadata pipeline
+// (MakeBillsMeta, LoadTitles and LoadMainTitles). Each run gets its own MetaIndex,
+// so indexes for different congresses can be built concurrently in one process.
+// The accessors are safe for concurrent use.
+type MetaIndex struct {
+	mu sync.RWMutex
+	// bill number (e.g. 116hr1500) -> metadata
+	billMeta map[string]BillMeta
+	// title without the year -> bill numbers
+	titleNoYear map[string][]string
+	// title of the whole bill without the year -> bill numbers
+	mainTitleNoYear map[string][]string
+	// Titles in each index that have the same normalized form share one entry (see TitleNormalizer)
+	titleBuckets     *titleBuckets
+	mainTitleBuckets *titleBuckets
+	// Normalizes the titles for the title indexes; if nil, only titles that are the same (without the year) are matched.
+	// Set before adding titles.
+	TitleNormalizer *TitleNormalizer
+	// If set, used to add the party and chamber of sponsors and cosponsors in MakeBillsMeta
+	Legislators *LegislatorIndex
+	// If set, used to add the names and jurisdiction of the committees in MakeBillsMeta
+	Committees *CommitteeRegistry
+	// If set, used to add the cosponsors on the committees of referral in MakeBillsMeta
+	CommitteeMembership *CommitteeMembership
+	// Recorded in the provenance of the reasons for related bills (see ReasonProvenance)
+	RunId string
+}
+
+func NewMetaIndex() *MetaIndex {
+	return &MetaIndex{
+		billMeta:         make(map[string]BillMeta),
+		titleNoYear:      make(map[string][]string),
+		mainTitleNoYear:  make(map[string][]string),
+		titleBuckets:     newTitleBuckets(),
+		mainTitleBuckets: newTitleBuckets(),
+		TitleNormalizer:  DefaultTitleNormalizer,
+	}
+}
+
+func (mi *MetaIndex) GetBillMeta(billNumber string) (billMeta BillMeta, ok bool) {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	billMeta, ok = mi.billMeta[billNumber]
+	return billMeta, ok
+}
+
+func (mi *MetaIndex) SetBillMeta(billMeta BillMeta) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	mi.billMeta[billMeta.BillCongressTypeNumber] = billMeta
+}
+
+// Number of bills with metadata in the index
+func (mi *MetaIndex) Len() int {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	return len(mi.billMeta)
+}
+
+// Gets the sorted bill numbers of the bills with metadata in the index
+func (mi *MetaIndex) BillNumbers() []string {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	billNumbers := make([]string, 0, len(mi.billMeta))
+	for billNumber := range mi.billMeta {
+		billNumbers = append(billNumbers, billNumber)
+	}
+	sort.Strings(billNumbers)
+	return billNumbers
+}
+
+// Calls fn for the metadata of each bill, in order of bill number, until fn returns false
+func (mi *MetaIndex) RangeBillMeta(fn func(billNumber string, billMeta BillMeta) bool) {
+	for _, billNumber := range mi.BillNumbers() {
+		billMeta, ok := mi.GetBillMeta(billNumber)
+		if ok && !fn(billNumber, billMeta) {
+			return
+		}
+	}
+}
+
+func addToTitleIndex(titleIndex map[string][]string, title string, billNumber string) {
+	titleIndex[title] = RemoveDuplicates(append(titleIndex[title], billNumber))
+}
+
+// Gets the title under which the title is indexed: the name of the bucket of its normalized form, if there is one
+func (mi *MetaIndex) bucketName(buckets *titleBuckets, title string) string {
+	if mi.TitleNormalizer == nil {
+		return title
+	}
+	if name, ok := buckets.names[mi.TitleNormalizer.Normalize(title)]; ok {
+		return name
+	}
+	return title
+}
+
+// Adds the bill to the title index under the name of the title's bucket. If the title sorts before the name
+// of its bucket, the bucket is renamed to the title, so the names do not depend on the order bills are added.
+// The caller holds the lock.
+func (mi *MetaIndex) addToTitleBucket(titleIndex map[string][]string, buckets *titleBuckets, title string, billNumber string) {
+	buckets.titles[title] = true
+	if mi.TitleNormalizer == nil {
+		addToTitleIndex(titleIndex, title, billNumber)
+		return
+	}
+	normalizedTitle := mi.TitleNormalizer.Normalize(title)
+	name, ok := buckets.names[normalizedTitle]
+	if !ok || title < name {
+		if ok {
+			titleIndex[title] = RemoveDuplicates(append(titleIndex[title], titleIndex[name]...))
+			delete(titleIndex, name)
+		}
+		buckets.names[normalizedTitle] = title
+		name = title
+	}
+	addToTitleIndex(titleIndex, name, billNumber)
+}
+
+// Gets the number of titles added to each title index and the number of entries they were merged into
+func (mi *MetaIndex) TitleMergeStats() (titleStats TitleMergeStats, mainTitleStats TitleMergeStats) {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	titleStats = TitleMergeStats{Index: TitleNoYearIndex, Titles: len(mi.titleBuckets.titles), Buckets: len(mi.titleNoYear)}
+	mainTitleStats = TitleMergeStats{Index: MainTitleNoYearIndex, Titles: len(mi.mainTitleBuckets.titles), Buckets: len(mi.mainTitleNoYear)}
+	titleStats.Merged = titleStats.Titles - titleStats.Buckets
+	mainTitleStats.Merged = mainTitleStats.Titles - mainTitleStats.Buckets
+	return titleStats, mainTitleStats
+}
+
+func copyTitleIndex(titleIndex map[string][]string) map[string][]string {
+	titleIndexCopy := make(map[string][]string, len(titleIndex))
+	for title, billNumbers := range titleIndex {
+		titleIndexCopy[title] = append([]string{}, billNumbers...)
+	}
+	return titleIndexCopy
+}
+
+// Adds the bill to the title indexes, for each of its titles and main titles (see BillTitlesNoYear)
+func (mi *MetaIndex) AddBillTitles(billMeta BillMeta) {
+	titlesNoYear, mainTitlesNoYear := BillTitlesNoYear(billMeta)
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	for _, titleNoYear := range titlesNoYear {
+		mi.addToTitleBucket(mi.titleNoYear, mi.titleBuckets, titleNoYear, billMeta.BillCongressTypeNumber)
+	}
+	for _, mainTitleNoYear := range mainTitlesNoYear {
+		mi.addToTitleBucket(mi.mainTitleNoYear, mi.mainTitleBuckets, mainTitleNoYear, billMeta.BillCongressTypeNumber)
+	}
+}
+
+// Adds the bill to the index of titles without the year
+func (mi *MetaIndex) AddTitle(titleNoYear string, billNumber string) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	mi.addToTitleBucket(mi.titleNoYear, mi.titleBuckets, titleNoYear, billNumber)
+}
+
+// Adds the bill to the index of main titles without the year
+func (mi *MetaIndex) AddMainTitle(mainTitleNoYear string, billNumber string) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	mi.addToTitleBucket(mi.mainTitleNoYear, mi.mainTitleBuckets, mainTitleNoYear, billNumber)
+}
+
+// Gets the bills with the title (without the year), or a title with the same normalized form
+func (mi *MetaIndex) TitleBills(titleNoYear string) []string {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	return append([]string{}, mi.titleNoYear[mi.bucketName(mi.titleBuckets, titleNoYear)]...)
+}
+
+// Gets the bills with the main title (without the year), or a main title with the same normalized form
+func (mi *MetaIndex) MainTitleBills(mainTitleNoYear string) []string {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	return append([]string{}, mi.mainTitleNoYear[mi.bucketName(mi.mainTitleBuckets, mainTitleNoYear)]...)
+}
+
+// Gets a copy of the index of titles without the year, e.g. to save as titleNoYearIndexGo.json
+func (mi *MetaIndex) TitleIndex() map[string][]string {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	return copyTitleIndex(mi.titleNoYear)
+}
+
+// Gets a copy of the index of main titles without the year, e.g. to save as mainTitleNoYearIndexGo.json
+func (mi *MetaIndex) MainTitleIndex() map[string][]string {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	return copyTitleIndex(mi.mainTitleNoYear)
+}
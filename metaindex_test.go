@@ -0,0 +1,153 @@
+package bills
+
+import (
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetaIndexLoadTitles(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test adding title matches to the related bills in a MetaIndex")
+	metaIndex := NewMetaIndex()
+	for _, billNumber := range []string{"116hr1500", "117hr200", "117s100"} {
+		metaIndex.SetBillMeta(BillMeta{BillCongressTypeNumber: billNumber})
+	}
+	metaIndex.AddTitle("Consumers First Act", "116hr1500")
+	metaIndex.AddTitle("Consumers First Act", "117hr200")
+	metaIndex.AddMainTitle("Consumers First Act", "116hr1500")
+	metaIndex.AddMainTitle("Consumers First Act", "117hr200")
+	// Adding the same bill twice does not duplicate it
+	metaIndex.AddTitle("Consumers First Act", "117hr200")
+	assert.Equal(t, []string{"116hr1500", "117hr200"}, metaIndex.TitleBills("Consumers First Act"))
+
+	metaIndex.LoadTitles()
+	metaIndex.LoadMainTitles()
+
+	billMeta, ok := metaIndex.GetBillMeta("116hr1500")
+	assert.True(t, ok)
+	relatedBill, ok := billMeta.RelatedBillsByBillnumber["117hr200"]
+	assert.True(t, ok)
+	assert.Equal(t, []string{"Consumers First Act"}, relatedBill.Titles)
+	assert.Equal(t, []string{"Consumers First Act"}, relatedBill.TitlesWholeBill)
+	assert.Contains(t, relatedBill.Reason, TitleMatchReason)
+	assert.Contains(t, relatedBill.Reason, MainTitleMatchReason)
+
+	billMeta, _ = metaIndex.GetBillMeta("117s100")
+	assert.Equal(t, 0, len(billMeta.RelatedBillsByBillnumber))
+}
+
+func TestMetaIndexMakeBillsMetaConcurrent(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test building separate MetaIndexes for two congresses concurrently")
+	congresses := []string{"116", "117"}
+	metaIndexes := make([]*MetaIndex, len(congresses))
+	wg := &sync.WaitGroup{}
+	for i, congress := range congresses {
+		wg.Add(1)
+		go func(i int, congress string) {
+			defer wg.Done()
+			billStore, err := OpenBadgerBillStore(path.Join(t.TempDir(), "badger"))
+			if err != nil {
+				t.Errorf("Error opening Badger store: %v", err)
+				return
+			}
+			defer billStore.Close()
+			metaIndexes[i] = NewMetaIndex()
+			metaIndexes[i].MakeBillsMeta("samples", billStore, congress)
+			metaIndexes[i].LoadTitles()
+			metaIndexes[i].LoadMainTitles()
+		}(i, congress)
+	}
+	wg.Wait()
+
+	for i, congress := range congresses {
+		assert.NotEqual(t, 0, metaIndexes[i].Len())
+		for _, billNumber := range metaIndexes[i].BillNumbers() {
+			assert.Equal(t, congress, billNumber[:3])
+		}
+		for _, billNumbers := range metaIndexes[i].TitleIndex() {
+			for _, billNumber := range billNumbers {
+				assert.Equal(t, congress, billNumber[:3])
+			}
+		}
+	}
+	billMeta, ok := metaIndexes[0].GetBillMeta("116hr1500")
+	assert.True(t, ok)
+	assert.Equal(t, "Consumers First Act", billMeta.ShortTitle)
+	_, ok = metaIndexes[1].GetBillMeta("116hr1500")
+	assert.False(t, ok)
+}
+
+func TestMergeRelatedBill(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test merging a related bill with a new reason")
+	relatedBills := make(RelatedBillMap)
+	mergeRelatedBill(relatedBills, "116hr1500", MainTitleMatchReason, ReasonProvenance{}, nil)
+	assert.Equal(t, RelatedBillItem{
+		BillCongressTypeNumber: "116hr1500",
+		BillId:                 "hr1500-116",
+		Reason:                 NewReasonSet(MainTitleMatchReason),
+		IdentifiedBy:           NewSourceSet(IdentifiedByBillMap),
+		Provenance:             map[string]ReasonProvenance{MainTitleMatchReason: {IdentifiedBy: IdentifiedByBillMap}},
+	}, relatedBills["116hr1500"])
+
+	// A bill from data.json, identified by its BillId
+	relatedBills["115hr6972"] = RelatedBillItem{BillId: "hr6972-115", Reason: NewReasonSet("related"), IdentifiedBy: NewSourceSet("House")}
+	mergeRelatedBill(relatedBills, "115hr6972", TitleMatchReason, ReasonProvenance{Titles: []string{"Consumers First Act"}, RunId: "run1"}, func(relatedBillItem *RelatedBillItem) {
+		relatedBillItem.Titles = append(relatedBillItem.Titles, "Consumers First Act")
+	})
+	mergeRelatedBill(relatedBills, "115hr6972", TitleMatchReason, ReasonProvenance{Titles: []string{"Consumers First Act of 2018"}, RunId: "run2"}, nil)
+	relatedBill := relatedBills["115hr6972"]
+	assert.Equal(t, "115hr6972", relatedBill.BillCongressTypeNumber)
+	assert.Equal(t, "bills-title_match, related", relatedBill.Reason.String())
+	assert.Equal(t, "House, BillMap", relatedBill.IdentifiedBy.String())
+	assert.Equal(t, []string{"Consumers First Act"}, relatedBill.Titles)
+	assert.Equal(t, ReasonProvenance{IdentifiedBy: IdentifiedByBillMap, Titles: []string{"Consumers First Act", "Consumers First Act of 2018"}, RunId: "run2"}, relatedBill.Provenance[TitleMatchReason])
+}
+
+func TestMetaIndexLoadTitlesSamples(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test relating the sample bills by title and main title")
+	billStore, err := OpenBadgerBillStore(path.Join(t.TempDir(), "badger"))
+	assert.Nil(t, err)
+	defer billStore.Close()
+	metaIndex := NewMetaIndex()
+	metaIndex.MakeBillsMeta("samples", billStore, "115", "116")
+	metaIndex.LoadTitles()
+	metaIndex.LoadMainTitles()
+
+	for _, billNumbers := range [][2]string{{"116hr1500", "115hr6972"}, {"115hr6972", "116hr1500"}} {
+		billMeta, _ := metaIndex.GetBillMeta(billNumbers[0])
+		relatedBill, ok := billMeta.RelatedBillsByBillnumber[billNumbers[1]]
+		if !assert.True(t, ok, billNumbers[0]) {
+			continue
+		}
+		assert.ElementsMatch(t, []string{TitleMatchReason, MainTitleMatchReason}, []string(relatedBill.Reason))
+		assert.Equal(t, NewSourceSet(IdentifiedByBillMap), relatedBill.IdentifiedBy)
+		assert.Equal(t, BillNumberToBillId(billNumbers[1]), relatedBill.BillId)
+		assert.Contains(t, relatedBill.Titles, "Consumers First Act")
+		assert.Contains(t, relatedBill.TitlesWholeBill, "Consumers First Act")
+	}
+
+	// A bill related only by its main title is identified by the BillMap
+	metaIndex = NewMetaIndex()
+	metaIndex.SetBillMeta(BillMeta{BillCongressTypeNumber: "116hr1500"})
+	metaIndex.SetBillMeta(BillMeta{BillCongressTypeNumber: "115hr6972"})
+	metaIndex.AddMainTitle("Consumers First Act", "116hr1500")
+	metaIndex.AddMainTitle("Consumers First Act", "115hr6972")
+	metaIndex.LoadMainTitles()
+	billMeta, _ := metaIndex.GetBillMeta("116hr1500")
+	assert.Equal(t, RelatedBillItem{
+		BillCongressTypeNumber: "115hr6972",
+		BillId:                 "hr6972-115",
+		Reason:                 NewReasonSet(MainTitleMatchReason),
+		IdentifiedBy:           NewSourceSet(IdentifiedByBillMap),
+		TitlesWholeBill:        []string{"Consumers First Act"},
+		Provenance:             map[string]ReasonProvenance{MainTitleMatchReason: {IdentifiedBy: IdentifiedByBillMap, Titles: []string{"Consumers First Act"}}},
+	}, billMeta.RelatedBillsByBillnumber["115hr6972"])
+}
@@ -0,0 +1,127 @@
+package bills
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// Suffix of the fingerprint file stored next to each bill xml file, in place of its extension
+	// (document.xml -> document.ngrams.gob)
+	NgramCacheFileSuffix = ".ngrams.gob"
+	// Set to false to always re-tokenize documents
+	UseNgramCache = true
+	// Increment when the tokenizer or shingle hashing changes, to invalidate existing caches
+	ngramFingerprintVersion = 2
+	NgramSize               = 4
+)
+
+// A compact form of the n-gram map for one bill version:
+// each n-gram is stored as a 64-bit hash, with the number of occurences
+type NgramFingerprint struct {
+	Version  int
+	FileHash string
+	N        int
+	Filter   string // the BoilerplateElements removed before making the n-grams
+	Shingles map[uint64]int
+}
+
+// Hashes an n-gram (shingle) to a uint64
+func HashShingle(nGram string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(nGram))
+	return h.Sum64()
+}
+
+// Converts a map of n-grams (see MakeNgramMap) to a map of hashed shingles
+func ShingleMap(nGramMap map[string]int) map[uint64]int {
+	shingles := make(map[uint64]int, len(nGramMap))
+	for nGram, count := range nGramMap {
+		shingles[HashShingle(nGram)] += count
+	}
+	return shingles
+}
+
+// Returns the hex-encoded sha256 hash of file contents
+func FileHash(file []byte) string {
+	sum := sha256.Sum256(file)
+	return hex.EncodeToString(sum[:])
+}
+
+// Path of the fingerprint cache for a document path. Each xml file in a directory has its own cache.
+func NgramCachePath(docPath string) string {
+	return strings.TrimSuffix(docPath, filepath.Ext(docPath)) + NgramCacheFileSuffix
+}
+
+// Identifies the boilerplate filter used for a fingerprint
+func boilerplateFilterKey() string {
+	return strings.Join(BoilerplateElements, "\n")
+}
+
+// Creates the fingerprint for the contents of a bill xml file, after removing the BoilerplateElements
+func MakeNgramFingerprint(file []byte, n int) NgramFingerprint {
+	return NgramFingerprint{
+		Version:  ngramFingerprintVersion,
+		FileHash: FileHash(file),
+		N:        n,
+		Filter:   boilerplateFilterKey(),
+		Shingles: ShingleMap(boilerplateFreeNgramMap(file, n)),
+	}
+}
+
+// Reads a cached fingerprint. Returns ok = false if there is no cache,
+// or if the cache was made from a different file, n-gram size, boilerplate filter or fingerprint version
+func ReadNgramFingerprint(cachePath, fileHash string, n int) (fingerprint NgramFingerprint, ok bool) {
+	cached, err := os.ReadFile(cachePath)
+	if err != nil {
+		return fingerprint, false
+	}
+	if err := gob.NewDecoder(bytes.NewReader(cached)).Decode(&fingerprint); err != nil {
+		log.Debug().Msgf("Could not decode ngram cache %s: %s", cachePath, err)
+		return fingerprint, false
+	}
+	if fingerprint.Version != ngramFingerprintVersion || fingerprint.FileHash != fileHash || fingerprint.N != n || fingerprint.Filter != boilerplateFilterKey() {
+		return fingerprint, false
+	}
+	return fingerprint, true
+}
+
+// Writes the fingerprint to the cachePath
+func WriteNgramFingerprint(cachePath string, fingerprint NgramFingerprint) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(fingerprint); err != nil {
+		return err
+	}
+	return WriteFileAtomic(cachePath, buf.Bytes(), 0644)
+}
+
+// Gets the fingerprint for a document, from the cache if it is fresh.
+// Otherwise, tokenizes the document and (if UseNgramCache is set) updates the cache.
+func GetNgramFingerprint(docPath string, n int) (fingerprint NgramFingerprint, err error) {
+	file, err := os.ReadFile(docPath)
+	if err != nil {
+		return fingerprint, err
+	}
+	cachePath := NgramCachePath(docPath)
+	if UseNgramCache {
+		if fingerprint, ok := ReadNgramFingerprint(cachePath, FileHash(file), n); ok {
+			log.Debug().Msgf("Using cached ngrams for: %s", docPath)
+			return fingerprint, nil
+		}
+	}
+	fingerprint = MakeNgramFingerprint(file, n)
+	if UseNgramCache {
+		if writeErr := WriteNgramFingerprint(cachePath, fingerprint); writeErr != nil {
+			log.Error().Msgf("Error writing ngram cache to %s: %s", cachePath, writeErr)
+		}
+	}
+	return fingerprint, nil
+}
@@ -0,0 +1,45 @@
+package bills
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetNgramFingerprint(t *testing.T) {
+	log.Info().Msg("Test caching ngram fingerprints next to document.xml")
+	testutils.SetLogLevel()
+	docPath := path.Join(t.TempDir(), "document.xml")
+	if err := CopyFile(sampleFilePath, docPath); err != nil {
+		t.Fatalf("Error copying sample file: %v", err)
+	}
+	fingerprint, err := GetNgramFingerprint(docPath, NgramSize)
+	if err != nil {
+		t.Fatalf("Error getting fingerprint: %v", err)
+	}
+	assert.Greater(t, len(fingerprint.Shingles), 100)
+	assert.FileExists(t, NgramCachePath(docPath))
+
+	file, _ := os.ReadFile(docPath)
+	cached, ok := ReadNgramFingerprint(NgramCachePath(docPath), FileHash(file), NgramSize)
+	assert.True(t, ok)
+	assert.Equal(t, fingerprint, cached)
+
+	// The cache is stale when the document changes or the n-gram size is different
+	_, ok = ReadNgramFingerprint(NgramCachePath(docPath), FileHash(append(file, ' ')), NgramSize)
+	assert.False(t, ok)
+	_, ok = ReadNgramFingerprint(NgramCachePath(docPath), FileHash(file), NgramSize+1)
+	assert.False(t, ok)
+}
+
+func TestNgramCachePath(t *testing.T) {
+	log.Info().Msg("Test that each xml file in a directory has its own ngram cache")
+	testutils.SetLogLevel()
+	assert.Equal(t, path.Join("bills", "document.ngrams.gob"), NgramCachePath(path.Join("bills", "document.xml")))
+	assert.Equal(t, path.Join("bills", "BILLS-116hr1500ih.ngrams.gob"), NgramCachePath(path.Join("bills", "BILLS-116hr1500ih.xml")))
+	assert.NotEqual(t, NgramCachePath(path.Join("bills", "BILLS-116hr1500ih.xml")), NgramCachePath(path.Join("bills", "BILLS-116hr1500eh.xml")))
+}
@@ -0,0 +1,296 @@
+package bills
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// Files in each bill directory that are loaded to the bill_data table with LoadBills
+var PgLoadedBillFiles = []string{RelatedDictFile, EsSimilarBillsDictFile, EsSimilarCategoryFile}
+
+// Number of rows loaded to each table
+type PgLoadStats struct {
+	Bills           int `json:"bills"`
+	Versions        int `json:"versions"`
+	Cosponsors      int `json:"cosponsors"`
+	Committees      int `json:"committees"`
+	RelatedBills    int `json:"related_bills"`
+	SimilarSections int `json:"similar_sections"`
+	Titles          int `json:"titles"`
+	Errors          int `json:"errors"`
+}
+
+func (stats *PgLoadStats) add(other PgLoadStats) {
+	stats.Bills += other.Bills
+	stats.Versions += other.Versions
+	stats.Cosponsors += other.Cosponsors
+	stats.Committees += other.Committees
+	stats.RelatedBills += other.RelatedBills
+	stats.SimilarSections += other.SimilarSections
+	stats.Titles += other.Titles
+	stats.Errors += other.Errors
+}
+
+// The rows for one bill, for each of the tables filled by LoadBills
+type PgBillRows struct {
+	BillMeta        BillMeta
+	Files           map[string][]byte
+	Versions        [][]interface{}
+	Cosponsors      [][]interface{}
+	Committees      [][]interface{}
+	RelatedBills    [][]interface{}
+	SimilarSections [][]interface{}
+}
+
+func (rows PgBillRows) stats() PgLoadStats {
+	return PgLoadStats{
+		Bills:           1,
+		Versions:        len(rows.Versions),
+		Cosponsors:      len(rows.Cosponsors),
+		Committees:      len(rows.Committees),
+		RelatedBills:    len(rows.RelatedBills),
+		SimilarSections: len(rows.SimilarSections),
+	}
+}
+
+// Makes the table rows for a bill from its metadata and similarity files.
+// relatedDict is the content of relatedDict.json, similarBillsDict of esSimilarBillsDict.json and similarCategory of esSimilarCategory.json;
+// versions are the versions with a directory in text-versions, mapped to whether they have a document.xml
+func MakePgBillRows(billMeta BillMeta, relatedDict RelatedBillMap, similarBillsDict SimilarBillMap, similarCategory map[string]CompareItem, versions map[string]bool) (rows PgBillRows) {
+	billNumber := billMeta.BillCongressTypeNumber
+	rows.BillMeta = billMeta
+
+	versionNames := make([]string, 0, len(versions))
+	for version := range versions {
+		versionNames = append(versionNames, version)
+	}
+	sort.Strings(versionNames)
+	for _, version := range versionNames {
+		rows.Versions = append(rows.Versions, []interface{}{billNumber, version, billNumber + version, versions[version]})
+	}
+
+	for _, cosponsor := range billMeta.Cosponsors {
+		rows.Cosponsors = append(rows.Cosponsors, []interface{}{billNumber, cosponsor.BioguideId, cosponsor.ThomasId, cosponsor.Name, cosponsor.State, cosponsor.District, cosponsor.Title, cosponsor.OriginalCosponsor, cosponsor.SponsoredAt})
+	}
+
+	for _, committee := range billMeta.Committees {
+		activity := committee.Activity
+		if activity == nil {
+			activity = []string{}
+		}
+		rows.Committees = append(rows.Committees, []interface{}{billNumber, committee.CommitteeId, committee.Committee, committee.SubcommitteeId, committee.Subcommittee, activity})
+	}
+
+	// Related bills come from relatedDict.json (reasons) and esSimilarCategory.json (scores)
+	type relatedBillRow struct {
+		billNumberVersion string
+		item              RelatedBillItem
+		compareItem       *CompareItem
+	}
+	relatedBills := make(map[string]*relatedBillRow)
+	for relatedBillNumber, relatedBillItem := range relatedDict {
+		if relatedBillNumber == billNumber {
+			continue
+		}
+		relatedBills[relatedBillNumber] = &relatedBillRow{item: relatedBillItem}
+	}
+	// Keep the highest scoring version of the related bill
+	for relatedBillNumber, billNumberVersion := range BestCompareVersions(billNumber, similarCategory) {
+		compareItem := similarCategory[billNumberVersion]
+		row, ok := relatedBills[relatedBillNumber]
+		if !ok {
+			row = &relatedBillRow{}
+			relatedBills[relatedBillNumber] = row
+		}
+		row.compareItem = &compareItem
+		row.billNumberVersion = billNumberVersion
+	}
+	relatedBillNumbers := make([]string, 0, len(relatedBills))
+	for relatedBillNumber := range relatedBills {
+		relatedBillNumbers = append(relatedBillNumbers, relatedBillNumber)
+	}
+	sort.Strings(relatedBillNumbers)
+	for _, relatedBillNumber := range relatedBillNumbers {
+		row := relatedBills[relatedBillNumber]
+		var score, scoreOther interface{}
+		explanation := ""
+		if row.compareItem != nil {
+			score, scoreOther, explanation = row.compareItem.Score, row.compareItem.ScoreOther, row.compareItem.Explanation
+		}
+		rows.RelatedBills = append(rows.RelatedBills, []interface{}{billNumber, relatedBillNumber, row.billNumberVersion, row.item.Reason.String(), row.item.IdentifiedBy.String(), row.item.Type, score, scoreOther, explanation})
+	}
+
+	similarBillNumbers := make([]string, 0, len(similarBillsDict))
+	for similarBillNumber := range similarBillsDict {
+		similarBillNumbers = append(similarBillNumbers, similarBillNumber)
+	}
+	sort.Strings(similarBillNumbers)
+	for _, similarBillNumber := range similarBillNumbers {
+		for _, section := range similarBillsDict[similarBillNumber] {
+			rows.SimilarSections = append(rows.SimilarSections, []interface{}{billNumber, similarBillNumber, section.BillCongressTypeNumberVersion, section.Score, section.SectionNum, section.SectionHeader, section.SectionIndex, section.TargetSectionNumber, section.TargetSectionHeader, section.TargetSectionIndex})
+		}
+	}
+	return rows
+}
+
+// Reads billMeta.json and the files in PgLoadedBillFiles from the bill directory, and makes the table rows
+func ReadPgBillRows(parentPath string, billNumber string) (rows PgBillRows, err error) {
+	fileStore := NewFileBillStore(parentPath)
+	billMeta, err := fileStore.GetBillMeta(billNumber)
+	if err != nil {
+		return rows, err
+	}
+	files := make(map[string][]byte)
+	for _, fileName := range PgLoadedBillFiles {
+		data, err := fileStore.GetBillData(billNumber, fileName)
+		if err == ErrNotFound {
+			continue
+		} else if err != nil {
+			return rows, err
+		}
+		files[fileName] = data
+	}
+	var relatedDict RelatedBillMap
+	var similarBillsDict SimilarBillMap
+	var similarCategory map[string]CompareItem
+	for fileName, target := range map[string]interface{}{RelatedDictFile: &relatedDict, EsSimilarBillsDictFile: &similarBillsDict, EsSimilarCategoryFile: &similarCategory} {
+		if data, ok := files[fileName]; ok {
+			if err := json.Unmarshal(data, target); err != nil {
+				log.Error().Msgf("Error parsing %s for %s: %s", fileName, billNumber, err)
+				delete(files, fileName)
+			}
+		}
+	}
+	billDir, err := fileStore.billDir(billNumber)
+	if err != nil {
+		return rows, err
+	}
+	versions := make(map[string]bool)
+	versionsDir := path.Join(billDir, "text-versions")
+	if versionDirs, err := os.ReadDir(versionsDir); err == nil {
+		for _, versionDir := range versionDirs {
+			if !versionDir.IsDir() {
+				continue
+			}
+			_, statErr := os.Stat(path.Join(versionsDir, versionDir.Name(), "document.xml"))
+			versions[versionDir.Name()] = statErr == nil
+		}
+	}
+	if billMeta.BillCongressTypeNumber == "" {
+		billMeta.BillCongressTypeNumber = billNumber
+	}
+	rows = MakePgBillRows(billMeta, relatedDict, similarBillsDict, similarCategory, versions)
+	rows.Files = files
+	return rows, nil
+}
+
+// The rows of a table, loaded with COPY
+type pgCopyTable struct {
+	name    string
+	columns []string
+	rows    [][]interface{}
+}
+
+// Gets the rows for the tables that are replaced for each bill, in the order of their columns
+func (rows PgBillRows) copyTables() []pgCopyTable {
+	return []pgCopyTable{
+		{"bill_versions", []string{"bill_number", "version", "bill_number_version", "has_document"}, rows.Versions},
+		{"cosponsors", []string{"bill_number", "bioguide_id", "thomas_id", "name", "state", "district", "title", "original_cosponsor", "sponsored_at"}, rows.Cosponsors},
+		{"committees", []string{"bill_number", "committee_id", "committee", "subcommittee_id", "subcommittee", "activity"}, rows.Committees},
+		{"related_bills", []string{"bill_number", "related_bill_number", "related_bill_number_version", "reason", "identified_by", "type", "score", "score_other", "explanation"}, rows.RelatedBills},
+		{"similar_sections", []string{"bill_number", "similar_bill_number", "similar_bill_number_version", "score", "section_num", "section_header", "section_index", "target_section_num", "target_section_header", "target_section_index"}, rows.SimilarSections},
+	}
+}
+
+// Upserts the rows for one bill in a transaction. The rows of the bill in the other tables are replaced,
+// so loading the same files again leaves the database unchanged.
+func (s *PgBillStore) LoadBillRows(rows PgBillRows) error {
+	ctx := context.Background()
+	billNumber := rows.BillMeta.BillCongressTypeNumber
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := pgPutBillMeta(ctx, tx, rows.BillMeta); err != nil {
+		return err
+	}
+	for _, fileName := range PgLoadedBillFiles {
+		if data, ok := rows.Files[fileName]; ok {
+			if err := pgPutBillData(ctx, tx, billNumber, fileName, data); err != nil {
+				return err
+			}
+		}
+	}
+	for _, table := range rows.copyTables() {
+		if _, err := tx.Exec(ctx, "DELETE FROM "+table.name+" WHERE bill_number = $1", billNumber); err != nil {
+			return err
+		}
+		if len(table.rows) == 0 {
+			continue
+		}
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{table.name}, table.columns, pgx.CopyFromRows(table.rows)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// Loads the files of one bill (e.g. 116hr1500) from the congress tree in parentPath
+func (s *PgBillStore) LoadBill(parentPath string, billNumber string) (PgLoadStats, error) {
+	rows, err := ReadPgBillRows(parentPath, billNumber)
+	if err != nil {
+		return PgLoadStats{}, err
+	}
+	if err := s.LoadBillRows(rows); err != nil {
+		return PgLoadStats{}, err
+	}
+	return rows.stats(), nil
+}
+
+// Loads the title indexes (titleNoYearIndexGo.json and mainTitleNoYearIndexGo.json) in parentPath, if they exist
+func (s *PgBillStore) LoadTitleIndexes(parentPath string) (titles int, err error) {
+	fileStore := NewFileBillStore(parentPath)
+	for _, indexName := range []string{TitleNoYearIndex, MainTitleNoYearIndex} {
+		titleIndex, err := fileStore.GetTitleIndex(indexName)
+		if err == ErrNotFound {
+			log.Info().Msgf("No title index %s in %s", indexName, parentPath)
+			continue
+		} else if err != nil {
+			return titles, err
+		}
+		if err := s.PutTitleIndex(indexName, titleIndex); err != nil {
+			return titles, err
+		}
+		titles += len(titleIndex)
+	}
+	return titles, nil
+}
+
+// Loads billMeta.json, relatedDict.json, esSimilarBillsDict.json and esSimilarCategory.json for each bill
+// in the congress tree of parentPath, and the title indexes. Bills that fail to load are logged and counted in Errors.
+func (s *PgBillStore) LoadBills(parentPath string) (stats PgLoadStats, err error) {
+	billNumbers, err := NewFileBillStore(parentPath).ListBillMeta()
+	if err != nil {
+		return stats, err
+	}
+	log.Info().Msgf("Loading %d bills to Postgres", len(billNumbers))
+	for i, billNumber := range billNumbers {
+		log.Info().Msgf("[%d] Loading %s", i+1, billNumber)
+		billStats, err := s.LoadBill(parentPath, billNumber)
+		if err != nil {
+			log.Error().Msgf("Error loading %s: %s", billNumber, err)
+			stats.Errors++
+			continue
+		}
+		stats.add(billStats)
+	}
+	stats.Titles, err = s.LoadTitleIndexes(parentPath)
+	return stats, err
+}
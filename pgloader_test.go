@@ -0,0 +1,178 @@
+package bills
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/jackc/pgconn"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakePgBillRows(t *testing.T) {
+	log.Info().Msg("Test making the Postgres rows for a bill")
+	testutils.SetLogLevel()
+	billMeta := readSampleBillMeta(t, sampleBillMetaPaths[0])
+	relatedDict := RelatedBillMap{
+		"116hr1500": {Reason: NewReasonSet("bills-identical"), IdentifiedBy: NewSourceSet(IdentifiedByBillMap)},
+		"116s1":     {Reason: NewReasonSet(TitleMatchReason), IdentifiedBy: NewSourceSet(IdentifiedByBillMap), Type: "related"},
+	}
+	similarCategory := map[string]CompareItem{
+		"116hr1500eh": {Score: 1, ScoreOther: 1, Explanation: "bills-identical"},
+		"115hr6972ih": {Score: .4, ScoreOther: .2, Explanation: "bills-some_similarity"},
+		"115hr6972rh": {Score: .5, ScoreOther: .3, Explanation: "bills-some_similarity"},
+	}
+	similarBillsDict := SimilarBillMap{
+		"115hr6972": {{Score: 30, SectionNum: "2.", SectionHeader: "Findings", BillCongressTypeNumberVersion: "115hr6972ih"}},
+	}
+	rows := MakePgBillRows(billMeta, relatedDict, similarBillsDict, similarCategory, map[string]bool{"ih": true, "eh": false})
+
+	assert.Equal(t, []interface{}{"116hr1500", "eh", "116hr1500eh", false}, rows.Versions[0])
+	assert.Equal(t, len(billMeta.Cosponsors), len(rows.Cosponsors))
+	assert.Equal(t, len(billMeta.Committees), len(rows.Committees))
+	// The bill itself is not related to itself; the highest scoring version of 115hr6972 is kept
+	assert.Equal(t, [][]interface{}{
+		{"116hr1500", "115hr6972", "115hr6972rh", "", "", "", .5, .3, "bills-some_similarity"},
+		{"116hr1500", "116s1", "", TitleMatchReason, IdentifiedByBillMap, "related", nil, nil, ""},
+	}, rows.RelatedBills)
+	assert.Equal(t, 1, len(rows.SimilarSections))
+	assert.Equal(t, "115hr6972ih", rows.SimilarSections[0][2])
+}
+
+func TestReadPgBillRows(t *testing.T) {
+	log.Info().Msg("Test reading the Postgres rows for a sample bill")
+	testutils.SetLogLevel()
+	rows, err := ReadPgBillRows("samples", "116hr1500")
+	if err != nil {
+		t.Fatalf("Error reading bill rows: %v", err)
+	}
+	assert.Equal(t, "116hr1500", rows.BillMeta.BillCongressTypeNumber)
+	assert.Equal(t, 4, len(rows.Versions))
+	assert.Equal(t, true, rows.Versions[0][3])
+	_, err = ReadPgBillRows("samples", "116hr1")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+// Gets the columns of each table in PgBillStoreSchema
+func pgSchemaColumns() map[string][]string {
+	tables := make(map[string][]string)
+	for _, statement := range PgBillStoreSchema {
+		if !strings.HasPrefix(statement, "CREATE TABLE IF NOT EXISTS ") {
+			continue
+		}
+		table := strings.Fields(statement)[5]
+		lines := strings.Split(statement, "\n")
+		for _, line := range lines[1 : len(lines)-1] {
+			column := strings.Fields(line)[0]
+			if column != "PRIMARY" {
+				tables[table] = append(tables[table], column)
+			}
+		}
+	}
+	return tables
+}
+
+// Records the statements run by pgPutBillMeta and pgPutBillData
+type pgRecorder struct {
+	statements []string
+	args       [][]interface{}
+}
+
+func (recorder *pgRecorder) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	recorder.statements = append(recorder.statements, sql)
+	recorder.args = append(recorder.args, arguments)
+	return nil, nil
+}
+
+var (
+	pgPlaceholderRegexCompiled = regexp.MustCompile(`\$([0-9]+)`)
+	pgInsertRegexCompiled      = regexp.MustCompile(`(?s)INSERT INTO (\w+) \(([^)]*)\)\s*VALUES \(([^)]*)\)`)
+)
+
+func TestPgBillRowsSQL(t *testing.T) {
+	log.Info().Msg("Test that the Postgres statements and COPY rows match the schema, without a database")
+	testutils.SetLogLevel()
+	schemaColumns := pgSchemaColumns()
+	assert.Equal(t, []string{"bill_number", "congress", "bill_type", "number", "meta", "updated_at"}, schemaColumns["bills"])
+
+	rows, err := ReadPgBillRows("samples", "116hr1500")
+	if err != nil {
+		t.Fatalf("Error reading bill rows: %v", err)
+	}
+	copyTables := append(rows.copyTables(), titleIndexCopyTable(TitleNoYearIndex, map[string][]string{"Consumers First Act": {"116hr1500"}}))
+	for _, table := range copyTables {
+		assert.Subset(t, schemaColumns[table.name], table.columns, table.name)
+		for _, row := range table.rows {
+			assert.Equal(t, len(table.columns), len(row), table.name)
+		}
+	}
+	assert.Greater(t, len(copyTables[1].rows), 0)
+
+	recorder := &pgRecorder{}
+	ctx := context.Background()
+	assert.Nil(t, pgPutBillMeta(ctx, recorder, rows.BillMeta))
+	assert.Nil(t, pgPutBillData(ctx, recorder, "116hr1500", RelatedDictFile, []byte(`{}`)))
+	assert.Equal(t, 2, len(recorder.statements))
+	for i, statement := range recorder.statements {
+		// Each argument has a placeholder, and each inserted column a value
+		maxPlaceholder := 0
+		for _, match := range pgPlaceholderRegexCompiled.FindAllStringSubmatch(statement, -1) {
+			if n, _ := strconv.Atoi(match[1]); n > maxPlaceholder {
+				maxPlaceholder = n
+			}
+		}
+		assert.Equal(t, len(recorder.args[i]), maxPlaceholder, statement)
+		insert := pgInsertRegexCompiled.FindStringSubmatch(statement)
+		if assert.NotNil(t, insert, statement) {
+			columns := strings.Split(strings.ReplaceAll(insert[2], " ", ""), ",")
+			assert.Subset(t, schemaColumns[insert[1]], columns, statement)
+			assert.Equal(t, len(columns), len(strings.Split(insert[3], ",")), statement)
+		}
+	}
+
+	sql, args := pgBillMetaQuery(BillMetaQuery{Congress: "116", BillType: "hr", CommitteeId: "HSBA", Cosponsor: "A000370", Sponsor: "W000187", Subject: "Aging", Title: "Consumers First Act"})
+	assert.Equal(t, 7, len(args))
+	assert.Contains(t, sql, "$7")
+	assert.NotContains(t, sql, "$8")
+}
+
+// Set TEST_DATABASE_URL to a disposable database to run this test; the bill tables are emptied first
+func TestPgLoadBills(t *testing.T) {
+	log.Info().Msg("Test loading the sample bills to Postgres")
+	testutils.SetLogLevel()
+	databaseUrl := os.Getenv("TEST_DATABASE_URL")
+	if databaseUrl == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	pgStore, err := OpenPgBillStore(databaseUrl)
+	if err != nil {
+		t.Fatalf("Error opening Postgres store: %v", err)
+	}
+	defer pgStore.Close()
+	ctx := context.Background()
+	if _, err := pgStore.Pool().Exec(ctx, "TRUNCATE bills, bill_data, title_index CASCADE"); err != nil {
+		t.Fatalf("Error emptying the tables: %v", err)
+	}
+	stats, err := pgStore.LoadBills("samples")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, stats.Errors)
+	assert.Greater(t, stats.Cosponsors, 0)
+
+	// Loading again does not add rows
+	statsAgain, err := pgStore.LoadBills("samples")
+	assert.Nil(t, err)
+	assert.Equal(t, stats, statsAgain)
+	for table, expected := range map[string]int{"bills": stats.Bills, "bill_versions": stats.Versions, "cosponsors": stats.Cosponsors, "committees": stats.Committees} {
+		var count int
+		assert.Nil(t, pgStore.Pool().QueryRow(ctx, "SELECT count(*) FROM "+table).Scan(&count))
+		assert.Equal(t, expected, count, table)
+	}
+	billMetas, err := pgStore.QueryBillMeta(BillMetaQuery{Congress: "116", CommitteeId: "HSBA"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(billMetas))
+}
@@ -0,0 +1,347 @@
+package bills
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Tables for the Postgres store; created if they do not exist when the store is opened
+var PgBillStoreSchema = []string{
+	`CREATE TABLE IF NOT EXISTS bills (
+		bill_number text PRIMARY KEY,
+		congress text NOT NULL,
+		bill_type text NOT NULL,
+		number text NOT NULL,
+		meta jsonb NOT NULL,
+		updated_at timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS bills_congress_bill_type_idx ON bills (congress, bill_type)`,
+	`CREATE TABLE IF NOT EXISTS bill_data (
+		bill_number text NOT NULL,
+		file_name text NOT NULL,
+		data jsonb NOT NULL,
+		updated_at timestamptz NOT NULL DEFAULT now(),
+		PRIMARY KEY (bill_number, file_name)
+	)`,
+	`CREATE TABLE IF NOT EXISTS title_index (
+		index_name text NOT NULL,
+		title text NOT NULL,
+		bill_numbers text[] NOT NULL,
+		normalized_title text NOT NULL,
+		normalization text NOT NULL,
+		PRIMARY KEY (index_name, title)
+	)`,
+	`CREATE INDEX IF NOT EXISTS title_index_normalized_title_idx ON title_index (index_name, normalized_title)`,
+	// The tables below are filled by LoadBills, from the files in each bill directory
+	`CREATE TABLE IF NOT EXISTS bill_versions (
+		bill_number text NOT NULL REFERENCES bills ON DELETE CASCADE,
+		version text NOT NULL,
+		bill_number_version text NOT NULL,
+		has_document boolean NOT NULL,
+		PRIMARY KEY (bill_number, version)
+	)`,
+	`CREATE TABLE IF NOT EXISTS cosponsors (
+		bill_number text NOT NULL REFERENCES bills ON DELETE CASCADE,
+		bioguide_id text NOT NULL,
+		thomas_id text NOT NULL,
+		name text NOT NULL,
+		state text NOT NULL,
+		district text NOT NULL,
+		title text NOT NULL,
+		original_cosponsor boolean NOT NULL,
+		sponsored_at text NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS cosponsors_bill_number_idx ON cosponsors (bill_number)`,
+	`CREATE INDEX IF NOT EXISTS cosponsors_bioguide_id_idx ON cosponsors (bioguide_id)`,
+	`CREATE TABLE IF NOT EXISTS committees (
+		bill_number text NOT NULL REFERENCES bills ON DELETE CASCADE,
+		committee_id text NOT NULL,
+		committee text NOT NULL,
+		subcommittee_id text NOT NULL,
+		subcommittee text NOT NULL,
+		activity text[] NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS committees_bill_number_idx ON committees (bill_number)`,
+	`CREATE INDEX IF NOT EXISTS committees_committee_id_idx ON committees (committee_id)`,
+	`CREATE TABLE IF NOT EXISTS related_bills (
+		bill_number text NOT NULL REFERENCES bills ON DELETE CASCADE,
+		related_bill_number text NOT NULL,
+		related_bill_number_version text NOT NULL,
+		reason text NOT NULL,
+		identified_by text NOT NULL,
+		type text NOT NULL,
+		score double precision,
+		score_other double precision,
+		explanation text NOT NULL,
+		PRIMARY KEY (bill_number, related_bill_number)
+	)`,
+	`CREATE INDEX IF NOT EXISTS related_bills_related_bill_number_idx ON related_bills (related_bill_number)`,
+	`CREATE TABLE IF NOT EXISTS similar_sections (
+		bill_number text NOT NULL REFERENCES bills ON DELETE CASCADE,
+		similar_bill_number text NOT NULL,
+		similar_bill_number_version text NOT NULL,
+		score real NOT NULL,
+		section_num text NOT NULL,
+		section_header text NOT NULL,
+		section_index text NOT NULL,
+		target_section_num text NOT NULL,
+		target_section_header text NOT NULL,
+		target_section_index text NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS similar_sections_bill_number_idx ON similar_sections (bill_number)`,
+}
+
+// Stores the bill data in Postgres: metadata in the bills table, other JSON data
+// (e.g. esSimilarBillsDict.json) in bill_data, and the title indexes in title_index
+type PgBillStore struct {
+	pool *pgxpool.Pool
+}
+
+// Returns the databaseUrl or, if it is empty, DATABASE_URL from the environment or the .env file
+func PgDatabaseUrl(databaseUrl string) string {
+	if databaseUrl == "" {
+		_ = LoadEnv()
+		databaseUrl = os.Getenv("DATABASE_URL")
+	}
+	return databaseUrl
+}
+
+// Connects to the database at the databaseUrl and creates the tables, if needed
+func OpenPgBillStore(databaseUrl string) (*PgBillStore, error) {
+	if databaseUrl == "" {
+		return nil, fmt.Errorf("no database url; set DATABASE_URL or pass a url")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.Connect(ctx, databaseUrl)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to database: %s", err)
+	}
+	for _, statement := range PgBillStoreSchema {
+		if _, err := pool.Exec(ctx, statement); err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("error creating schema: %s", err)
+		}
+	}
+	return &PgBillStore{pool: pool}, nil
+}
+
+// The connection pool, e.g. to run other queries against the store's tables
+func (s *PgBillStore) Pool() *pgxpool.Pool {
+	return s.pool
+}
+
+func pgNotFound(err error) error {
+	if err == pgx.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *PgBillStore) GetBillMeta(billNumber string) (billMeta BillMeta, err error) {
+	var meta []byte
+	err = s.pool.QueryRow(context.Background(), `SELECT meta FROM bills WHERE bill_number = $1`, billNumber).Scan(&meta)
+	if err != nil {
+		return billMeta, pgNotFound(err)
+	}
+	err = json.Unmarshal(meta, &billMeta)
+	return billMeta, err
+}
+
+// Runs the queries of PgBillStore on the pool or in a transaction
+type pgExecer interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+}
+
+func pgPutBillMeta(ctx context.Context, db pgExecer, billMeta BillMeta) error {
+	meta, err := json.Marshal(billMeta)
+	if err != nil {
+		return fmt.Errorf("error marshalling metadata for: %s\nErr: %s", billMeta.BillCongressTypeNumber, err)
+	}
+	_, err = db.Exec(ctx, `INSERT INTO bills (bill_number, congress, bill_type, number, meta)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (bill_number) DO UPDATE
+		SET congress = EXCLUDED.congress, bill_type = EXCLUDED.bill_type, number = EXCLUDED.number, meta = EXCLUDED.meta, updated_at = now()`,
+		billMeta.BillCongressTypeNumber, billMeta.Congress, billMeta.BillType, billMeta.Number, string(meta))
+	return err
+}
+
+func pgPutBillData(ctx context.Context, db pgExecer, billNumber string, fileName string, data []byte) error {
+	_, err := db.Exec(ctx, `INSERT INTO bill_data (bill_number, file_name, data)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (bill_number, file_name) DO UPDATE SET data = EXCLUDED.data, updated_at = now()`,
+		billNumber, fileName, string(data))
+	return err
+}
+
+func (s *PgBillStore) PutBillMeta(billMeta BillMeta) error {
+	return pgPutBillMeta(context.Background(), s.pool, billMeta)
+}
+
+func (s *PgBillStore) queryStrings(sql string, args ...interface{}) (values []string, err error) {
+	rows, err := s.pool.Query(context.Background(), sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+func (s *PgBillStore) ListBillMeta() ([]string, error) {
+	return s.queryStrings(`SELECT bill_number FROM bills ORDER BY bill_number`)
+}
+
+// Builds the SQL and arguments for a BillMetaQuery
+func pgBillMetaQuery(query BillMetaQuery) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if query.Congress != "" {
+		addCondition("congress = $%d", query.Congress)
+	}
+	if query.BillType != "" {
+		addCondition("bill_type = $%d", query.BillType)
+	}
+	if query.CommitteeId != "" {
+		committees, _ := json.Marshal([]map[string]string{{"committee_id": query.CommitteeId}})
+		addCondition("meta->'committees' @> $%d::jsonb", string(committees))
+	}
+	if query.Cosponsor != "" {
+		cosponsors, _ := json.Marshal([]map[string]string{{"bioguide_id": query.Cosponsor}})
+		addCondition("meta->'cosponsors' @> $%d::jsonb", string(cosponsors))
+	}
+	if query.Sponsor != "" {
+		addCondition("meta->'sponsor'->>'bioguide_id' = $%d", query.Sponsor)
+	}
+	if query.Subject != "" {
+		addCondition("(lower(meta->>'subjects_top_term') = lower($%[1]d) OR EXISTS (SELECT 1 FROM jsonb_array_elements_text(COALESCE(meta->'subjects', '[]'::jsonb)) AS s(subject) WHERE lower(s.subject) = lower($%[1]d)))", query.Subject)
+	}
+	if query.Title != "" {
+		addCondition("EXISTS (SELECT 1 FROM jsonb_array_elements_text(meta->'titles') AS t(title) WHERE lower(t.title) = lower($%d))", query.Title)
+	}
+	sql := "SELECT meta FROM bills"
+	if len(conditions) > 0 {
+		sql += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	return sql + " ORDER BY bill_number", args
+}
+
+func (s *PgBillStore) QueryBillMeta(query BillMetaQuery) (billMetas []BillMeta, err error) {
+	sql, args := pgBillMetaQuery(query)
+	rows, err := s.pool.Query(context.Background(), sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var meta []byte
+		if err := rows.Scan(&meta); err != nil {
+			return nil, err
+		}
+		var billMeta BillMeta
+		if err := json.Unmarshal(meta, &billMeta); err != nil {
+			return nil, err
+		}
+		billMetas = append(billMetas, billMeta)
+	}
+	return billMetas, rows.Err()
+}
+
+func (s *PgBillStore) GetBillData(billNumber string, fileName string) (data []byte, err error) {
+	err = s.pool.QueryRow(context.Background(), `SELECT data FROM bill_data WHERE bill_number = $1 AND file_name = $2`, billNumber, fileName).Scan(&data)
+	return data, pgNotFound(err)
+}
+
+func (s *PgBillStore) PutBillData(billNumber string, fileName string, data []byte) error {
+	return pgPutBillData(context.Background(), s.pool, billNumber, fileName, data)
+}
+
+func (s *PgBillStore) ListBillData(fileName string) ([]string, error) {
+	return s.queryStrings(`SELECT bill_number FROM bill_data WHERE file_name = $1 ORDER BY bill_number`, fileName)
+}
+
+func (s *PgBillStore) GetTitleIndex(indexName string) (map[string][]string, error) {
+	rows, err := s.pool.Query(context.Background(), `SELECT title, bill_numbers FROM title_index WHERE index_name = $1`, indexName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	titleIndex := make(map[string][]string)
+	for rows.Next() {
+		var title string
+		var billNumbers []string
+		if err := rows.Scan(&title, &billNumbers); err != nil {
+			return nil, err
+		}
+		titleIndex[title] = billNumbers
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(titleIndex) == 0 {
+		return nil, ErrNotFound
+	}
+	return titleIndex, nil
+}
+
+// Gets the title_index rows of the index, with each title as NormalizeTitle returns it (for QueryTitleIndex)
+// and the normalization it was made with
+func titleIndexCopyTable(indexName string, titleIndex map[string][]string) pgCopyTable {
+	format := titleIndexFormat()
+	rows := make([][]interface{}, 0, len(titleIndex))
+	for title, billNumbers := range titleIndex {
+		rows = append(rows, []interface{}{indexName, title, billNumbers, NormalizeTitle(title), format})
+	}
+	return pgCopyTable{"title_index", []string{"index_name", "title", "bill_numbers", "normalized_title", "normalization"}, rows}
+}
+
+// Replaces the title index in one transaction
+func (s *PgBillStore) PutTitleIndex(indexName string, titleIndex map[string][]string) error {
+	ctx := context.Background()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `DELETE FROM title_index WHERE index_name = $1`, indexName); err != nil {
+		return err
+	}
+	table := titleIndexCopyTable(indexName, titleIndex)
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{table.name}, table.columns, pgx.CopyFromRows(table.rows)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *PgBillStore) QueryTitleIndex(indexName string, title string) ([]string, error) {
+	billNumbers, err := s.queryStrings(`SELECT DISTINCT unnest(bill_numbers) AS bill_number FROM title_index
+		WHERE index_name = $1 AND normalized_title = $2 ORDER BY bill_number`, indexName, NormalizeTitle(title))
+	if err != nil {
+		return nil, err
+	}
+	if len(billNumbers) == 0 {
+		return nil, ErrNotFound
+	}
+	return billNumbers, nil
+}
+
+func (s *PgBillStore) Close() error {
+	s.pool.Close()
+	return nil
+}
@@ -0,0 +1,265 @@
+package bills
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Weights of the evidence for a reintroduction. When the text of the bills is not compared,
+// the score is the weighted title and sponsor evidence alone.
+const (
+	reintroductionTitleWeight   = 0.4
+	reintroductionSponsorWeight = 0.2
+	reintroductionTextWeight    = 0.4
+	// Documents kept in memory while comparing texts; the cache is cleared when it is full
+	reintroductionMaxCachedDocs = 1000
+)
+
+// Versions compared as the text of the bill when it was introduced, in order of preference
+var introducedVersions = []string{"ih", "is", "iph", "ips", "ath", "ats"}
+
+// A bill in another congress that is, or is reintroduced as, this bill
+type Reintroduction struct {
+	BillCongressTypeNumber string  `json:"bill_congress_type_number"`
+	BillId                 string  `json:"bill_id"`
+	Score                  float64 `json:"score"`
+	// 1 for the same title (after normalization), or the similarity of the most similar titles (see TitleMatcher)
+	TitleSimilarity float64 `json:"title_similarity"`
+	SameSponsor     bool    `json:"same_sponsor"`
+	// Share of the n-grams of the introduced versions that are in both (the lower of the two shares); set if TextCompared
+	TextSimilarity float64 `json:"text_similarity"`
+	TextCompared   bool    `json:"text_compared"`
+}
+
+// Finds, for each bill, its most likely predecessor in the previous congresses: a bill with the same or a similar title
+// (from the related bills found by MetaIndex.LoadTitles, LoadMainTitles and LoadSimilarTitles) or, if the texts are
+// compared, a bill of the same type by the same sponsor. The candidates are scored by title similarity, sponsor identity
+// and the n-gram similarity of the introduced versions (see NgramFingerprint).
+type ReintroductionDetector struct {
+	// Number of previous congresses to search (e.g. 2, for congresses N-1 and N-2)
+	Congresses int
+	// Minimum score (0 to 1) of a predecessor
+	MinScore float64
+	// Path to the 'data' directory of the congress tree (e.g. ../../../congress/data), to compare the texts of the bills;
+	// if empty, only titles and sponsors are compared
+	DataPath string
+	docMaps  map[string]*docMap
+}
+
+func NewReintroductionDetector(dataPath string) *ReintroductionDetector {
+	return &ReintroductionDetector{Congresses: 2, MinScore: 0.6, DataPath: dataPath}
+}
+
+// Gets the congress (e.g. 116) and stage (e.g. 'hr') of a bill number (e.g. 116hr1500)
+func billCongressStage(billNumber string) (congress int, stage string) {
+	matchMap := FindNamedMatches(BillnumberRegexCompiled, billNumber)
+	congress, _ = strconv.Atoi(matchMap["congress"])
+	return congress, matchMap["stage"]
+}
+
+// Gets the path to the document.xml of the introduced version of the bill, or the earliest version there is
+func introducedDocPath(dataPath string, billNumber string) (docPath string, ok bool) {
+	billPath, err := PathFromBillNumber(billNumber + introducedVersions[0])
+	if err != nil {
+		return "", false
+	}
+	versionsDir := path.Join(dataPath, path.Dir(billPath))
+	entries, err := os.ReadDir(versionsDir)
+	if err != nil {
+		return "", false
+	}
+	var versions []string
+	for _, entry := range entries {
+		if _, err := os.Stat(path.Join(versionsDir, entry.Name(), "document.xml")); err == nil {
+			versions = append(versions, entry.Name())
+		}
+	}
+	if len(versions) == 0 {
+		return "", false
+	}
+	versionOrder := func(version string) int {
+		if i, ok := Find(introducedVersions, version); ok {
+			return i - len(introducedVersions)
+		}
+		if order, ok := BillVersionsOrdered[version]; ok {
+			return order
+		}
+		return len(BillVersionsOrdered)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		if versionOrder(versions[i]) != versionOrder(versions[j]) {
+			return versionOrder(versions[i]) < versionOrder(versions[j])
+		}
+		return versions[i] < versions[j]
+	})
+	return path.Join(versionsDir, versions[0], "document.xml"), true
+}
+
+// Gets the n-grams of the introduced version of the bill; nil if the bill has no text in the DataPath
+func (detector *ReintroductionDetector) introducedDocMap(billNumber string) *docMap {
+	if detector.docMaps == nil || len(detector.docMaps) >= reintroductionMaxCachedDocs {
+		detector.docMaps = make(map[string]*docMap)
+	}
+	if docMapItem, ok := detector.docMaps[billNumber]; ok {
+		return docMapItem
+	}
+	var docMapItem *docMap
+	if docPath, ok := introducedDocPath(detector.DataPath, billNumber); ok {
+		var err error
+		if docMapItem, err = makeDocMap(docPath); err != nil {
+			log.Error().Msgf("Error reading %s: %s", docPath, err)
+		}
+	}
+	detector.docMaps[billNumber] = docMapItem
+	return docMapItem
+}
+
+// Scores the candidate as a predecessor of the bill
+func (detector *ReintroductionDetector) score(billMeta BillMeta, candidate BillMeta, titleSimilarity float64) Reintroduction {
+	reintroduction := Reintroduction{
+		BillCongressTypeNumber: candidate.BillCongressTypeNumber,
+		BillId:                 BillNumberToBillId(candidate.BillCongressTypeNumber),
+		TitleSimilarity:        titleSimilarity,
+		SameSponsor:            billMeta.Sponsor.BioguideId != "" && billMeta.Sponsor.BioguideId == candidate.Sponsor.BioguideId,
+	}
+	weights := reintroductionTitleWeight + reintroductionSponsorWeight
+	score := reintroductionTitleWeight * titleSimilarity
+	if reintroduction.SameSponsor {
+		score += reintroductionSponsorWeight
+	}
+	if detector.DataPath != "" {
+		docMapItem, candidateDocMap := detector.introducedDocMap(billMeta.BillCongressTypeNumber), detector.introducedDocMap(candidate.BillCongressTypeNumber)
+		if docMapItem != nil && candidateDocMap != nil {
+			scorei, scorej, _, _ := compareDocMaps(docMapItem, candidateDocMap)
+			reintroduction.TextSimilarity = scorei
+			if scorej < scorei {
+				reintroduction.TextSimilarity = scorej
+			}
+			reintroduction.TextCompared = true
+			weights += reintroductionTextWeight
+			score += reintroductionTextWeight * reintroduction.TextSimilarity
+		}
+	}
+	reintroduction.Score = roundShare(score / weights)
+	return reintroduction
+}
+
+// Gets the candidate predecessors of the bill, with their title similarity
+func (detector *ReintroductionDetector) candidates(billMeta BillMeta, billsBySponsor map[string][]string) map[string]float64 {
+	congress, stage := billCongressStage(billMeta.BillCongressTypeNumber)
+	inRange := func(billNumber string) bool {
+		candidateCongress, _ := billCongressStage(billNumber)
+		return candidateCongress < congress && candidateCongress >= congress-detector.Congresses
+	}
+	candidates := make(map[string]float64)
+	for relatedBillNumber, relatedBillItem := range billMeta.RelatedBillsByBillnumber {
+		if !inRange(relatedBillNumber) {
+			continue
+		}
+		switch {
+		case relatedBillItem.HasReason(MainTitleMatchReason), relatedBillItem.HasReason(TitleMatchReason):
+			candidates[relatedBillNumber] = 1
+		case relatedBillItem.HasReason(TitleSimilarReason):
+			candidates[relatedBillNumber] = relatedBillItem.TitleSimilarity
+		}
+	}
+	// Bills by the same sponsor are only candidates if their texts can be compared
+	if detector.DataPath != "" && billMeta.Sponsor.BioguideId != "" {
+		for _, sponsorBillNumber := range billsBySponsor[billMeta.Sponsor.BioguideId] {
+			if _, sponsorStage := billCongressStage(sponsorBillNumber); sponsorStage != stage || !inRange(sponsorBillNumber) {
+				continue
+			}
+			if _, ok := candidates[sponsorBillNumber]; !ok {
+				candidates[sponsorBillNumber] = 0
+			}
+		}
+	}
+	return candidates
+}
+
+// Finds the predecessor of each bill in the index (see ReintroductionDetector). Sets ReintroductionOf of the bill and
+// ReintroducedAs of the predecessor, and relates them with ReintroductionOfReason and ReintroducedAsReason.
+// Run after LoadTitles, LoadMainTitles and LoadSimilarTitles, which find the candidates by title.
+func (mi *MetaIndex) LoadReintroductions(detector *ReintroductionDetector) {
+	log.Info().Msg("***** Processing reintroductions ******")
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	billNumbers := make([]string, 0, len(mi.billMeta))
+	billsBySponsor := make(map[string][]string)
+	for billNumber, billMeta := range mi.billMeta {
+		billNumbers = append(billNumbers, billNumber)
+		if billMeta.Sponsor.BioguideId != "" {
+			billsBySponsor[billMeta.Sponsor.BioguideId] = append(billsBySponsor[billMeta.Sponsor.BioguideId], billNumber)
+		}
+		billMeta.ReintroductionOf = nil
+		billMeta.ReintroducedAs = nil
+		mi.billMeta[billNumber] = billMeta
+	}
+	sort.Strings(billNumbers)
+	found := 0
+	for _, billNumber := range billNumbers {
+		billMeta := mi.billMeta[billNumber]
+		var best *Reintroduction
+		var bestCongress int
+		for candidateNumber, titleSimilarity := range detector.candidates(billMeta, billsBySponsor) {
+			candidate, ok := mi.billMeta[candidateNumber]
+			if !ok {
+				continue
+			}
+			reintroduction := detector.score(billMeta, candidate, titleSimilarity)
+			if reintroduction.Score < detector.MinScore {
+				continue
+			}
+			// Prefer the higher score, then the later congress, then the lower bill number
+			candidateCongress, _ := billCongressStage(candidateNumber)
+			if best == nil || reintroduction.Score > best.Score ||
+				(reintroduction.Score == best.Score && (candidateCongress > bestCongress ||
+					(candidateCongress == bestCongress && candidateNumber < best.BillCongressTypeNumber))) {
+				best = &reintroduction
+				bestCongress = candidateCongress
+			}
+		}
+		if best == nil {
+			continue
+		}
+		found++
+		log.Debug().Msgf("%s is a reintroduction of %s (score %v)", billNumber, best.BillCongressTypeNumber, best.Score)
+		billMeta.ReintroductionOf = best
+		if billMeta.RelatedBillsByBillnumber == nil {
+			billMeta.RelatedBillsByBillnumber = make(RelatedBillMap)
+		}
+		mergeRelatedBill(billMeta.RelatedBillsByBillnumber, best.BillCongressTypeNumber, ReintroductionOfReason, ReasonProvenance{Score: best.Score, RunId: mi.RunId}, nil)
+		mi.billMeta[billNumber] = billMeta
+
+		predecessor := mi.billMeta[best.BillCongressTypeNumber]
+		reintroducedAs := *best
+		reintroducedAs.BillCongressTypeNumber = billNumber
+		reintroducedAs.BillId = BillNumberToBillId(billNumber)
+		predecessor.ReintroducedAs = append(predecessor.ReintroducedAs, reintroducedAs)
+		if predecessor.RelatedBillsByBillnumber == nil {
+			predecessor.RelatedBillsByBillnumber = make(RelatedBillMap)
+		}
+		mergeRelatedBill(predecessor.RelatedBillsByBillnumber, billNumber, ReintroducedAsReason, ReasonProvenance{Score: best.Score, RunId: mi.RunId}, nil)
+		mi.billMeta[best.BillCongressTypeNumber] = predecessor
+	}
+	log.Info().Msgf("Found %d reintroductions", found)
+}
+
+// Saves the metadata of the bills with a reintroduction to the store. billMeta.json is written by MakeBillsMeta,
+// before LoadReintroductions, so it is written again for these bills.
+func WriteReintroductionsToStore(metaIndex *MetaIndex, billStore BillStore) {
+	log.Info().Msg("***** Writing reintroductions to billMeta ******")
+	metaIndex.RangeBillMeta(func(billCongressTypeNumber string, billMeta BillMeta) bool {
+		if billMeta.ReintroductionOf == nil && len(billMeta.ReintroducedAs) == 0 {
+			return true
+		}
+		if err := billStore.PutBillMeta(billMeta); err != nil {
+			log.Error().Msgf("Error saving billMeta for %s: %s", billCongressTypeNumber, err)
+		}
+		return true
+	})
+}
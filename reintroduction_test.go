@@ -0,0 +1,81 @@
+package bills
+
+import (
+	"path"
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadReintroductions(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test finding the bills of the 116th Congress reintroduced from the 115th")
+	billStore, err := OpenBadgerBillStore(path.Join(t.TempDir(), "badger"))
+	assert.Nil(t, err)
+	defer billStore.Close()
+	metaIndex := NewMetaIndex()
+	metaIndex.MakeBillsMeta("samples", billStore, "115", "116", "117")
+	metaIndex.LoadTitles()
+	metaIndex.LoadMainTitles()
+	metaIndex.LoadReintroductions(NewReintroductionDetector(path.Join("samples", CongressDir, "data")))
+
+	// The Consumers First Act, by the same sponsor, with nearly the same text
+	billMeta, _ := metaIndex.GetBillMeta("116hr1500")
+	if assert.NotNil(t, billMeta.ReintroductionOf) {
+		reintroduction := *billMeta.ReintroductionOf
+		assert.Equal(t, "115hr6972", reintroduction.BillCongressTypeNumber)
+		assert.Equal(t, 1.0, reintroduction.TitleSimilarity)
+		assert.True(t, reintroduction.SameSponsor)
+		assert.True(t, reintroduction.TextCompared)
+		assert.Greater(t, reintroduction.TextSimilarity, 0.5)
+		assert.GreaterOrEqual(t, reintroduction.Score, 0.8)
+	}
+	assert.True(t, billMeta.RelatedBillsByBillnumber["115hr6972"].HasReason(ReintroductionOfReason))
+
+	billMeta, _ = metaIndex.GetBillMeta("115hr6972")
+	assert.Nil(t, billMeta.ReintroductionOf)
+	if assert.Equal(t, 1, len(billMeta.ReintroducedAs)) {
+		assert.Equal(t, "116hr1500", billMeta.ReintroducedAs[0].BillCongressTypeNumber)
+	}
+	assert.True(t, billMeta.RelatedBillsByBillnumber["116hr1500"].HasReason(ReintroducedAsReason))
+
+	WriteReintroductionsToStore(metaIndex, billStore)
+	storedBillMeta, err := billStore.GetBillMeta("116hr1500")
+	assert.Nil(t, err)
+	assert.Equal(t, "115hr6972", storedBillMeta.ReintroductionOf.BillCongressTypeNumber)
+
+	// No earlier bill with the same title or sponsor
+	billMeta, _ = metaIndex.GetBillMeta("117hr200")
+	assert.Nil(t, billMeta.ReintroductionOf)
+
+	// Without the texts, the same title and sponsor are enough
+	detector := NewReintroductionDetector("")
+	metaIndex.LoadReintroductions(detector)
+	billMeta, _ = metaIndex.GetBillMeta("116hr1500")
+	assert.Equal(t, Reintroduction{BillCongressTypeNumber: "115hr6972", BillId: "hr6972-115", Score: 1, TitleSimilarity: 1, SameSponsor: true}, *billMeta.ReintroductionOf)
+	billMeta, _ = metaIndex.GetBillMeta("115hr6972")
+	assert.Equal(t, 1, len(billMeta.ReintroducedAs))
+	detector.Congresses = 0
+	metaIndex.LoadReintroductions(detector)
+	billMeta, _ = metaIndex.GetBillMeta("116hr1500")
+	assert.Nil(t, billMeta.ReintroductionOf)
+}
+
+func TestReintroductionScore(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test scoring candidate predecessors")
+	detector := NewReintroductionDetector("")
+	billMeta := BillMeta{BillCongressTypeNumber: "117hr10", Sponsor: CosponsorItem{BioguideId: "W000187"}}
+	candidate := BillMeta{BillCongressTypeNumber: "116hr20", Sponsor: CosponsorItem{BioguideId: "A000370"}}
+	// The same title, by a different sponsor
+	assert.Equal(t, 0.667, detector.score(billMeta, candidate, 1).Score)
+	candidate.Sponsor.BioguideId = "W000187"
+	reintroduction := detector.score(billMeta, candidate, 0.9)
+	assert.Equal(t, 0.933, reintroduction.Score)
+	assert.Equal(t, "hr20-116", reintroduction.BillId)
+	billMeta.Sponsor.BioguideId = ""
+	candidate.Sponsor.BioguideId = ""
+	assert.False(t, detector.score(billMeta, candidate, 0.9).SameSponsor)
+}
@@ -0,0 +1,199 @@
+package bills
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// A set of reasons that two bills are related (e.g. bills-title_match), sorted by REASON_ORDER.
+// In JSON it is the ", "-joined string that readers of relatedDict.json expect (e.g. "bills-title_match, related");
+// a JSON array of reasons is also read.
+type ReasonSet []string
+
+// A set of the sources that identified a related bill (e.g. House, BillMap), in the order they were added.
+// In JSON it is a ", "-joined string, as for ReasonSet.
+type SourceSet []string
+
+// Where one reason for relating two bills came from
+type ReasonProvenance struct {
+	// The source that found the reason (e.g. BillMap)
+	IdentifiedBy string `json:"identified_by,omitempty"`
+	// The titles that matched, for the title reasons
+	Titles []string `json:"titles,omitempty"`
+	// The score of the match (e.g. the title similarity), for reasons that have one
+	Score float64 `json:"score,omitempty"`
+	// The run that found the reason (see Manifest)
+	RunId string `json:"run_id,omitempty"`
+}
+
+// Splits items joined by commas (e.g. "bills-title_match, related"), without empty items
+func splitSetItems(items []string) (split []string) {
+	for _, item := range items {
+		for _, part := range strings.Split(item, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				split = append(split, part)
+			}
+		}
+	}
+	return split
+}
+
+// Reads a set from a JSON string of items joined by commas, or a JSON array
+func unmarshalSet(data []byte) ([]string, error) {
+	var joined string
+	if err := json.Unmarshal(data, &joined); err == nil {
+		return splitSetItems([]string{joined}), nil
+	}
+	var items []string
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	return splitSetItems(items), nil
+}
+
+// Makes a set of the reasons. Each reason may also be several reasons joined by commas.
+func NewReasonSet(reasons ...string) ReasonSet {
+	return ReasonSet(nil).Add(reasons...)
+}
+
+// Gets the set with the reasons added. The set is not changed.
+func (set ReasonSet) Add(reasons ...string) ReasonSet {
+	// Reasons that are not in REASON_ORDER come after the known reasons, in the order they were added
+	return ReasonSet(SortReasons(RemoveDuplicates(append(append([]string{}, set...), splitSetItems(reasons)...))))
+}
+
+func (set ReasonSet) Union(other ReasonSet) ReasonSet {
+	return set.Add(other...)
+}
+
+func (set ReasonSet) Has(reason string) bool {
+	_, ok := Find(set, reason)
+	return ok
+}
+
+func (set ReasonSet) String() string {
+	return strings.Join(set, ", ")
+}
+
+func (set ReasonSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(set.String())
+}
+
+func (set *ReasonSet) UnmarshalJSON(data []byte) error {
+	items, err := unmarshalSet(data)
+	if err != nil {
+		return err
+	}
+	*set = NewReasonSet(items...)
+	return nil
+}
+
+// Makes a set of the sources. Each source may also be several sources joined by commas.
+func NewSourceSet(sources ...string) SourceSet {
+	return SourceSet(nil).Add(sources...)
+}
+
+// Gets the set with the sources added after the sources in the set. The set is not changed.
+func (set SourceSet) Add(sources ...string) SourceSet {
+	return SourceSet(RemoveDuplicates(append(append([]string{}, set...), splitSetItems(sources)...)))
+}
+
+func (set SourceSet) Union(other SourceSet) SourceSet {
+	return set.Add(other...)
+}
+
+func (set SourceSet) Has(source string) bool {
+	_, ok := Find(set, source)
+	return ok
+}
+
+func (set SourceSet) String() string {
+	return strings.Join(set, ", ")
+}
+
+func (set SourceSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(set.String())
+}
+
+func (set *SourceSet) UnmarshalJSON(data []byte) error {
+	items, err := unmarshalSet(data)
+	if err != nil {
+		return err
+	}
+	*set = NewSourceSet(items...)
+	return nil
+}
+
+// Merges the provenance of the same reason: the titles of both, the higher score, and the later run
+func (provenance ReasonProvenance) Merge(other ReasonProvenance) ReasonProvenance {
+	if provenance.IdentifiedBy == "" {
+		provenance.IdentifiedBy = other.IdentifiedBy
+	}
+	if len(other.Titles) > 0 {
+		provenance.Titles = RemoveDuplicates(append(append([]string{}, provenance.Titles...), other.Titles...))
+	}
+	if other.Score > provenance.Score {
+		provenance.Score = other.Score
+	}
+	// Run ids sort in the order of the runs
+	if other.RunId > provenance.RunId {
+		provenance.RunId = other.RunId
+	}
+	return provenance
+}
+
+func (relatedBillItem RelatedBillItem) HasReason(reason string) bool {
+	return relatedBillItem.Reason.Has(reason)
+}
+
+// Adds the reason to the item, with where it came from. The source (provenance.IdentifiedBy), if set,
+// is added to the item's IdentifiedBy.
+func (relatedBillItem *RelatedBillItem) AddReason(reason string, provenance ReasonProvenance) {
+	relatedBillItem.Reason = relatedBillItem.Reason.Add(reason)
+	if provenance.IdentifiedBy != "" {
+		relatedBillItem.IdentifiedBy = relatedBillItem.IdentifiedBy.Add(provenance.IdentifiedBy)
+	}
+	if relatedBillItem.Provenance == nil {
+		relatedBillItem.Provenance = make(map[string]ReasonProvenance)
+	}
+	relatedBillItem.Provenance[reason] = relatedBillItem.Provenance[reason].Merge(provenance)
+}
+
+// Merges two items for the same related bill (e.g. the bill related by several mechanisms): the union of
+// the reasons, sources and titles, the provenance of each reason, and the most similar titles
+func (relatedBillItem RelatedBillItem) Merge(other RelatedBillItem) RelatedBillItem {
+	merged := relatedBillItem
+	if merged.BillId == "" {
+		merged.BillId = other.BillId
+	}
+	if merged.BillCongressTypeNumber == "" {
+		merged.BillCongressTypeNumber = other.BillCongressTypeNumber
+	}
+	if merged.Type == "" {
+		merged.Type = other.Type
+	}
+	merged.Reason = merged.Reason.Union(other.Reason)
+	merged.IdentifiedBy = merged.IdentifiedBy.Union(other.IdentifiedBy)
+	if len(other.Titles) > 0 {
+		merged.Titles = RemoveDuplicates(append(append([]string{}, merged.Titles...), other.Titles...))
+	}
+	if len(other.TitlesWholeBill) > 0 {
+		merged.TitlesWholeBill = RemoveDuplicates(append(append([]string{}, merged.TitlesWholeBill...), other.TitlesWholeBill...))
+	}
+	if other.TitleSimilarity > merged.TitleSimilarity {
+		merged.TitleSimilarity = other.TitleSimilarity
+		merged.SimilarTitles = other.SimilarTitles
+	}
+	if len(other.Provenance) > 0 {
+		provenance := make(map[string]ReasonProvenance, len(merged.Provenance)+len(other.Provenance))
+		for reason, reasonProvenance := range merged.Provenance {
+			provenance[reason] = reasonProvenance
+		}
+		for reason, reasonProvenance := range other.Provenance {
+			provenance[reason] = provenance[reason].Merge(reasonProvenance)
+		}
+		merged.Provenance = provenance
+	}
+	normalizeRelatedBillIds(&merged)
+	return merged
+}
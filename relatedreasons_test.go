@@ -0,0 +1,79 @@
+package bills
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReasonSet(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test reason and source sets")
+	reasons := NewReasonSet("related", "bills-identical, related")
+	assert.Equal(t, ReasonSet{"bills-identical", "related"}, reasons)
+	added := reasons.Add(TitleMatchReason)
+	assert.Equal(t, "bills-identical, bills-title_match, related", added.String())
+	// Add does not change the set
+	assert.False(t, reasons.Has(TitleMatchReason))
+	assert.True(t, added.Has(TitleMatchReason))
+	assert.False(t, added.Has("bills-title"))
+	// The main title match comes after the title match, and reasons that are not in REASON_ORDER come last
+	assert.Equal(t, "bills-title_match, bills-title_match_main, related, bills-custom", NewReasonSet("bills-custom", "related", MainTitleMatchReason, TitleMatchReason).String())
+
+	sources := NewSourceSet("House").Add(IdentifiedByBillMap, "House")
+	assert.Equal(t, SourceSet{"House", IdentifiedByBillMap}, sources)
+	assert.Equal(t, SourceSet{"House", IdentifiedByBillMap, "Senate"}, sources.Union(NewSourceSet("Senate", "BillMap")))
+}
+
+func TestRelatedBillItemJSON(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test that related bill items keep the string form of reasons and sources in JSON")
+	// As in relatedDict.json, and in the related_bills of data.json
+	data := []byte(`{"bill_id": "hr6972-115", "identified_by": "House, BillMap", "reason": "related, bills-title_match", "type": "bill"}`)
+	var relatedBillItem RelatedBillItem
+	assert.Nil(t, json.Unmarshal(data, &relatedBillItem))
+	assert.Equal(t, ReasonSet{TitleMatchReason, "related"}, relatedBillItem.Reason)
+	assert.Equal(t, SourceSet{"House", IdentifiedByBillMap}, relatedBillItem.IdentifiedBy)
+
+	marshalled, err := json.Marshal(relatedBillItem)
+	assert.Nil(t, err)
+	var fields map[string]interface{}
+	assert.Nil(t, json.Unmarshal(marshalled, &fields))
+	assert.Equal(t, "bills-title_match, related", fields["reason"])
+	assert.Equal(t, "House, BillMap", fields["identified_by"])
+	assert.NotContains(t, fields, "provenance")
+
+	// Arrays are also read
+	assert.Nil(t, json.Unmarshal([]byte(`{"reason": ["related", "bills-identical"], "identified_by": ["BillMap"]}`), &relatedBillItem))
+	assert.Equal(t, ReasonSet{"bills-identical", "related"}, relatedBillItem.Reason)
+	assert.Equal(t, SourceSet{IdentifiedByBillMap}, relatedBillItem.IdentifiedBy)
+	assert.NotNil(t, json.Unmarshal([]byte(`{"reason": 1}`), &relatedBillItem))
+}
+
+func TestRelatedBillItemMerge(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test merging a bill related by several mechanisms")
+	fromDataJson := RelatedBillItem{BillId: "hr6972-115", Reason: NewReasonSet("related"), IdentifiedBy: NewSourceSet("House"), Type: "bill"}
+	byTitle := RelatedBillItem{BillCongressTypeNumber: "115hr6972", Titles: []string{"Consumers First Act"}}
+	byTitle.AddReason(TitleMatchReason, ReasonProvenance{IdentifiedBy: IdentifiedByBillMap, Titles: []string{"Consumers First Act"}, RunId: "run1"})
+	bySimilarTitle := RelatedBillItem{BillCongressTypeNumber: "115hr6972", SimilarTitles: []string{"Consumers First Act", "Consumer First Act"}, TitleSimilarity: 0.947}
+	bySimilarTitle.AddReason(TitleSimilarReason, ReasonProvenance{IdentifiedBy: IdentifiedByBillMap, Score: 0.947, RunId: "run2"})
+	bySimilarTitle.AddReason(TitleMatchReason, ReasonProvenance{IdentifiedBy: IdentifiedByBillMap, Titles: []string{"Consumers First Act of 2018"}, RunId: "run2"})
+
+	merged := fromDataJson.Merge(byTitle).Merge(bySimilarTitle)
+	assert.Equal(t, "hr6972-115", merged.BillId)
+	assert.Equal(t, "115hr6972", merged.BillCongressTypeNumber)
+	assert.Equal(t, "bill", merged.Type)
+	assert.Equal(t, "bills-title_match, bills-title_similar, related", merged.Reason.String())
+	assert.Equal(t, "House, BillMap", merged.IdentifiedBy.String())
+	assert.Equal(t, []string{"Consumers First Act"}, merged.Titles)
+	assert.Equal(t, 0.947, merged.TitleSimilarity)
+	assert.Equal(t, ReasonProvenance{IdentifiedBy: IdentifiedByBillMap, Titles: []string{"Consumers First Act", "Consumers First Act of 2018"}, RunId: "run2"}, merged.Provenance[TitleMatchReason])
+	assert.Equal(t, 0.947, merged.Provenance[TitleSimilarReason].Score)
+	// The merged items are not changed
+	assert.Equal(t, 1, len(byTitle.Provenance))
+	assert.Nil(t, fromDataJson.Provenance)
+}
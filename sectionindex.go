@@ -0,0 +1,451 @@
+package bills
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	SectionBackendES    = "es"
+	SectionBackendLocal = "local"
+)
+
+var (
+	// Where esquery finds similar sections
+	SectionBackends = []string{SectionBackendES, SectionBackendLocal}
+	// e.g. '1st Session' -> 1
+	sessionNumberRegexCompiled = regexp.MustCompile(`[0-9]+`)
+)
+
+// A section in the SectionIndex
+type indexedSection struct {
+	billVersion   int
+	sectionIndex  string
+	sectionNumber string
+	sectionHeader string
+	length        int
+}
+
+// A bill version in the SectionIndex. The sections are read again from docPath for LatestBillItem.
+type indexedBillVersion struct {
+	billItem BillItemES
+	title    string
+	docPath  string
+}
+
+type sectionPosting struct {
+	section int
+	freq    int
+}
+
+// A section scored for a query
+type sectionHit struct {
+	section int
+	score   float64
+}
+
+// An in-memory inverted index of the top-level sections of bills (from document.xml), with BM25 scoring.
+// It finds similar sections the way the more_like_this query of esquery does in Elasticsearch
+// (see SectionItemQuery), so that esquery can run without an Elasticsearch cluster (`-backend local`).
+// Set the options before querying; the index is safe for concurrent use.
+type SectionIndex struct {
+	mu            sync.RWMutex
+	sections      []indexedSection
+	billVersions  []indexedBillVersion
+	billVersionId map[string]int
+	// The indexes in billVersions of the versions of each bill number
+	billNumberVersionIds map[string][]int
+	postings             map[string][]sectionPosting
+	totalLength          int
+
+	// BM25 parameters (the Elasticsearch defaults)
+	K1 float64
+	B  float64
+	// Options of the more-like-this query: the terms of the section text that occur at least MinTermFreq times,
+	// in at least MinDocFreq indexed sections, are ranked by tf-idf and the top MaxQueryTerms are the query.
+	// A section matches if it has at least MinShouldMatch of the query terms.
+	MinTermFreq    int
+	MinDocFreq     int
+	MaxQueryTerms  int
+	MinShouldMatch float64
+	// Maximum number of bill versions in the results, and the minimum score of their best section
+	Size     int
+	MinScore float64
+}
+
+func NewSectionIndex() *SectionIndex {
+	return &SectionIndex{
+		billVersionId:        make(map[string]int),
+		billNumberVersionIds: make(map[string][]int),
+		postings:             make(map[string][]sectionPosting),
+		K1:                   1.2,
+		B:                    0.75,
+		MinTermFreq:          2,
+		MinDocFreq:           2,
+		MaxQueryTerms:        60,
+		MinShouldMatch:       0.3,
+		Size:                 num_results,
+		MinScore:             min_sim_score,
+	}
+}
+
+// Splits the text into lowercase words and numbers, as the standard analyzer of Elasticsearch does
+func sectionTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// The text of the section that is indexed and queried: its number, header and body
+func billSectionText(section BillSection) string {
+	return strings.TrimSpace(strings.Join(append([]string{section.SectionNumber, section.SectionHeader}, section.Words...), " "))
+}
+
+func findNodeText(doc *xmlquery.Node, expr string) string {
+	if node := xmlquery.FindOne(doc, expr); node != nil {
+		return strings.TrimSpace(node.InnerText())
+	}
+	return ""
+}
+
+// Reads the metadata and the sections of a bill version from its document.xml, in the form of the
+// Elasticsearch 'billsections' index
+func ReadBillItem(docPath string) (billItem BillItemES, err error) {
+	billNumberVersion := BillNumberFromPath(path.Dir(docPath))
+	matches := FindNamedMatches(BillnumberRegexCompiled, billNumberVersion)
+	if matches["version"] == "" {
+		return billItem, fmt.Errorf("no bill number version in the path: %s", docPath)
+	}
+	xmlFile, err := os.Open(docPath)
+	if err != nil {
+		return billItem, err
+	}
+	defer xmlFile.Close()
+	doc, err := xmlquery.Parse(xmlFile)
+	if err != nil {
+		return billItem, fmt.Errorf("error parsing %s: %w", docPath, err)
+	}
+	dcTitle := findNodeText(doc, "//dublinCore/dc:title")
+	billItem = BillItemES{
+		ID:          billNumberVersion,
+		BillNumber:  matches["congress"] + matches["stage"] + matches["billnumber"],
+		BillVersion: matches["version"],
+		Congress:    matches["congress"],
+		Session:     sessionNumberRegexCompiled.FindString(findNodeText(doc, "//form/session")),
+		Date:        findNodeText(doc, "//dublinCore/dc:date"),
+		DCTitle:     dcTitle,
+		Legisnum:    findNodeText(doc, "//form/legis-num"),
+	}
+	if dcTitle != "" {
+		billItem.DC = []string{"<dc:title>" + dcTitle + "</dc:title>"}
+	}
+	for _, section := range parseBillSections(doc) {
+		billItem.Headers = append(billItem.Headers, section.SectionHeader)
+		billItem.Sections = append(billItem.Sections, SectionItem{
+			BillNumber:        billItem.BillNumber,
+			BillNumberVersion: billNumberVersion,
+			SectionIndex:      strconv.Itoa(section.SectionIndex),
+			SectionNumber:     section.SectionNumber,
+			SectionHeader:     section.SectionHeader,
+			SectionText:       billSectionText(section),
+		})
+	}
+	return billItem, nil
+}
+
+// Adds the sections of the bill version to the index. The text of the sections is not kept in memory;
+// LatestBillItem reads it again from docPath.
+func (index *SectionIndex) AddBillItem(billItem BillItemES, docPath string) {
+	// Count the terms before taking the lock
+	sectionTermFreqs := make([]map[string]int, len(billItem.Sections))
+	sectionLengths := make([]int, len(billItem.Sections))
+	for i, sectionItem := range billItem.Sections {
+		sectionTermFreqs[i] = make(map[string]int)
+		for _, term := range sectionTerms(sectionItem.SectionText) {
+			sectionTermFreqs[i][term]++
+			sectionLengths[i]++
+		}
+	}
+	title := ""
+	if result := DcTitle_Regexp.FindStringSubmatch(strings.Join(billItem.DC, "")); len(result) > 1 {
+		title = strings.TrimSpace(result[1])
+	}
+	sections := billItem.Sections
+	billItem.Sections = nil
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	if _, ok := index.billVersionId[billItem.ID]; ok {
+		log.Debug().Msgf("%s is already in the section index", billItem.ID)
+		return
+	}
+	index.billVersionId[billItem.ID] = len(index.billVersions)
+	index.billNumberVersionIds[billItem.BillNumber] = append(index.billNumberVersionIds[billItem.BillNumber], len(index.billVersions))
+	index.billVersions = append(index.billVersions, indexedBillVersion{billItem: billItem, title: title, docPath: docPath})
+	for i, sectionItem := range sections {
+		sectionId := len(index.sections)
+		index.sections = append(index.sections, indexedSection{
+			billVersion:   len(index.billVersions) - 1,
+			sectionIndex:  sectionItem.SectionIndex,
+			sectionNumber: sectionItem.SectionNumber,
+			sectionHeader: sectionItem.SectionHeader,
+			length:        sectionLengths[i],
+		})
+		index.totalLength += sectionLengths[i]
+		for term, freq := range sectionTermFreqs[i] {
+			index.postings[term] = append(index.postings[term], sectionPosting{section: sectionId, freq: freq})
+		}
+	}
+}
+
+// Reads and indexes the bill version in the document.xml file
+func (index *SectionIndex) AddDocument(docPath string) error {
+	billItem, err := ReadBillItem(docPath)
+	if err != nil {
+		return err
+	}
+	index.AddBillItem(billItem, docPath)
+	return nil
+}
+
+// Builds the index of the sections of each document.xml in the directory (e.g. [parentPath]/congress/data/117).
+// Documents that cannot be read are logged and skipped. The documents are read concurrently, and added in the
+// sorted order of their paths, so the same data always gives the same index.
+func BuildSectionIndex(dataPath string) (*SectionIndex, error) {
+	documentXMLFiles, err := WalkDirFilter(dataPath, func(fpath string) bool {
+		return path.Base(fpath) == "document.xml"
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(documentXMLFiles)
+	type readResult struct {
+		billItem BillItemES
+		err      error
+	}
+	// At most maxconcurrent documents are read and not yet added
+	maxconcurrent := 20
+	sem := make(chan bool, maxconcurrent)
+	results := make([]chan readResult, len(documentXMLFiles))
+	for i := range results {
+		results[i] = make(chan readResult, 1)
+	}
+	go func() {
+		for i, docPath := range documentXMLFiles {
+			sem <- true
+			go func(docPath string, result chan<- readResult) {
+				billItem, err := ReadBillItem(docPath)
+				result <- readResult{billItem: billItem, err: err}
+			}(docPath, results[i])
+		}
+	}()
+	index := NewSectionIndex()
+	for i, docPath := range documentXMLFiles {
+		if i > 0 && i%1000 == 0 {
+			log.Info().Msgf("Indexed the sections of %d of %d documents", i, len(documentXMLFiles))
+		}
+		result := <-results[i]
+		<-sem
+		if result.err != nil {
+			log.Error().Msgf("Error indexing %s: %s", docPath, result.err)
+			continue
+		}
+		index.AddBillItem(result.billItem, docPath)
+	}
+	log.Info().Msgf("Indexed %d sections of %d bill versions", index.Len(), len(index.BillNumberVersions()))
+	return index, nil
+}
+
+// Number of sections in the index
+func (index *SectionIndex) Len() int {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	return len(index.sections)
+}
+
+// Gets the sorted bill number versions (e.g. 116hr1500ih) in the index
+func (index *SectionIndex) BillNumberVersions() []string {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	billNumberVersions := make([]string, 0, len(index.billVersionId))
+	for billNumberVersion := range index.billVersionId {
+		billNumberVersions = append(billNumberVersions, billNumberVersion)
+	}
+	sort.Strings(billNumberVersions)
+	return billNumberVersions
+}
+
+// Inverse document frequency of a term in n of the sections, as in the BM25 of Lucene. The caller holds the lock.
+func (index *SectionIndex) idf(n int) float64 {
+	return math.Log(1 + (float64(len(index.sections))-float64(n)+0.5)/(float64(n)+0.5))
+}
+
+// Selects the terms of the text for a more-like-this query: the terms that pass MinTermFreq and MinDocFreq,
+// by tf-idf, at most MaxQueryTerms. The caller holds the lock.
+func (index *SectionIndex) moreLikeThisTerms(text string) []string {
+	termFreqs := make(map[string]int)
+	for _, term := range sectionTerms(text) {
+		termFreqs[term]++
+	}
+	var terms []string
+	weights := make(map[string]float64)
+	for term, freq := range termFreqs {
+		docFreq := len(index.postings[term])
+		if freq < index.MinTermFreq || docFreq < index.MinDocFreq || docFreq == 0 {
+			continue
+		}
+		terms = append(terms, term)
+		weights[term] = float64(freq) * index.idf(docFreq)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if weights[terms[i]] != weights[terms[j]] {
+			return weights[terms[i]] > weights[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+	if index.MaxQueryTerms > 0 && len(terms) > index.MaxQueryTerms {
+		terms = terms[:index.MaxQueryTerms]
+	}
+	return terms
+}
+
+// Scores the sections that have the terms with BM25. The caller holds the lock.
+func (index *SectionIndex) scoreSections(terms []string) []sectionHit {
+	if len(index.sections) == 0 || len(terms) == 0 {
+		return nil
+	}
+	avgLength := float64(index.totalLength) / float64(len(index.sections))
+	scores := make(map[int]float64)
+	matchedTerms := make(map[int]int)
+	for _, term := range terms {
+		postings := index.postings[term]
+		idf := index.idf(len(postings))
+		for _, posting := range postings {
+			freq := float64(posting.freq)
+			norm := index.K1 * (1 - index.B + index.B*float64(index.sections[posting.section].length)/avgLength)
+			scores[posting.section] += idf * freq * (index.K1 + 1) / (freq + norm)
+			matchedTerms[posting.section]++
+		}
+	}
+	minMatched := int(index.MinShouldMatch * float64(len(terms)))
+	if minMatched < 1 {
+		minMatched = 1
+	}
+	var hits []sectionHit
+	for section, score := range scores {
+		if matchedTerms[section] >= minMatched {
+			hits = append(hits, sectionHit{section: section, score: score})
+		}
+	}
+	return hits
+}
+
+// Finds the sections most like the text: the best section of each bill version, for at most Size bill versions
+// whose best section scores at least MinScore, highest score first. The caller holds the lock.
+func (index *SectionIndex) moreLikeThis(text string) []sectionHit {
+	bestHits := make(map[int]sectionHit)
+	for _, hit := range index.scoreSections(index.moreLikeThisTerms(text)) {
+		billVersion := index.sections[hit.section].billVersion
+		if best, ok := bestHits[billVersion]; !ok || hit.score > best.score || (hit.score == best.score && hit.section < best.section) {
+			bestHits[billVersion] = hit
+		}
+	}
+	var hits []sectionHit
+	for _, hit := range bestHits {
+		if hit.score >= index.MinScore {
+			hits = append(hits, hit)
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].section < hits[j].section
+	})
+	if index.Size > 0 && len(hits) > index.Size {
+		hits = hits[:index.Size]
+	}
+	return hits
+}
+
+// Gets the sections of the indexed bills that are similar to the section, in the same form as SectionItemQuery
+func (index *SectionIndex) SectionItemQuery(sectionItem SectionItem) (similarSectionsItem SimilarSectionsItem) {
+	log.Debug().Msgf("Get similar sections for: '%s'", sectionItem.SectionHeader)
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	var similarSections SimilarSections
+	var similarBills, similarBillNumberVersions []string
+	for _, hit := range index.moreLikeThis(sectionItem.SectionText) {
+		section := index.sections[hit.section]
+		billVersion := index.billVersions[section.billVersion]
+		billItem := billVersion.billItem
+		similarSections = append(similarSections, SimilarSection{
+			Billnumber:                    billItem.BillNumber,
+			BillCongressTypeNumberVersion: billItem.ID,
+			Congress:                      billItem.Congress,
+			Session:                       billItem.Session,
+			Legisnum:                      billItem.Legisnum,
+			Score:                         float32(hit.score),
+			SectionIndex:                  section.sectionIndex,
+			SectionNum:                    section.sectionNumber + " ",
+			SectionHeader:                 section.sectionHeader,
+			TargetSectionHeader:           sectionItem.SectionHeader,
+			TargetSectionNumber:           sectionItem.SectionNumber + " ",
+			TargetSectionIndex:            sectionItem.SectionIndex,
+			Date:                          billItem.Date,
+			Title:                         billVersion.title,
+		})
+		similarBills = append(similarBills, billItem.BillNumber)
+		similarBillNumberVersions = append(similarBillNumberVersions, billItem.ID)
+	}
+	log.Debug().Msgf("number of similarSections: %v\n", len(similarSections))
+	return SimilarSectionsItem{
+		BillNumber:                sectionItem.BillNumber,
+		BillNumberVersion:         sectionItem.BillNumberVersion,
+		SectionHeader:             sectionItem.SectionHeader,
+		SectionNum:                sectionItem.SectionNumber,
+		SectionIndex:              sectionItem.SectionIndex,
+		SimilarSections:           similarSections,
+		SimilarBills:              RemoveDuplicates(similarBills),
+		SimilarBillNumberVersions: RemoveDuplicates(similarBillNumberVersions),
+	}
+}
+
+// Gets the latest indexed version of the bill (e.g. 116hr1500), with its sections, as GetLatestBill does for
+// the Elasticsearch results: the last enrolled or engrossed version if there is one, otherwise the version with the latest date
+func (index *SectionIndex) LatestBillItem(billNumber string) (billItem BillItemES, err error) {
+	index.mu.RLock()
+	var latest *indexedBillVersion
+	isLater := func(billVersion, other *indexedBillVersion) bool {
+		version, otherVersion := billVersion.billItem.BillVersion, other.billItem.BillVersion
+		if strings.HasPrefix(version, "e") != strings.HasPrefix(otherVersion, "e") {
+			return strings.HasPrefix(version, "e")
+		}
+		if strings.HasPrefix(version, "e") || billVersion.billItem.Date == other.billItem.Date {
+			return BillVersionsOrdered[version] > BillVersionsOrdered[otherVersion]
+		}
+		return billVersion.billItem.Date > other.billItem.Date
+	}
+	for _, id := range index.billNumberVersionIds[billNumber] {
+		billVersion := &index.billVersions[id]
+		if latest == nil || isLater(billVersion, latest) {
+			latest = billVersion
+		}
+	}
+	index.mu.RUnlock()
+	if latest == nil {
+		return billItem, fmt.Errorf("%s is not in the section index", billNumber)
+	}
+	return ReadBillItem(latest.docPath)
+}
@@ -0,0 +1,81 @@
+package bills
+
+import (
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadBillItem(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test reading the sections of a bill for the section index")
+	billItem, err := ReadBillItem(sampleFilePathIH)
+	assert.Nil(t, err)
+	assert.Equal(t, "116hr1500ih", billItem.ID)
+	assert.Equal(t, "116hr1500", billItem.BillNumber)
+	assert.Equal(t, "ih", billItem.BillVersion)
+	assert.Equal(t, "1", billItem.Session)
+	assert.Equal(t, "2019-03-05", billItem.Date)
+	assert.Equal(t, "H. R. 1500", billItem.Legisnum)
+	assert.Equal(t, "116 HR 1500 IH: Consumers First Act", billItem.DCTitle)
+	assert.Equal(t, 8, len(billItem.Sections))
+	assert.Equal(t, "2", billItem.Sections[2].SectionIndex)
+	assert.Equal(t, "3.", billItem.Sections[2].SectionNumber)
+	assert.Equal(t, "Consumer Financial Protection Bureau", billItem.Sections[2].SectionHeader)
+	assert.Contains(t, billItem.Sections[2].SectionText, "3. Consumer Financial Protection Bureau ")
+
+	_, err = ReadBillItem("samples/congress/data/116/bills/hr/hr1500/document.xml")
+	assert.NotNil(t, err)
+}
+
+func TestSectionIndex(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test finding similar sections in the section index")
+	index, err := BuildSectionIndex("samples/congress/data")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"115hr6972ih", "116hr1500eh", "116hr1500ih", "116hr1500rfs", "116hr1500rh", "117hr100ih"}, index.BillNumberVersions())
+	// Sections are numbered in the order of the document paths
+	rebuiltIndex, err := BuildSectionIndex("samples/congress/data")
+	assert.Nil(t, err)
+	assert.Equal(t, index.sections, rebuiltIndex.sections)
+	assert.Equal(t, index.postings, rebuiltIndex.postings)
+	assert.Equal(t, "115hr6972ih", index.billVersions[index.sections[0].billVersion].billItem.ID)
+	// Documents already in the index are not added again
+	sectionCount := index.Len()
+	assert.Nil(t, index.AddDocument(sampleFilePathIH))
+	assert.Equal(t, sectionCount, index.Len())
+
+	billItem, err := index.LatestBillItem("116hr1500")
+	assert.Nil(t, err)
+	// The engrossed version is the latest
+	assert.Equal(t, "116hr1500eh", billItem.ID)
+	_, err = index.LatestBillItem("116hr9999")
+	assert.NotNil(t, err)
+
+	similarSectionsItem := index.SectionItemQuery(billItem.Sections[2])
+	assert.Equal(t, "116hr1500eh", similarSectionsItem.BillNumberVersion)
+	assert.Equal(t, "Consumer Financial Protection Bureau", similarSectionsItem.SectionHeader)
+	assert.Equal(t, []string{"116hr1500", "115hr6972"}, similarSectionsItem.SimilarBills)
+	assert.Equal(t, 5, len(similarSectionsItem.SimilarSections))
+	assert.ElementsMatch(t, []string{"116hr1500eh", "116hr1500ih", "116hr1500rfs", "116hr1500rh", "115hr6972ih"}, similarSectionsItem.SimilarBillNumberVersions)
+	topSection := similarSectionsItem.SimilarSections[0]
+	assert.Equal(t, "Consumer Financial Protection Bureau", topSection.SectionHeader)
+	assert.Equal(t, "3. ", topSection.SectionNum)
+	assert.Equal(t, "2", topSection.TargetSectionIndex)
+	assert.Contains(t, topSection.Title, "Consumers First Act")
+	for i := 1; i < len(similarSectionsItem.SimilarSections); i++ {
+		assert.GreaterOrEqual(t, similarSectionsItem.SimilarSections[i-1].Score, similarSectionsItem.SimilarSections[i].Score)
+	}
+
+	// A lower minimum score finds the weaker match in 117hr100
+	index.MinScore = 0
+	assert.Contains(t, index.SectionItemQuery(billItem.Sections[2]).SimilarBills, "117hr100")
+	index.Size = 1
+	assert.Equal(t, 1, len(index.SectionItemQuery(billItem.Sections[2]).SimilarSections))
+
+	similarSectionsItems := GetSimilaritySections(index, billItem, 2)
+	assert.Equal(t, 2, len(similarSectionsItems))
+	assert.Equal(t, "1", similarSectionsItems[1].SectionIndex)
+}
@@ -1,12 +1,16 @@
 package bills
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"path"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -36,9 +40,17 @@ var (
 	minimumTotal             = 150
 )
 
+// Output formats for WriteCompareMatrix
+const (
+	CompareFormatJSON      = "json"
+	CompareFormatCSV       = "csv"
+	CompareFormatTable     = "table"
+	CompareFormatDelimited = "delimited"
+)
+
 type docMap struct {
-	nGramMap map[string]int
-	keys     []string
+	nGramMap map[uint64]int
+	keys     []uint64
 }
 
 type docMaps map[string]*docMap
@@ -47,7 +59,21 @@ type CompareItem struct {
 	Score        float64
 	ScoreOther   float64 // Score of the other bill
 	Explanation  string
-	ComparedDocs string
+	ComparedDocs string // SourceBill-TargetBill; kept for existing readers of esSimilarCategory.json
+	SourceBill   string // bill number version of the row of the compare matrix
+	TargetBill   string // bill number version of the column of the compare matrix
+}
+
+// Creates the CompareItem for a comparison of sourceBill to targetBill
+func newCompareItem(score, scoreOther float64, explanation, sourceBill, targetBill string) CompareItem {
+	return CompareItem{
+		Score:        score,
+		ScoreOther:   scoreOther,
+		Explanation:  explanation,
+		ComparedDocs: sourceBill + "-" + targetBill,
+		SourceBill:   sourceBill,
+		TargetBill:   targetBill,
+	}
 }
 
 func getExplanation(scorei, scorej float64, iTotal, jTotal int) string {
@@ -78,28 +104,68 @@ func getExplanation(scorei, scorej float64, iTotal, jTotal int) string {
 
 // Creates ngrams for files in the list of docPaths
 // Returns a map with key = docPath and value = docMap
-// Each docMap consists of a map of nGrams to the number of occurences, and a list of the nGrams
+// Each docMap consists of a map of hashed nGrams to the number of occurences, and a list of the hashed nGrams
+// The nGrams are read from the fingerprint cache next to each document, when it is fresh (see GetNgramFingerprint)
+// Boilerplate (BoilerplateElements and BoilerplateNgrams) is not included in the nGrams
 func makeBillNgrams(docPaths []string) (nGramMaps docMaps, err error) {
 	nGramMaps = make(docMaps)
 	for i, docpath := range docPaths {
 		log.Debug().Msgf("Getting Ngrams for file: %d\n", i)
-		file, err := os.ReadFile(docpath)
+		docMapItem, err := makeDocMap(docpath)
 		if err != nil {
 			log.Error().Msgf("Error reading document: %s\n", err)
 			return nil, err
-		} else {
-			fileText := removeXMLRegexCompiled.ReplaceAllString(string(file), " ")
-			var docMapItem *docMap = new(docMap)
-			docMapItem.nGramMap = MakeNgramMap(fileText, 4)
-			docMapItem.keys = MapNgramKeys(docMapItem.nGramMap)
-
-			nGramMaps[docpath] = docMapItem
 		}
+		nGramMaps[docpath] = docMapItem
 	}
 	return nGramMaps, nil
 
 }
 
+// Creates the docMap for one document
+func makeDocMap(docpath string) (*docMap, error) {
+	fingerprint, err := GetNgramFingerprint(docpath, NgramSize)
+	if err != nil {
+		return nil, err
+	}
+	var docMapItem *docMap = new(docMap)
+	docMapItem.nGramMap = FilterBoilerplateShingles(fingerprint.Shingles)
+	docMapItem.keys = shingleKeys(docMapItem.nGramMap)
+	return docMapItem, nil
+}
+
+// Returns the keys of a map of hashed nGrams
+func shingleKeys(shingles map[uint64]int) (keys []uint64) {
+	keys = make([]uint64, 0, len(shingles))
+	for k := range shingles {
+		keys = append(keys, k)
+	}
+	return
+}
+
+// Compares the n-grams of two documents. scorei is the share of the n-grams of docMap2 (by count) that are in docMap1,
+// and scorej the share of the n-grams of docMap1 that are in docMap2; iTotal and jTotal are the n-gram counts of each
+// Documents with no nGrams (e.g. only boilerplate) have a score of 0
+func compareDocMaps(docMap1, docMap2 *docMap) (scorei, scorej float64, iTotal, jTotal int) {
+	iScore := 0
+	for _, key := range docMap2.keys {
+		iScore += docMap1.nGramMap[key]
+		jTotal += docMap2.nGramMap[key]
+	}
+	jScore := 0
+	for _, key := range docMap1.keys {
+		jScore += docMap2.nGramMap[key]
+		iTotal += docMap1.nGramMap[key]
+	}
+	if jTotal > 0 {
+		scorei = math.Round(100*float64(iScore)/float64(jTotal)) / 100
+	}
+	if iTotal > 0 {
+		scorej = math.Round(100*float64(jScore)/float64(iTotal)) / 100
+	}
+	return scorei, scorej, iTotal, jTotal
+}
+
 // Compares all of the documents in a docMaps object, returns a matrix of the comparison values
 func compareFiles(nGramMaps docMaps, docPaths []string) (compareMatrix [][]CompareItem, err error) {
 	log.Info().Msg("Comparing files")
@@ -110,43 +176,7 @@ func compareFiles(nGramMaps docMaps, docPaths []string) (compareMatrix [][]Compa
 		for j := 0; j < (i + 1); j++ {
 			docpath2 := docPaths[j]
 
-			iTotal := 0
-			jTotal := 0
-
-			iKeys := nGramMaps[docpath1].keys
-			//log.Info().Msg(docpath1)
-			//log.Info().Msg(iKeys)
-			jKeys := nGramMaps[docpath2].keys
-			iScore := 0
-			for _, key := range jKeys {
-				iValue := nGramMaps[docpath1].nGramMap[key]
-				//log.Info().Msg(iValue)
-				//jValuea := nGramMaps[docpath2].nGramMap[key]
-				//log.Info().Msg(jValuea)
-				iScore += iValue
-				jTotal += nGramMaps[docpath2].nGramMap[key]
-			}
-			jScore := 0
-			for _, key := range iKeys {
-				jValue := nGramMaps[docpath2].nGramMap[key]
-				//log.Info().Msg(iValue)
-				//jValuea := nGramMaps[docpath2].nGramMap[key]
-				//log.Info().Msg(jValuea)
-				jScore += jValue
-				iTotal += nGramMaps[docpath1].nGramMap[key]
-			}
-
-			//if docpath1 != docpath2 {
-			//log.Info().Msgf("Keys:\n%v", nGramMaps[docpath1].keys)
-			//log.Info().Msgf("Keys 2:\n%v", nGramMaps[docpath2].keys)
-			//log.Info().Msgf("scoreTotal: %d\n", scoreTotal)
-			//log.Info().Msgf("scoreTotal: %d\n", scoreTotal)
-			//log.Info().Msgf("%s vs. %s", docpath1, docpath2)
-			//log.Info().Msgf("iTotal: %d\n", iTotal)
-			//log.Info().Msgf("jTotal: %d\n", jTotal)
-			//}
-			scorei := math.Round(100*float64(iScore)/float64(jTotal)) / 100
-			scorej := math.Round(100*float64(jScore)/float64(iTotal)) / 100
+			scorei, scorej, iTotal, jTotal := compareDocMaps(nGramMaps[docpath1], nGramMaps[docpath2])
 			exi := getExplanation(scorei, scorej, iTotal, jTotal)
 			exj := getExplanation(scorej, scorei, iTotal, jTotal)
 			//log.Info().Msgf("i,j docpath1/docpath2 scorei scorej: %d,%d %d/%d %f %f\n", i, j, iTotal, jTotal, scorei, scorej)
@@ -154,8 +184,8 @@ func compareFiles(nGramMaps docMaps, docPaths []string) (compareMatrix [][]Compa
 			//	log.Info().Msgf("i,j docpath1/docpath2 scorei scorej: %d,%d %d/%d %f %f\n", i, j, iTotal, jTotal, scorei, scorej)
 			//}
 
-			compareMatrix[i][j] = CompareItem{scorei, scorej, exi, BillNumberFromPath(docpath1) + "-" + BillNumberFromPath(docpath2)}
-			compareMatrix[j][i] = CompareItem{scorej, scorei, exj, BillNumberFromPath(docpath2) + "-" + BillNumberFromPath(docpath1)}
+			compareMatrix[i][j] = newCompareItem(scorei, scorej, exi, BillNumberFromPath(docpath1), BillNumberFromPath(docpath2))
+			compareMatrix[j][i] = newCompareItem(scorej, scorei, exj, BillNumberFromPath(docpath2), BillNumberFromPath(docpath1))
 		}
 	}
 
@@ -210,25 +240,26 @@ func CompareBillsfromPaths(docPaths []string, print bool) ([][]CompareItem, erro
 	if len(docPaths) == 0 {
 		log.Info().Msg("No documents to compare")
 		if print {
-			fmt.Print(":compareMatrix:", "", ":compareMatrix:")
+			WriteCompareMatrix(os.Stdout, nil, CompareFormatDelimited)
 		}
 		return nil, nil
 	}
 	compareMatrix, _ := compareFiles(nGramMaps, docPaths)
-	compareMatrixJson, _ := json.Marshal(compareMatrix)
 	if print {
-		fmt.Print(":compareMatrix:", string(compareMatrixJson), ":compareMatrix:")
+		WriteCompareMatrix(os.Stdout, compareMatrix, CompareFormatDelimited)
 	}
 	return compareMatrix, nil
 }
 
 // To call from Python
-// import subprocess
-// result = subprocess.run(['./compare', '-p', '../../../congress/data', '-b', '116hr1500rh,115hr6972ih'],  capture_output=True, text=True)
+// import subprocess, json
+// result = subprocess.run(['./comparematrix', '-p', '../../../congress/data', '-b', '116hr1500rh,115hr6972ih'],  capture_output=True, text=True)
 // OR
-// result = subprocess.run(['./compare', '-abspaths', '../../../congress/data/.../BILLS-116hr15000rh,../....'],  capture_output=True, text=True)
-// result.stdout.split('compareMatrix:\n')[-1]
-// Out[4]: '[[{1 identical} {0.63 incorporates}] [{0.79 incorporated by} {1 identical}]]'
+// result = subprocess.run(['./comparematrix', '-abspaths', '../../../congress/data/.../BILLS-116hr15000rh,../....'],  capture_output=True, text=True)
+// json.loads(result.stdout.split(':compareMatrix:')[1])
+// (or pass '-format', 'json' and use json.loads(result.stdout))
+// Out[4]: [[{'Score': 1, 'ScoreOther': 1, 'Explanation': 'bills-identical', 'SourceBill': '116hr1500rh', 'TargetBill': '116hr1500rh', ...}, ...], ...]
+// To avoid starting a process (and re-reading the bills) for each comparison, run the `compared` service (see CompareServer)
 
 func CompareBills(parentPath string, billList []string, print bool) ([][]CompareItem, error) {
 
@@ -249,10 +280,93 @@ func GetCompareMap(compareRow []CompareItem) (compareMap map[string]CompareItem)
 	compareMap = make(map[string]CompareItem)
 	log.Debug().Msgf("compareRow: %v", compareRow)
 	for _, row := range compareRow {
-		comparedocs := strings.Split(row.ComparedDocs, "-")
-		if len(comparedocs) == 2 {
-			compareMap[comparedocs[1]] = row
+		if row.TargetBill != "" {
+			compareMap[row.TargetBill] = row
 		}
 	}
 	return compareMap
 }
+
+// Gets the highest scoring version of each bill compared to billNumber in similarCategory (the content of esSimilarCategory.json,
+// keyed by bill number version), as a map of bill number to bill number version. Versions of billNumber itself are left out.
+// Of versions with the same score, the first in alphabetical order is kept.
+func BestCompareVersions(billNumber string, similarCategory map[string]CompareItem) (bestVersions map[string]string) {
+	bestVersions = make(map[string]string)
+	for billNumberVersion, compareItem := range similarCategory {
+		otherBillNumber := BillnumberRegexCompiled.ReplaceAllString(billNumberVersion, "$1$2$3")
+		if otherBillNumber == billNumber {
+			continue
+		}
+		bestVersion, ok := bestVersions[otherBillNumber]
+		if !ok || compareItem.Score > similarCategory[bestVersion].Score ||
+			(compareItem.Score == similarCategory[bestVersion].Score && billNumberVersion < bestVersion) {
+			bestVersions[otherBillNumber] = billNumberVersion
+		}
+	}
+	return bestVersions
+}
+
+// Writes the compare matrix to w, in one of the formats:
+// CompareFormatJSON: the matrix as a JSON array of rows
+// CompareFormatCSV: one line for each cell, with the source and target bill, scores and explanation
+// CompareFormatTable: a human-readable table with a row and column for each bill
+// CompareFormatDelimited: the JSON matrix between ':compareMatrix:' delimiters (the original output of comparematrix)
+func WriteCompareMatrix(w io.Writer, compareMatrix [][]CompareItem, format string) error {
+	switch format {
+	case CompareFormatJSON, CompareFormatDelimited:
+		if compareMatrix == nil {
+			compareMatrix = [][]CompareItem{}
+		}
+		compareMatrixJson, err := json.Marshal(compareMatrix)
+		if err != nil {
+			return err
+		}
+		if format == CompareFormatDelimited {
+			if len(compareMatrix) == 0 {
+				compareMatrixJson = nil
+			}
+			_, err = fmt.Fprint(w, ":compareMatrix:", string(compareMatrixJson), ":compareMatrix:")
+		} else {
+			_, err = fmt.Fprintln(w, string(compareMatrixJson))
+		}
+		return err
+	case CompareFormatCSV:
+		csvWriter := csv.NewWriter(w)
+		csvWriter.Write([]string{"source_bill", "target_bill", "score", "score_other", "explanation"})
+		for _, row := range compareMatrix {
+			for _, item := range row {
+				csvWriter.Write([]string{
+					item.SourceBill,
+					item.TargetBill,
+					strconv.FormatFloat(item.Score, 'f', -1, 64),
+					strconv.FormatFloat(item.ScoreOther, 'f', -1, 64),
+					item.Explanation,
+				})
+			}
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	case CompareFormatTable:
+		tabWriter := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		if len(compareMatrix) > 0 {
+			header := []string{""}
+			for _, item := range compareMatrix[0] {
+				header = append(header, item.TargetBill)
+			}
+			fmt.Fprintln(tabWriter, strings.Join(header, "\t"))
+		}
+		for _, row := range compareMatrix {
+			if len(row) == 0 {
+				continue
+			}
+			cells := []string{row[0].SourceBill}
+			for _, item := range row {
+				cells = append(cells, fmt.Sprintf("%.2f %s", item.Score, strings.TrimPrefix(item.Explanation, "bills-")))
+			}
+			fmt.Fprintln(tabWriter, strings.Join(cells, "\t"))
+		}
+		return tabWriter.Flush()
+	default:
+		return fmt.Errorf("unknown compare matrix format: %s", format)
+	}
+}
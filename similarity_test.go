@@ -0,0 +1,79 @@
+package bills
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var compareSamplePaths = []string{sampleFilePathIH, sampleFilePath}
+
+func getSampleCompareMatrix(t *testing.T) [][]CompareItem {
+	UseNgramCache = false
+	defer func() { UseNgramCache = true }()
+	compareMatrix, err := CompareBillsfromPaths(compareSamplePaths, false)
+	if err != nil {
+		t.Fatalf("Error comparing bills: %v", err)
+	}
+	return compareMatrix
+}
+
+func TestCompareBillsfromPaths(t *testing.T) {
+	log.Info().Msg("Test comparing bills and getting the compare map")
+	testutils.SetLogLevel()
+	compareMatrix := getSampleCompareMatrix(t)
+	assert.Equal(t, 2, len(compareMatrix))
+	assert.Equal(t, "bills-identical", compareMatrix[0][0].Explanation)
+	assert.Equal(t, "116hr1500ih", compareMatrix[0][1].SourceBill)
+	assert.Equal(t, "116hr1500eh", compareMatrix[0][1].TargetBill)
+	assert.Equal(t, "116hr1500ih-116hr1500eh", compareMatrix[0][1].ComparedDocs)
+	compareMap := GetCompareMap(compareMatrix[0])
+	assert.Equal(t, compareMatrix[0][1], compareMap["116hr1500eh"])
+}
+
+func TestWriteCompareMatrix(t *testing.T) {
+	log.Info().Msg("Test writing the compare matrix in each format")
+	testutils.SetLogLevel()
+	compareMatrix := getSampleCompareMatrix(t)
+
+	var jsonOut bytes.Buffer
+	assert.Nil(t, WriteCompareMatrix(&jsonOut, compareMatrix, CompareFormatJSON))
+	var decoded [][]CompareItem
+	assert.Nil(t, json.Unmarshal(jsonOut.Bytes(), &decoded))
+	assert.Equal(t, compareMatrix, decoded)
+
+	var csvOut bytes.Buffer
+	assert.Nil(t, WriteCompareMatrix(&csvOut, compareMatrix, CompareFormatCSV))
+	csvLines := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+	assert.Equal(t, 5, len(csvLines))
+	assert.True(t, strings.HasPrefix(csvLines[2], "116hr1500ih,116hr1500eh,"))
+
+	var tableOut bytes.Buffer
+	assert.Nil(t, WriteCompareMatrix(&tableOut, compareMatrix, CompareFormatTable))
+	assert.Contains(t, tableOut.String(), "1.00 identical")
+
+	var delimitedOut bytes.Buffer
+	assert.Nil(t, WriteCompareMatrix(&delimitedOut, compareMatrix, CompareFormatDelimited))
+	assert.True(t, strings.HasPrefix(delimitedOut.String(), ":compareMatrix:[["))
+
+	assert.NotNil(t, WriteCompareMatrix(&bytes.Buffer{}, compareMatrix, "xml"))
+}
+
+func TestBestCompareVersions(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test keeping the highest scoring version of each compared bill")
+	similarCategory := map[string]CompareItem{
+		"116hr1500ih": {Score: 1},
+		"115hr6972ih": {Score: 0.5},
+		"115hr6972rh": {Score: 0.8},
+		"116s100is":   {Score: 0.3},
+		"116s100rs":   {Score: 0.3},
+	}
+	assert.Equal(t, map[string]string{"115hr6972": "115hr6972rh", "116s100": "116s100is"}, BestCompareVersions("116hr1500", similarCategory))
+	assert.Equal(t, map[string]string{}, BestCompareVersions("116hr1500", nil))
+}
@@ -71,12 +71,21 @@ func MapNgramKeys(nGramMap map[string]int) (keys []string) {
 	return
 }
 
-var REASON_ORDER = map[string]int{"bills-identical": 1, "bills-nearly_identical": 2, "bills-title_match": 3, "bills-includes": 4, "bills-included_by": 5, "related": 6, "bills-some_similarity": 7, "bills-unrelated": 8}
+var REASON_ORDER = map[string]int{"bills-identical": 1, "bills-nearly_identical": 2, "bills-title_match": 3, "bills-title_match_main": 4, "bills-title_similar": 5, "bills-includes": 6, "bills-included_by": 7, "bills-reintroduction_of": 8, "bills-reintroduced_as": 9, "related": 10, "bills-some_similarity": 11, "bills-unrelated": 12}
 
+// Gets the position of the reason in REASON_ORDER; reasons that are not in REASON_ORDER come last
+func reasonOrder(reason string) int {
+	if order, ok := REASON_ORDER[reason]; ok {
+		return order
+	}
+	return len(REASON_ORDER) + 1
+}
+
+// Sorts the reasons by REASON_ORDER. The sort is stable, so reasons that are not in REASON_ORDER keep their order.
 func SortReasons(reasons []string) []string {
 
-	sort.Slice(reasons, func(i, j int) bool {
-		return REASON_ORDER[reasons[i]] < REASON_ORDER[reasons[j]]
+	sort.SliceStable(reasons, func(i, j int) bool {
+		return reasonOrder(reasons[i]) < reasonOrder(reasons[j])
 	})
 	return reasons
 }
@@ -0,0 +1,325 @@
+package bills
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dgraph-io/badger"
+	"github.com/rs/zerolog/log"
+)
+
+// Key prefixes for the title indexes in Badger. badgerhold keys start with 'bh_', so these do not collide.
+// titleKeyPrefix + indexName + "/" + normalized title + "\x00" + billNumber + "\x00" + the title, as it was indexed
+// billTitlesKeyPrefix + indexName + "/" + billNumber -> JSON list of the bill's titles in the index
+// titleFormatKeyPrefix + indexName -> the titleIndexFormat the title keys were made with
+const (
+	titleKeyPrefix       = "ti/"
+	billTitlesKeyPrefix  = "tb/"
+	titleFormatKeyPrefix = "tv/"
+	titleKeySeparator    = "\x00"
+	// Increment when NormalizeTitle changes in a way its steps do not show, so that the title keys are rebuilt
+	titleIndexVersion = 2
+)
+
+// Identifies the normalization of the title keys. The keys of an index made with another format
+// cannot be found with NormalizeTitle, and are rebuilt (see BadgerTitleIndex.Rebuild).
+func titleIndexFormat() string {
+	return fmt.Sprintf("%d:%s", titleIndexVersion, strings.Join(DefaultTitleNormalizer.Steps(), ","))
+}
+
+// Normalizes a title for the title index with the DefaultTitleNormalizer: removes the year at the end (e.g. '... of 2019'),
+// punctuation and a leading article, collapses whitespace and lower-cases it, so that titles that differ only in these respects are matched
+func NormalizeTitle(title string) string {
+	return DefaultTitleNormalizer.Normalize(title)
+}
+
+// A title index (e.g. titleNoYearIndexGo.json) stored in Badger. Each (title, bill) pair is a key,
+// so titles can be looked up by prefix and a bill's titles can be updated without rewriting the index.
+type BadgerTitleIndex struct {
+	db   *badger.DB
+	Name string
+}
+
+// Gets the title index with the name (e.g. TitleNoYearIndex) in the Badger database
+func NewBadgerTitleIndex(db *badger.DB, indexName string) *BadgerTitleIndex {
+	return &BadgerTitleIndex{db: db, Name: indexName}
+}
+
+func (ti *BadgerTitleIndex) titlePrefix(normalizedTitle string) []byte {
+	return []byte(titleKeyPrefix + ti.Name + "/" + normalizedTitle)
+}
+
+func (ti *BadgerTitleIndex) titleKey(title string, billNumber string) []byte {
+	return ti.titlePrefix(NormalizeTitle(title) + titleKeySeparator + billNumber + titleKeySeparator + title)
+}
+
+func (ti *BadgerTitleIndex) billTitlesKey(billNumber string) []byte {
+	return []byte(billTitlesKeyPrefix + ti.Name + "/" + billNumber)
+}
+
+func (ti *BadgerTitleIndex) formatKey() []byte {
+	return []byte(titleFormatKeyPrefix + ti.Name)
+}
+
+// Splits a title key into the normalized title, the bill number and the title as it was indexed
+func (ti *BadgerTitleIndex) splitTitleKey(key []byte) (normalizedTitle string, billNumber string, title string) {
+	parts := strings.SplitN(string(key[len(ti.titlePrefix("")):]), titleKeySeparator, 3)
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	return parts[0], parts[1], parts[2]
+}
+
+func txBillTitles(tx *badger.Txn, key []byte) (titles []string, err error) {
+	item, err := tx.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	err = item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &titles)
+	})
+	return titles, err
+}
+
+// Replaces the titles of a bill in the index. Titles the bill no longer has are removed,
+// so this can be called whenever the bill's metadata changes.
+func (ti *BadgerTitleIndex) UpdateBillTitles(billNumber string, titles []string) error {
+	titles = RemoveDuplicates(titles)
+	return ti.db.Update(func(tx *badger.Txn) error {
+		return ti.txUpdateBillTitles(tx, billNumber, titles)
+	})
+}
+
+func (ti *BadgerTitleIndex) txUpdateBillTitles(tx *badger.Txn, billNumber string, titles []string) error {
+	oldTitles, err := txBillTitles(tx, ti.billTitlesKey(billNumber))
+	if err != nil {
+		return err
+	}
+	for _, title := range oldTitles {
+		if err := tx.Delete(ti.titleKey(title, billNumber)); err != nil {
+			return err
+		}
+	}
+	if len(titles) == 0 {
+		return tx.Delete(ti.billTitlesKey(billNumber))
+	}
+	for _, title := range titles {
+		if err := tx.Set(ti.titleKey(title, billNumber), nil); err != nil {
+			return err
+		}
+	}
+	titlesJson, err := json.Marshal(titles)
+	if err != nil {
+		return err
+	}
+	return tx.Set(ti.billTitlesKey(billNumber), titlesJson)
+}
+
+// Gets the titles of a bill in the index
+func (ti *BadgerTitleIndex) BillTitles(billNumber string) (titles []string, err error) {
+	err = ti.db.View(func(tx *badger.Txn) error {
+		titles, err = txBillTitles(tx, ti.billTitlesKey(billNumber))
+		return err
+	})
+	return titles, err
+}
+
+// Iterates over the title keys with the prefix; fn gets the normalized title, bill number and the title as indexed
+func (ti *BadgerTitleIndex) scan(prefix []byte, fn func(normalizedTitle string, billNumber string, title string) bool) error {
+	return ti.db.View(func(tx *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.PrefetchValues = false
+		it := tx.NewIterator(options)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			normalizedTitle, billNumber, title := ti.splitTitleKey(it.Item().Key())
+			if !fn(normalizedTitle, billNumber, title) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// Gets all of the bills that share the normalized title
+func (ti *BadgerTitleIndex) Bills(title string) (billNumbers []string, err error) {
+	err = ti.scan(ti.titlePrefix(NormalizeTitle(title)+titleKeySeparator), func(_ string, billNumber string, _ string) bool {
+		billNumbers = append(billNumbers, billNumber)
+		return true
+	})
+	// A bill has a key for each of its titles with the normalized title
+	return RemoveDuplicates(billNumbers), err
+}
+
+// Gets the bills for each normalized title that starts with the (normalized) prefix,
+// up to limit titles; a limit of 0 returns all of the titles with the prefix
+func (ti *BadgerTitleIndex) TitlesWithPrefix(prefix string, limit int) (map[string][]string, error) {
+	titles := make(map[string][]string)
+	normalizedPrefix := DefaultTitleNormalizer.NormalizePrefix(prefix)
+	err := ti.scan(ti.titlePrefix(normalizedPrefix), func(normalizedTitle string, billNumber string, _ string) bool {
+		if _, ok := titles[normalizedTitle]; !ok && limit > 0 && len(titles) >= limit {
+			return false
+		}
+		titles[normalizedTitle] = append(titles[normalizedTitle], billNumber)
+		return true
+	})
+	for normalizedTitle, billNumbers := range titles {
+		titles[normalizedTitle] = RemoveDuplicates(billNumbers)
+	}
+	return titles, err
+}
+
+// Gets the whole index, keyed by the titles as they were indexed
+func (ti *BadgerTitleIndex) Map() (map[string][]string, error) {
+	titleIndex := make(map[string][]string)
+	err := ti.scan(ti.titlePrefix(""), func(_ string, billNumber string, title string) bool {
+		titleIndex[title] = append(titleIndex[title], billNumber)
+		return true
+	})
+	return titleIndex, err
+}
+
+// Deletes all of the keys with the prefix, in batches
+func deleteBadgerPrefix(db *badger.DB, prefix []byte) error {
+	for {
+		var keys [][]byte
+		err := db.View(func(tx *badger.Txn) error {
+			options := badger.DefaultIteratorOptions
+			options.PrefetchValues = false
+			it := tx.NewIterator(options)
+			defer it.Close()
+			for it.Seek(prefix); it.ValidForPrefix(prefix) && len(keys) < badgerBatchSize; it.Next() {
+				keys = append(keys, it.Item().KeyCopy(nil))
+			}
+			return nil
+		})
+		if err != nil || len(keys) == 0 {
+			return err
+		}
+		err = db.Update(func(tx *badger.Txn) error {
+			for _, key := range keys {
+				if err := tx.Delete(key); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Replaces the whole index with the titleIndex (title -> bill numbers), e.g. from MetaIndex.TitleIndex
+func (ti *BadgerTitleIndex) Put(titleIndex map[string][]string) error {
+	for _, prefix := range []string{titleKeyPrefix, billTitlesKeyPrefix} {
+		if err := deleteBadgerPrefix(ti.db, []byte(prefix+ti.Name+"/")); err != nil {
+			return err
+		}
+	}
+	// Invert the index, so that each bill's titles are written together
+	billTitles := make(map[string][]string)
+	for title, billNumbers := range titleIndex {
+		for _, billNumber := range billNumbers {
+			billTitles[billNumber] = append(billTitles[billNumber], title)
+		}
+	}
+	return ti.putBillTitles(billTitles)
+}
+
+// Writes the title keys of each bill (bill number -> titles), in batches, and records the titleIndexFormat
+func (ti *BadgerTitleIndex) putBillTitles(billTitles map[string][]string) error {
+	billNumbers := make([]string, 0, len(billTitles))
+	for billNumber := range billTitles {
+		billNumbers = append(billNumbers, billNumber)
+	}
+	sort.Strings(billNumbers)
+	for start := 0; start < len(billNumbers); start += badgerBatchSize {
+		end := start + badgerBatchSize
+		if end > len(billNumbers) {
+			end = len(billNumbers)
+		}
+		err := ti.db.Update(func(tx *badger.Txn) error {
+			for _, billNumber := range billNumbers[start:end] {
+				titles := billTitles[billNumber]
+				sort.Strings(titles)
+				if err := ti.txUpdateBillTitles(tx, billNumber, titles); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return ti.db.Update(func(tx *badger.Txn) error {
+		return tx.Set(ti.formatKey(), []byte(titleIndexFormat()))
+	})
+}
+
+// Whether the title keys were made with another normalization than NormalizeTitle (or before it was recorded).
+// An index without title keys does not need to be rebuilt.
+func (ti *BadgerTitleIndex) NeedsRebuild() (needsRebuild bool, err error) {
+	err = ti.db.View(func(tx *badger.Txn) error {
+		item, err := tx.Get(ti.formatKey())
+		if err == badger.ErrKeyNotFound {
+			opts := badger.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			it := tx.NewIterator(opts)
+			defer it.Close()
+			prefix := ti.titlePrefix("")
+			it.Seek(prefix)
+			needsRebuild = it.ValidForPrefix(prefix)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			needsRebuild = string(val) != titleIndexFormat()
+			return nil
+		})
+	})
+	return needsRebuild, err
+}
+
+// Makes the title keys again from the titles of each bill, with NormalizeTitle
+func (ti *BadgerTitleIndex) Rebuild() error {
+	billTitles := make(map[string][]string)
+	prefix := []byte(billTitlesKeyPrefix + ti.Name + "/")
+	err := ti.db.View(func(tx *badger.Txn) error {
+		it := tx.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var titles []string
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &titles)
+			}); err != nil {
+				return err
+			}
+			billTitles[string(it.Item().Key()[len(prefix):])] = titles
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := deleteBadgerPrefix(ti.db, []byte(titleKeyPrefix+ti.Name+"/")); err != nil {
+		return err
+	}
+	log.Info().Msgf("Rebuilding the %s title index for %d bills", ti.Name, len(billTitles))
+	return ti.putBillTitles(billTitles)
+}
+
+// Rebuilds the title index if it was made with another normalization (see NeedsRebuild)
+func (ti *BadgerTitleIndex) RebuildIfNeeded() error {
+	needsRebuild, err := ti.NeedsRebuild()
+	if err != nil || !needsRebuild {
+		return err
+	}
+	return ti.Rebuild()
+}
@@ -0,0 +1,153 @@
+package bills
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTitle(t *testing.T) {
+	log.Info().Msg("Test normalizing titles for the title index")
+	testutils.SetLogLevel()
+	assert.Equal(t, "consumers first act", NormalizeTitle(" Consumers  First Act of 2019"))
+	assert.Equal(t, "consumers first act", NormalizeTitle("Consumers First Act"))
+	assert.Equal(t, "consumers first act", NormalizeTitle("The Consumers First Act."))
+	// The year is only removed from the end of a whole title
+	assert.Equal(t, "consumers first act of 2019", DefaultTitleNormalizer.NormalizePrefix("The Consumers First Act of 2019"))
+}
+
+func TestBadgerTitleIndex(t *testing.T) {
+	log.Info().Msg("Test prefix lookups and incremental updates of a title index in Badger")
+	testutils.SetLogLevel()
+	billStore, err := OpenBadgerBillStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Error opening Badger store: %v", err)
+	}
+	defer billStore.Close()
+	titleIndex := billStore.TitleIndex(TitleNoYearIndex)
+	assert.Nil(t, titleIndex.Put(map[string][]string{
+		"Consumers First Act":         {"116hr1500", "116s1"},
+		"Consumer Protection Act":     {"116hr2"},
+		"Stop Senior Scams Act":       {"116s149"},
+		"Consumers First Act of 2021": {"117hr3"},
+		"U.S. Health-Care Act":        {"116hr4"},
+	}))
+
+	billNumbers, err := titleIndex.Bills("consumers first act")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"116hr1500", "116s1", "117hr3"}, billNumbers)
+
+	titles, err := titleIndex.TitlesWithPrefix("Consumer", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]string{"consumer protection act": {"116hr2"}, "consumers first act": {"116hr1500", "116s1", "117hr3"}}, titles)
+	titles, err = titleIndex.TitlesWithPrefix("Consumer", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(titles))
+	// The prefix is normalized as the titles are: a leading article, punctuation and curly quotes are ignored
+	titles, err = titleIndex.TitlesWithPrefix("The Consumers", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]string{"consumers first act": {"116hr1500", "116s1", "117hr3"}}, titles)
+	titles, err = titleIndex.TitlesWithPrefix("U.S. Health-", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]string{"us health care act": {"116hr4"}}, titles)
+	titles, err = titleIndex.TitlesWithPrefix("“Consumer Pro", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]string{"consumer protection act": {"116hr2"}}, titles)
+
+	// Changing the titles of a bill removes it from its old titles
+	assert.Nil(t, titleIndex.UpdateBillTitles("116s1", []string{"Stop Senior Scams Act"}))
+	billNumbers, err = titleIndex.Bills("Consumers First Act")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"116hr1500", "117hr3"}, billNumbers)
+	billNumbers, err = titleIndex.Bills("Stop Senior Scams Act")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"116s1", "116s149"}, billNumbers)
+	billTitles, err := titleIndex.BillTitles("116s1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Stop Senior Scams Act"}, billTitles)
+
+	// The other index is separate
+	billNumbers, err = billStore.TitleIndex(MainTitleNoYearIndex).Bills("Consumers First Act")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(billNumbers))
+}
+
+func TestBadgerTitleIndexRawTitles(t *testing.T) {
+	log.Info().Msg("Test that titles of a bill with the same normalized form are all kept, and keys are rebuilt after a normalization change")
+	testutils.SetLogLevel()
+	badgerPath := t.TempDir()
+	billStore, err := OpenBadgerBillStore(badgerPath)
+	if err != nil {
+		t.Fatalf("Error opening Badger store: %v", err)
+	}
+	titleIndex := billStore.TitleIndex(TitleNoYearIndex)
+	// An empty index has nothing to rebuild
+	needsRebuild, err := titleIndex.NeedsRebuild()
+	assert.Nil(t, err)
+	assert.False(t, needsRebuild)
+	assert.Nil(t, titleIndex.Put(map[string][]string{
+		"Consumers First Act":     {"116hr1500"},
+		"The Consumers First Act": {"116hr1500", "116s1"},
+	}))
+	titles, err := titleIndex.Map()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]string{"Consumers First Act": {"116hr1500"}, "The Consumers First Act": {"116hr1500", "116s1"}}, titles)
+	billNumbers, err := titleIndex.Bills("consumers first act")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"116hr1500", "116s1"}, billNumbers)
+	needsRebuild, err = titleIndex.NeedsRebuild()
+	assert.Nil(t, err)
+	assert.False(t, needsRebuild)
+
+	// Keys written with another normalization are rebuilt when the store is opened
+	oldNormalizer := DefaultTitleNormalizer
+	DefaultTitleNormalizer, _ = NewTitleNormalizer(TitleStepCase)
+	assert.Nil(t, titleIndex.Rebuild())
+	billNumbers, err = titleIndex.Bills("consumers first act")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"116hr1500"}, billNumbers)
+	DefaultTitleNormalizer = oldNormalizer
+	billStore.Close()
+
+	billStore, err = OpenBadgerBillStore(badgerPath)
+	if err != nil {
+		t.Fatalf("Error opening Badger store: %v", err)
+	}
+	defer billStore.Close()
+	billNumbers, err = billStore.TitleIndex(TitleNoYearIndex).Bills("consumers first act")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"116hr1500", "116s1"}, billNumbers)
+}
+
+func TestUpdateBillTitleIndexes(t *testing.T) {
+	log.Info().Msg("Test updating the title indexes for one bill in each store")
+	testutils.SetLogLevel()
+	billMeta := readSampleBillMeta(t, sampleBillMetaPaths[0])
+	titlesNoYear, mainTitlesNoYear := BillTitlesNoYear(billMeta)
+	assert.Contains(t, titlesNoYear, "Consumers First Act")
+	assert.Contains(t, mainTitlesNoYear, "Consumers First Act")
+
+	badgerStore, err := OpenBadgerBillStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Error opening Badger store: %v", err)
+	}
+	defer badgerStore.Close()
+	parentPath := t.TempDir()
+	assert.Nil(t, os.WriteFile(path.Join(parentPath, TitleNoYearIndex), []byte(`{"Consumers First Act": ["116s1"], "Old Title": ["116hr1500"]}`), 0666))
+
+	for _, billStore := range []BillStore{badgerStore, NewFileBillStore(parentPath)} {
+		assert.Nil(t, UpdateBillTitleIndexes(billStore, billMeta))
+		billNumbers, err := billStore.QueryTitleIndex(TitleNoYearIndex, "Consumers First Act")
+		assert.Nil(t, err)
+		assert.Contains(t, billNumbers, "116hr1500")
+		_, err = billStore.QueryTitleIndex(TitleNoYearIndex, "Old Title")
+		assert.Equal(t, ErrNotFound, err)
+		billNumbers, err = billStore.QueryTitleIndex(MainTitleNoYearIndex, "Consumers First Act")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"116hr1500"}, billNumbers)
+	}
+}
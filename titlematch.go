@@ -0,0 +1,223 @@
+package bills
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Finds titles that are similar, but not the same after normalization (e.g. a bill renamed when it is reintroduced:
+// 'Consumers First Act' and 'Consumer First Act', or 'Stop Senior Scams Act' and 'Stop Senior Scams and Fraud Act').
+// Two titles are similar if the share of their words in common (of the words in either title) is at least
+// MinTokenSimilarity, or if one can be changed to the other by editing at most 1 - MinEditSimilarity of its characters.
+type TitleMatcher struct {
+	MinTokenSimilarity float64
+	MinEditSimilarity  float64
+	// Titles with fewer words (after normalization) are not matched
+	MinTokens int
+	// Words in more titles than this (e.g. 'act') are not used to find candidate titles, though they count in the similarity
+	MaxTokenTitles int
+	Normalizer     *TitleNormalizer
+}
+
+// A pair of similar titles
+type TitlePair struct {
+	Title      string  `json:"title"`
+	OtherTitle string  `json:"other_title"`
+	Similarity float64 `json:"similarity"`
+}
+
+func NewTitleMatcher() *TitleMatcher {
+	return &TitleMatcher{MinTokenSimilarity: 0.8, MinEditSimilarity: 0.9, MinTokens: 3, MaxTokenTitles: 1000, Normalizer: DefaultTitleNormalizer}
+}
+
+func (matcher *TitleMatcher) normalize(title string) string {
+	if matcher.Normalizer == nil {
+		return strings.ToLower(strings.TrimSpace(title))
+	}
+	return matcher.Normalizer.Normalize(title)
+}
+
+// Share of the words in either title that are in both
+func tokenSimilarity(tokens []string, otherTokens []string) float64 {
+	tokenSet := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		tokenSet[token] = true
+	}
+	otherTokenSet := make(map[string]bool, len(otherTokens))
+	shared := 0
+	for _, token := range otherTokens {
+		if tokenSet[token] && !otherTokenSet[token] {
+			shared++
+		}
+		otherTokenSet[token] = true
+	}
+	union := len(tokenSet) + len(otherTokenSet) - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+// 1 - the edit (Levenshtein) distance between the strings, divided by the length of the longer one
+func editSimilarity(a string, b string) float64 {
+	runesA, runesB := []rune(a), []rune(b)
+	if len(runesA) < len(runesB) {
+		runesA, runesB = runesB, runesA
+	}
+	if len(runesA) == 0 {
+		return 1
+	}
+	previous := make([]int, len(runesB)+1)
+	current := make([]int, len(runesB)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(runesA); i++ {
+		current[0] = i
+		for j := 1; j <= len(runesB); j++ {
+			cost := 1
+			if runesA[i-1] == runesB[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(minInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return 1 - float64(previous[len(runesB)])/float64(len(runesA))
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Gets the similarity of the normalized titles (the higher of the token and edit similarity), and whether it is
+// above either threshold. Titles that are the same after normalization are not similar; they are title matches.
+func (matcher *TitleMatcher) matchNormalized(normalized string, otherNormalized string) (similarity float64, ok bool) {
+	if normalized == otherNormalized {
+		return 0, false
+	}
+	tokens, otherTokens := strings.Fields(normalized), strings.Fields(otherNormalized)
+	if len(tokens) < matcher.MinTokens || len(otherTokens) < matcher.MinTokens {
+		return 0, false
+	}
+	tokenSim := tokenSimilarity(tokens, otherTokens)
+	similarity = tokenSim
+	ok = matcher.MinTokenSimilarity > 0 && tokenSim >= matcher.MinTokenSimilarity
+	// The edit distance is at least the difference in length
+	lengthRatio := float64(len(normalized)) / float64(len(otherNormalized))
+	if lengthRatio > 1 {
+		lengthRatio = 1 / lengthRatio
+	}
+	if matcher.MinEditSimilarity > 0 && lengthRatio >= matcher.MinEditSimilarity {
+		editSim := editSimilarity(normalized, otherNormalized)
+		if editSim > similarity {
+			similarity = editSim
+		}
+		ok = ok || editSim >= matcher.MinEditSimilarity
+	}
+	return roundShare(similarity), ok
+}
+
+// Gets the similarity of two titles, and whether they are similar
+func (matcher *TitleMatcher) Match(title string, otherTitle string) (similarity float64, ok bool) {
+	return matcher.matchNormalized(matcher.normalize(title), matcher.normalize(otherTitle))
+}
+
+// Gets the pairs of similar titles, sorted by title. Candidate pairs share at least one word
+// that is in no more than MaxTokenTitles titles.
+func (matcher *TitleMatcher) SimilarTitles(titles []string) (pairs []TitlePair) {
+	titles = append([]string{}, titles...)
+	sort.Strings(titles)
+	normalized := make([]string, len(titles))
+	titlesByToken := make(map[string][]int)
+	for i, title := range titles {
+		normalized[i] = matcher.normalize(title)
+		for _, token := range RemoveDuplicates(strings.Fields(normalized[i])) {
+			titlesByToken[token] = append(titlesByToken[token], i)
+		}
+	}
+	for i := range titles {
+		candidates := make(map[int]bool)
+		for _, token := range RemoveDuplicates(strings.Fields(normalized[i])) {
+			tokenTitles := titlesByToken[token]
+			if matcher.MaxTokenTitles > 0 && len(tokenTitles) > matcher.MaxTokenTitles {
+				continue
+			}
+			for _, j := range tokenTitles {
+				if j > i {
+					candidates[j] = true
+				}
+			}
+		}
+		for j := range candidates {
+			if similarity, ok := matcher.matchNormalized(normalized[i], normalized[j]); ok {
+				pairs = append(pairs, TitlePair{Title: titles[i], OtherTitle: titles[j], Similarity: similarity})
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Title != pairs[j].Title {
+			return pairs[i].Title < pairs[j].Title
+		}
+		return pairs[i].OtherTitle < pairs[j].OtherTitle
+	})
+	return pairs
+}
+
+// Adds the bill to the related bills with TitleSimilarReason, unless the bills already have a title or main title match
+func addSimilarTitleBill(relatedBills RelatedBillMap, billNumber string, title string, otherTitle string, similarity float64, runId string) {
+	if relatedBills[billNumber].HasReason(TitleMatchReason) || relatedBills[billNumber].HasReason(MainTitleMatchReason) {
+		return
+	}
+	provenance := ReasonProvenance{Titles: []string{title, otherTitle}, Score: similarity, RunId: runId}
+	mergeRelatedBill(relatedBills, billNumber, TitleSimilarReason, provenance, func(relatedBillItem *RelatedBillItem) {
+		if similarity > relatedBillItem.TitleSimilarity {
+			relatedBillItem.TitleSimilarity = similarity
+			relatedBillItem.SimilarTitles = []string{title, otherTitle}
+		}
+	})
+}
+
+// Adds the bills with similar titles (see TitleMatcher) to the related bills of each bill in the index, with TitleSimilarReason.
+// Run after LoadTitles, so that bills that already have a title in common are not also related as similar.
+func (mi *MetaIndex) LoadSimilarTitles(matcher *TitleMatcher) {
+	log.Info().Msg("***** Processing similar titles ******")
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	titles := make([]string, 0, len(mi.titleNoYear))
+	for title := range mi.titleNoYear {
+		titles = append(titles, title)
+	}
+	pairs := matcher.SimilarTitles(titles)
+	log.Info().Msgf("Found %d pairs of similar titles", len(pairs))
+	for _, pair := range pairs {
+		for _, billNumber := range mi.titleNoYear[pair.Title] {
+			for _, otherBillNumber := range mi.titleNoYear[pair.OtherTitle] {
+				if billNumber == otherBillNumber {
+					continue
+				}
+				for _, direction := range [][2]string{{billNumber, otherBillNumber}, {otherBillNumber, billNumber}} {
+					billMeta, ok := mi.billMeta[direction[0]]
+					if !ok {
+						log.Error().Msgf("No metadata in the MetaIndex for bill: %s", direction[0])
+						continue
+					}
+					if billMeta.RelatedBillsByBillnumber == nil {
+						billMeta.RelatedBillsByBillnumber = make(RelatedBillMap)
+					}
+					title, otherTitle := pair.Title, pair.OtherTitle
+					if direction[0] != billNumber {
+						title, otherTitle = otherTitle, title
+					}
+					addSimilarTitleBill(billMeta.RelatedBillsByBillnumber, direction[1], title, otherTitle, pair.Similarity, mi.RunId)
+					mi.billMeta[direction[0]] = billMeta
+				}
+			}
+		}
+	}
+}
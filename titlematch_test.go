@@ -0,0 +1,98 @@
+package bills
+
+import (
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTitleMatcher(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test matching similar titles")
+	matcher := NewTitleMatcher()
+	// One letter apart: similar by edit distance
+	similarity, ok := matcher.Match("Consumers First Act", "The Consumer First Act of 2021")
+	assert.True(t, ok)
+	assert.Equal(t, 0.947, similarity)
+	// Titles that are the same after normalization are title matches, not similar titles
+	_, ok = matcher.Match("Consumers First Act", "The Consumers First Act.")
+	assert.False(t, ok)
+	similarity, ok = matcher.Match("Stop Senior Scams Act", "Stop Senior Scams and Fraud Act")
+	assert.False(t, ok)
+	assert.Equal(t, 0.667, similarity)
+	_, ok = matcher.Match("Border Act", "Borders Act")
+	assert.False(t, ok, "titles shorter than MinTokens")
+
+	matcher.MinTokenSimilarity = 0.6
+	_, ok = matcher.Match("Stop Senior Scams Act", "Stop Senior Scams and Fraud Act")
+	assert.True(t, ok)
+
+	pairs := matcher.SimilarTitles([]string{"Stop Senior Scams and Fraud Act", "Consumers First Act", "Stop Senior Scams Act", "Consumer First Act", "Clean Water Act"})
+	assert.Equal(t, []TitlePair{
+		{Title: "Consumer First Act", OtherTitle: "Consumers First Act", Similarity: 0.947},
+		{Title: "Stop Senior Scams Act", OtherTitle: "Stop Senior Scams and Fraud Act", Similarity: 0.667},
+	}, pairs)
+	// 'act' is in too many titles to find candidates, and the titles have no other words in common
+	matcher.MaxTokenTitles = 2
+	assert.Equal(t, 0, len(matcher.SimilarTitles([]string{"Clean Water Act", "Clean Air Act", "Safe Water Act"})))
+}
+
+func TestLoadSimilarTitles(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test adding bills with similar titles to the related bills")
+	metaIndex := NewMetaIndex()
+	for billNumber, titles := range map[string][]string{
+		"116hr1500": {"Consumers First Act"},
+		"117hr200":  {"Consumer First Act"},
+		"117s100":   {"Consumer First Act", "Consumers First Act"},
+		"116s149":   {"Stop Senior Scams Act"},
+	} {
+		metaIndex.SetBillMeta(BillMeta{BillCongressTypeNumber: billNumber, RelatedBillsByBillnumber: make(RelatedBillMap)})
+		for _, title := range titles {
+			metaIndex.AddTitle(title, billNumber)
+		}
+	}
+	metaIndex.LoadTitles()
+	metaIndex.LoadSimilarTitles(NewTitleMatcher())
+
+	billMeta, _ := metaIndex.GetBillMeta("116hr1500")
+	assert.Equal(t, RelatedBillItem{
+		BillCongressTypeNumber: "117hr200",
+		BillId:                 "hr200-117",
+		Reason:                 NewReasonSet(TitleSimilarReason),
+		IdentifiedBy:           NewSourceSet(IdentifiedByBillMap),
+		SimilarTitles:          []string{"Consumers First Act", "Consumer First Act"},
+		TitleSimilarity:        0.947,
+		Provenance: map[string]ReasonProvenance{TitleSimilarReason: {
+			IdentifiedBy: IdentifiedByBillMap,
+			Titles:       []string{"Consumers First Act", "Consumer First Act"},
+			Score:        0.947,
+		}},
+	}, billMeta.RelatedBillsByBillnumber["117hr200"])
+	// 117s100 has both titles: it is a title match, and not also similar
+	assert.Equal(t, NewReasonSet(TitleMatchReason), billMeta.RelatedBillsByBillnumber["117s100"].Reason)
+	assert.Equal(t, 0.0, billMeta.RelatedBillsByBillnumber["117s100"].TitleSimilarity)
+	billMeta, _ = metaIndex.GetBillMeta("117hr200")
+	assert.Equal(t, []string{"Consumer First Act", "Consumers First Act"}, billMeta.RelatedBillsByBillnumber["116hr1500"].SimilarTitles)
+	// Only related to itself, by its title
+	billMeta, _ = metaIndex.GetBillMeta("116s149")
+	assert.Equal(t, []string{"116s149"}, sortedKeys(relatedBillKeys(billMeta.RelatedBillsByBillnumber)))
+
+	// A bill related by its main title is not also similar
+	relatedBills := RelatedBillMap{"117hr200": {BillCongressTypeNumber: "117hr200", Reason: NewReasonSet(MainTitleMatchReason)}}
+	addSimilarTitleBill(relatedBills, "117hr200", "Consumers First Act", "Consumer First Act", 0.947, "")
+	assert.Equal(t, NewReasonSet(MainTitleMatchReason), relatedBills["117hr200"].Reason)
+	assert.Equal(t, 0.0, relatedBills["117hr200"].TitleSimilarity)
+	addSimilarTitleBill(relatedBills, "117s100", "Consumers First Act", "Consumer First Act", 0.947, "")
+	assert.Equal(t, NewReasonSet(TitleSimilarReason), relatedBills["117s100"].Reason)
+}
+
+func relatedBillKeys(relatedBills RelatedBillMap) map[string]bool {
+	keys := make(map[string]bool)
+	for billNumber := range relatedBills {
+		keys[billNumber] = true
+	}
+	return keys
+}
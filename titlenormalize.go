@@ -0,0 +1,140 @@
+package bills
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Steps of title normalization, applied in the order of TitleNormalizeSteps
+const (
+	// Replaces curly quotes, dashes and non-breaking spaces with their ASCII forms
+	TitleStepQuotes = "quotes"
+	// Removes punctuation; hyphens, dashes and slashes become spaces (e.g. 'U.S. Health-Care Act.' -> 'US Health Care Act')
+	TitleStepPunctuation = "punctuation"
+	// Collapses whitespace
+	TitleStepWhitespace = "whitespace"
+	// Removes the year at the end (e.g. '... of 2019')
+	TitleStepYear = "year"
+	// Removes a leading 'The', 'A' or 'An'
+	TitleStepArticles = "articles"
+	// Removes a final 'Act' or 'Resolution'. Not in the default steps, since it also matches
+	// bills and resolutions of the same name.
+	TitleStepSuffix = "suffix"
+	// Lower-cases the title
+	TitleStepCase = "case"
+)
+
+var (
+	TitleNormalizeSteps        = []string{TitleStepQuotes, TitleStepPunctuation, TitleStepWhitespace, TitleStepYear, TitleStepArticles, TitleStepSuffix, TitleStepCase}
+	DefaultTitleNormalizeSteps = []string{TitleStepQuotes, TitleStepPunctuation, TitleStepWhitespace, TitleStepYear, TitleStepArticles, TitleStepCase}
+	// Normalizes titles with the DefaultTitleNormalizeSteps
+	DefaultTitleNormalizer = &TitleNormalizer{steps: DefaultTitleNormalizeSteps}
+
+	titleYearRegexCompiled    = regexp.MustCompile(`(?i)\s*\bof\s+[0-9]{4}$`)
+	titleArticleRegexCompiled = regexp.MustCompile(`(?i)^(the|an|a)\s+`)
+	titleSuffixRegexCompiled  = regexp.MustCompile(`(?i)\s+(act|resolution)$`)
+	titleQuotesReplacer       = strings.NewReplacer("“", `"`, "”", `"`, "‘", "'", "’", "'", "«", `"`, "»", `"`, "–", "-", "—", "-", "\u00a0", " ")
+	titleNormalizeStepFuncs   = map[string]func(string) string{
+		TitleStepQuotes:      titleQuotesReplacer.Replace,
+		TitleStepPunctuation: removeTitlePunctuation,
+		TitleStepWhitespace:  func(title string) string { return strings.Join(strings.Fields(title), " ") },
+		TitleStepYear:        func(title string) string { return titleYearRegexCompiled.ReplaceAllString(title, "") },
+		TitleStepArticles:    func(title string) string { return titleArticleRegexCompiled.ReplaceAllString(title, "") },
+		TitleStepSuffix:      removeTitleSuffix,
+		TitleStepCase:        strings.ToLower,
+	}
+)
+
+func removeTitlePunctuation(title string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '-' || r == '/' || unicode.Is(unicode.Pd, r):
+			return ' '
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			return -1
+		default:
+			return r
+		}
+	}, title)
+}
+
+// Removes a final 'Act' or 'Resolution', unless that is the whole title
+func removeTitleSuffix(title string) string {
+	if trimmed := titleSuffixRegexCompiled.ReplaceAllString(title, ""); strings.TrimSpace(trimmed) != "" {
+		return trimmed
+	}
+	return title
+}
+
+// Normalizes titles with a configurable set of steps, so that titles that differ only
+// in those respects (e.g. 'The Consumers First Act.' and 'Consumers First Act') are matched
+type TitleNormalizer struct {
+	steps []string
+}
+
+// Makes a normalizer with the steps (see TitleNormalizeSteps). The steps are applied in the order of TitleNormalizeSteps,
+// whatever their order here. With no steps, titles are only trimmed.
+func NewTitleNormalizer(steps ...string) (*TitleNormalizer, error) {
+	selected := make(map[string]bool)
+	for _, step := range steps {
+		if _, ok := titleNormalizeStepFuncs[step]; !ok {
+			return nil, fmt.Errorf("unknown title normalization step: %s (options: %s)", step, strings.Join(TitleNormalizeSteps, ", "))
+		}
+		selected[step] = true
+	}
+	normalizer := &TitleNormalizer{}
+	for _, step := range TitleNormalizeSteps {
+		if selected[step] {
+			normalizer.steps = append(normalizer.steps, step)
+		}
+	}
+	return normalizer, nil
+}
+
+// Gets the steps of the normalizer, in the order they are applied
+func (normalizer *TitleNormalizer) Steps() []string {
+	return append([]string{}, normalizer.steps...)
+}
+
+func (normalizer *TitleNormalizer) Normalize(title string) string {
+	for _, step := range normalizer.steps {
+		title = titleNormalizeStepFuncs[step](title)
+	}
+	return strings.TrimSpace(title)
+}
+
+// Normalizes the start of a title, for a prefix search of normalized titles. The year and suffix
+// steps are left out, since they only apply at the end of a whole title.
+func (normalizer *TitleNormalizer) NormalizePrefix(prefix string) string {
+	for _, step := range normalizer.steps {
+		if step == TitleStepYear || step == TitleStepSuffix {
+			continue
+		}
+		prefix = titleNormalizeStepFuncs[step](prefix)
+	}
+	return strings.TrimSpace(prefix)
+}
+
+// The number of distinct titles added to a title index, and the number of buckets they were
+// normalized into; Merged is the number of titles that were merged into the bucket of another title (Titles - Buckets)
+type TitleMergeStats struct {
+	Index   string `json:"index"`
+	Titles  int    `json:"titles"`
+	Buckets int    `json:"buckets"`
+	Merged  int    `json:"merged"`
+}
+
+// Groups titles by their normalized form. Each bucket is named by one of its titles (the first in sort order),
+// so the title indexes keep readable titles.
+type titleBuckets struct {
+	// normalized title -> bucket title
+	names map[string]string
+	// titles as they were added
+	titles map[string]bool
+}
+
+func newTitleBuckets() *titleBuckets {
+	return &titleBuckets{names: make(map[string]string), titles: make(map[string]bool)}
+}
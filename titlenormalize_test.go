@@ -0,0 +1,67 @@
+package bills
+
+import (
+	"testing"
+
+	"github.com/aih/bills/internal/testutils"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTitleNormalizer(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test the title normalization steps")
+	for _, title := range []string{"Consumers First Act", "The Consumers First Act.", "  consumers  FIRST act of 2019", "“Consumers First” Act", "Consumers First Act of 2019."} {
+		assert.Equal(t, "consumers first act", DefaultTitleNormalizer.Normalize(title), title)
+	}
+	assert.Equal(t, "us health care act", DefaultTitleNormalizer.Normalize("U.S. Health-Care Act"))
+	// 'A' is only removed as a word
+	assert.Equal(t, "american dream act", DefaultTitleNormalizer.Normalize("American Dream Act"))
+
+	normalizer, err := NewTitleNormalizer(TitleStepCase, TitleStepSuffix, TitleStepYear)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{TitleStepYear, TitleStepSuffix, TitleStepCase}, normalizer.Steps())
+	assert.Equal(t, "consumers first", normalizer.Normalize("Consumers First Act of 2019"))
+	assert.Equal(t, "consumers first", normalizer.Normalize("Consumers First Resolution"))
+	assert.Equal(t, "act", normalizer.Normalize("Act"))
+
+	normalizer, err = NewTitleNormalizer()
+	assert.Nil(t, err)
+	assert.Equal(t, "The Act.", normalizer.Normalize(" The Act. "))
+	_, err = NewTitleNormalizer("stemming")
+	assert.NotNil(t, err)
+}
+
+func TestMetaIndexTitleNormalization(t *testing.T) {
+	testutils.SetLogLevel()
+	log.Info().Msg("Test merging titles in the MetaIndex title indexes")
+	metaIndex := NewMetaIndex()
+	metaIndex.AddTitle("The Consumers First Act.", "116hr1500")
+	metaIndex.AddTitle("Consumers First Act", "117hr200")
+	metaIndex.AddTitle("consumers first act", "117s100")
+	metaIndex.AddTitle("Stop Senior Scams Act", "116s149")
+	metaIndex.AddMainTitle("Consumers First Act", "117hr200")
+
+	// The entry is named by the first of its titles in sort order, whatever the order they were added
+	assert.Equal(t, []string{"Consumers First Act", "Stop Senior Scams Act"}, sortedKeys(titleIndexKeys(metaIndex.TitleIndex())))
+	assert.ElementsMatch(t, []string{"116hr1500", "117hr200", "117s100"}, metaIndex.TitleBills("the consumers first act"))
+	titleStats, mainTitleStats := metaIndex.TitleMergeStats()
+	assert.Equal(t, TitleMergeStats{Index: TitleNoYearIndex, Titles: 4, Buckets: 2, Merged: 2}, titleStats)
+	assert.Equal(t, TitleMergeStats{Index: MainTitleNoYearIndex, Titles: 1, Buckets: 1}, mainTitleStats)
+
+	metaIndex = NewMetaIndex()
+	metaIndex.TitleNormalizer = nil
+	metaIndex.AddTitle("The Consumers First Act.", "116hr1500")
+	metaIndex.AddTitle("Consumers First Act", "117hr200")
+	assert.Equal(t, []string{"117hr200"}, metaIndex.TitleBills("Consumers First Act"))
+	titleStats, _ = metaIndex.TitleMergeStats()
+	assert.Equal(t, 0, titleStats.Merged)
+}
+
+func titleIndexKeys(titleIndex map[string][]string) map[string]bool {
+	keys := make(map[string]bool)
+	for title := range titleIndex {
+		keys[title] = true
+	}
+	return keys
+}